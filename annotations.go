@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxElementValueDepth bounds how deeply annotation values may nest, so
+// that a hostile class can't exhaust the stack.
+const maxElementValueDepth = 64
+
+// elementValueKinds names the kind of value each element_value tag holds,
+// and the kind of constant it refers to if any (JVMS §4.7.16.1).
+var elementValueKinds = map[byte]struct {
+	name     string
+	constant string
+}{
+	'B': {"byte", intKind},
+	'C': {"char", intKind},
+	'D': {"double", doubleKind},
+	'F': {"float", floatKind},
+	'I': {"int", intKind},
+	'J': {"long", longKind},
+	'S': {"short", intKind},
+	'Z': {"boolean", intKind},
+	's': {"String", utf8Kind},
+	'e': {"enum", ""},
+	'c': {"class", ""},
+	'@': {"annotation", ""},
+	'[': {"array", ""},
+}
+
+var primitiveTypeNames = map[string]string{
+	"B": "byte",
+	"C": "char",
+	"D": "double",
+	"F": "float",
+	"I": "int",
+	"J": "long",
+	"S": "short",
+	"Z": "boolean",
+	"V": "void",
+}
+
+// javaTypeName renders a field descriptor, or V, as it would be written in
+// Java source, e.g. "java.lang.String[]" for "[Ljava/lang/String;".
+// Anything else is returned as is.
+func javaTypeName(descriptor string) string {
+	if descriptor == "V" {
+		return "void"
+	}
+	t, err := parseFieldDescriptor(descriptor)
+	if err != nil {
+		return descriptor
+	}
+	return t.String()
+}
+
+// parseAnnotations reads the table of a RuntimeVisibleAnnotations or
+// RuntimeInvisibleAnnotations attribute.
+func (p *sectionParser) parseAnnotations(name string, bytes []byte, start, end int) []Section {
+	if end-start < 2 {
+		return p.malformedInfo(name, start, end)
+	}
+	sections, _, next, ok := p.parseAnnotationTable(name, bytes[:end], start)
+	if !ok {
+		return append(sections, p.opaqueInfo(next, end)...)
+	}
+	return append(sections, p.leftoverInfo(name, next, end)...)
+}
+
+// parseParameterAnnotations reads the table of a
+// RuntimeVisibleParameterAnnotations or RuntimeInvisibleParameterAnnotations
+// attribute, which holds a table of annotations for each parameter.
+func (p *sectionParser) parseParameterAnnotations(name string, bytes []byte, start, end int) []Section {
+	if end-start < 1 {
+		return p.malformedInfo(name, start, end)
+	}
+	bytes = bytes[:end]
+	count := int(bytes[start])
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 1,
+		Name:       fmt.Sprintf("number of parameters: %d", count),
+	}}
+	next := start + 1
+	for i := 0; i < count; i++ {
+		if next+2 > end {
+			p.annotationTruncated(name, bytes, next, "a parameter")
+			return append(sections, p.opaqueInfo(next, end)...)
+		}
+		children, texts, after, ok := p.parseAnnotationTable(name, bytes, next)
+		if !ok {
+			return append(sections, p.opaqueInfo(next, end)...)
+		}
+		text := strings.Join(texts, " ")
+		if len(texts) == 0 {
+			text = "no annotations"
+		}
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   after,
+			Name:       fmt.Sprintf("parameter %d: %s", i, text),
+			Children:   children,
+		})
+		next = after
+	}
+	return append(sections, p.leftoverInfo(name, next, end)...)
+}
+
+func (p *sectionParser) parseAnnotationDefault(bytes []byte, start, end int) []Section {
+	if end-start < 1 {
+		return p.malformedInfo("AnnotationDefault", start, end)
+	}
+	value, _, ok := p.parseElementValue("AnnotationDefault", bytes[:end], start, 0)
+	if !ok {
+		return p.opaqueInfo(start, end)
+	}
+	value.Name = "default value: " + value.Name
+	return append([]Section{value}, p.leftoverInfo("AnnotationDefault", value.EndIndex, end)...)
+}
+
+// parseAnnotationTable reads a u2 count followed by that many annotations,
+// returning their sections and how each is written in Java. If one can't
+// be read, the sections up to it are returned along with where it starts.
+// The caller makes sure the count is within bytes.
+func (p *sectionParser) parseAnnotationTable(name string, bytes []byte, index int) (sections []Section, texts []string, next int, ok bool) {
+	count := int(newByteParser(bytes, index).u2())
+	sections = []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 2,
+		Name:       fmt.Sprintf("number of annotations: %d", count),
+	}}
+	next = index + 2
+	for i := 0; i < count; i++ {
+		annotation, text, ok := p.parseAnnotation(name, bytes, next, 0)
+		if !ok {
+			return sections, texts, next, false
+		}
+		sections = append(sections, annotation)
+		texts = append(texts, text)
+		next = annotation.EndIndex
+	}
+	return sections, texts, next, true
+}
+
+// parseAnnotation reads an annotation structure and renders it as it would
+// be written in Java, e.g. @java.lang.Deprecated(since="9"). Problems are
+// recorded against name, the attribute being read.
+func (p *sectionParser) parseAnnotation(name string, bytes []byte, index, depth int) (Section, string, bool) {
+	if index+4 > len(bytes) {
+		p.annotationTruncated(name, bytes, index, "an annotation")
+		return Section{}, "", false
+	}
+	parser := newByteParser(bytes, index)
+	typeIndex := parser.u2()
+	count := int(parser.u2())
+	descriptor, _ := p.constantPoolUtf8(typeIndex)
+	children := []Section{
+		p.indexSection(bytes, index, "type index", utf8Kind),
+		{
+			Id:         p.nextId(),
+			StartIndex: index + 2,
+			EndIndex:   index + 4,
+			Name:       fmt.Sprintf("number of element value pairs: %d", count),
+		},
+	}
+	next := index + 4
+	var pairs []string
+	for i := 0; i < count; i++ {
+		if next+2 > len(bytes) {
+			p.annotationTruncated(name, bytes, next, "an element value pair")
+			return Section{}, "", false
+		}
+		element, _ := p.constantPoolUtf8(newByteParser(bytes, next).u2())
+		elementName := p.indexSection(bytes, next, "element name index", utf8Kind)
+		value, text, ok := p.parseElementValue(name, bytes, next+2, depth+1)
+		if !ok {
+			return Section{}, "", false
+		}
+		pair := fmt.Sprintf("%s=%s", element, text)
+		pairs = append(pairs, pair)
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   value.EndIndex,
+			Name:       pair,
+			Children:   []Section{elementName, value},
+		})
+		next = value.EndIndex
+	}
+	text := "@" + javaTypeName(descriptor)
+	if len(pairs) > 0 {
+		text += "(" + strings.Join(pairs, ", ") + ")"
+	}
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       text,
+		Children:   children,
+	}, text, true
+}
+
+// parseElementValue reads an element_value, returning its section and how
+// it would be written in Java.
+func (p *sectionParser) parseElementValue(name string, bytes []byte, index, depth int) (Section, string, bool) {
+	if index >= len(bytes) {
+		p.annotationTruncated(name, bytes, index, "an element value")
+		return Section{}, "", false
+	}
+	tag := bytes[index]
+	kind, known := elementValueKinds[tag]
+	if !known {
+		p.errorf(index, index+1, "%s attribute has an element value with unknown tag %q", name, tag)
+		return Section{}, "", false
+	}
+	if depth > maxElementValueDepth {
+		p.errorf(index, index+1, "%s attribute has element values nested more than %d deep", name, maxElementValueDepth)
+		return Section{}, "", false
+	}
+	children := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 1,
+		Name:       fmt.Sprintf("tag: '%c' (%s)", tag, kind.name),
+	}}
+	next := index + 1
+	// need reports whether n more bytes are available, recording an error
+	// if not.
+	need := func(n int) bool {
+		if next+n > len(bytes) {
+			p.annotationTruncated(name, bytes, index, "an element value")
+			return false
+		}
+		return true
+	}
+
+	var text string
+	switch tag {
+	case 'e':
+		if !need(4) {
+			return Section{}, "", false
+		}
+		parser := newByteParser(bytes, next)
+		typeName, _ := p.constantPoolUtf8(parser.u2())
+		constName, _ := p.constantPoolUtf8(parser.u2())
+		children = append(children,
+			p.indexSection(bytes, next, "type name index", utf8Kind),
+			p.indexSection(bytes, next+2, "const name index", utf8Kind),
+		)
+		text = javaTypeName(typeName) + "." + constName
+		next += 4
+	case 'c':
+		if !need(2) {
+			return Section{}, "", false
+		}
+		descriptor, _ := p.constantPoolUtf8(newByteParser(bytes, next).u2())
+		children = append(children, p.indexSection(bytes, next, "class info index", utf8Kind))
+		text = javaTypeName(descriptor) + ".class"
+		next += 2
+	case '@':
+		annotation, annotationText, ok := p.parseAnnotation(name, bytes, next, depth)
+		if !ok {
+			return Section{}, "", false
+		}
+		children = append(children, annotation)
+		text = annotationText
+		next = annotation.EndIndex
+	case '[':
+		if !need(2) {
+			return Section{}, "", false
+		}
+		count := int(newByteParser(bytes, next).u2())
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 2,
+			Name:       fmt.Sprintf("number of values: %d", count),
+		})
+		next += 2
+		var values []string
+		for i := 0; i < count; i++ {
+			value, valueText, ok := p.parseElementValue(name, bytes, next, depth+1)
+			if !ok {
+				return Section{}, "", false
+			}
+			children = append(children, value)
+			values = append(values, valueText)
+			next = value.EndIndex
+		}
+		text = "{" + strings.Join(values, ", ") + "}"
+	default:
+		if !need(2) {
+			return Section{}, "", false
+		}
+		value := newByteParser(bytes, next).u2()
+		children = append(children, p.indexSection(bytes, next, "const value index", kind.constant))
+		text = p.elementConstant(tag, value)
+		next += 2
+	}
+	label := fmt.Sprintf("%s %s", kind.name, text)
+	if tag == 'c' {
+		// Class literals already say what they are.
+		label = text
+	}
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       label,
+		Children:   children,
+	}, text, true
+}
+
+// elementConstant renders the constant at index as a Java literal of the
+// type given by an element_value tag.
+func (p *sectionParser) elementConstant(tag byte, index uint16) string {
+	item, _ := p.constantPoolItem(index)
+	switch c := item.(type) {
+	case intConstant:
+		switch tag {
+		case 'Z':
+			return strconv.FormatBool(c.value != 0)
+		case 'C':
+			return quoteJavaChar(uint16(c.value))
+		}
+		return strconv.Itoa(int(c.value))
+	case longConstant:
+		return fmt.Sprintf("%dL", c.value)
+	case floatConstant:
+		return javaFloatString(float64(c.value), 32) + "f"
+	case doubleConstant:
+		return javaFloatString(c.value, 64)
+	case utf8String:
+		return javaQuote(c.contents)
+	}
+	return p.resolve(index, elementValueKinds[tag].constant)
+}
+
+// annotationTruncated records that the attribute being read ends partway
+// through the structure starting at index.
+func (p *sectionParser) annotationTruncated(name string, bytes []byte, index int, what string) {
+	p.errorf(index, len(bytes), "%s attribute ends in the middle of %s", name, what)
+}
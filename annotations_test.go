@@ -0,0 +1,153 @@
+package main
+
+import "testing"
+
+func TestParseAnnotations(t *testing.T) {
+	tag := parsedFixture(t, "Tag.class")
+	checkOutline(t, findSection(t, tag, "method java.lang.annotation.RetentionPolicy policy()", "attribute AnnotationDefault"), 2, `
+594-605 attribute AnnotationDefault
+  594-596 name index: #2 -> AnnotationDefault
+  596-600 length: 5
+  600-605 default value: enum java.lang.annotation.RetentionPolicy.RUNTIME
+    600-601 tag: 'e' (enum)
+    601-603 type name index: #12 -> Ljava/lang/annotation/RetentionPolicy;
+    603-605 const name index: #13 -> RUNTIME
+`)
+	checkOutline(t, findSection(t, tag, "class has 1 attributes"), 4, `
+622-662 class has 1 attributes
+  622-624 attributes count: 1
+  624-662 attribute RuntimeVisibleAnnotations
+    624-626 name index: #25 -> RuntimeVisibleAnnotations
+    626-630 length: 32
+    630-632 number of annotations: 2
+    632-643 @java.lang.annotation.Retention(value=java.lang.annotation.RetentionPolicy.RUNTIME)
+      632-634 type index: #20 -> Ljava/lang/annotation/Retention;
+      634-636 number of element value pairs: 1
+      636-643 value=java.lang.annotation.RetentionPolicy.RUNTIME
+        636-638 element name index: #3 -> value
+        638-643 enum java.lang.annotation.RetentionPolicy.RUNTIME
+    643-662 @java.lang.annotation.Target(value={java.lang.annotation.ElementType.TYPE_USE, java.lang.annotation.ElementType.TYPE})
+      643-645 type index: #21 -> Ljava/lang/annotation/Target;
+      645-647 number of element value pairs: 1
+      647-662 value={java.lang.annotation.ElementType.TYPE_USE, java.lang.annotation.ElementType.TYPE}
+        647-649 element name index: #3 -> value
+        649-662 array {java.lang.annotation.ElementType.TYPE_USE, java.lang.annotation.ElementType.TYPE}
+`)
+	checkOutline(t, findSection(t, parsedFixture(t, "Annotated.class"), "attribute RuntimeVisibleParameterAnnotations"), 3, `
+871-886 attribute RuntimeVisibleParameterAnnotations
+  871-873 name index: #37 -> RuntimeVisibleParameterAnnotations
+  873-877 length: 9
+  877-878 number of parameters: 2
+  878-884 parameter 0: @java.lang.Deprecated
+    878-880 number of annotations: 1
+    880-884 @java.lang.Deprecated
+      880-882 type index: #1 -> Ljava/lang/Deprecated;
+      882-884 number of element value pairs: 0
+  884-886 parameter 1: no annotations
+    884-886 number of annotations: 0
+`)
+
+	b := newClassBuilder()
+	value := func(tag rune, index int) []byte {
+		return join(u1(int(tag)), u2(index))
+	}
+	pair := func(name string, value []byte) []byte {
+		return join(u2(b.utf8(name)), value)
+	}
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "A", super: "java/lang/Object",
+		attributes: [][]byte{
+			b.attribute("RuntimeInvisibleAnnotations", u2(1),
+				u2(b.utf8("LOuter;")), u2(8),
+				pair("inner", join(u1('@'), u2(b.utf8("LInner;")), u2(1), pair("n", value('I', b.integer(1))))),
+				pair("list", join(u1('['), u2(2), value('I', b.integer(1)), value('I', b.integer(2)))),
+				pair("flag", value('Z', b.integer(1))),
+				pair("ch", value('C', b.integer('x'))),
+				pair("f", value('F', b.float(1.5))),
+				pair("d", value('D', b.double(2))),
+				pair("b", value('B', b.integer(1))),
+				pair("sh", value('S', b.integer(2)))),
+		},
+	})
+	sections, diagnostics := parseClass(class)
+	if len(diagnostics) != 0 {
+		t.Errorf("built class: %v", diagnostics)
+	}
+	checkCoverage(t, "built class", sections, len(class))
+	checkOutline(t, findSection(t, sections, "attribute RuntimeInvisibleAnnotations"), 4, `
+180-245 attribute RuntimeInvisibleAnnotations
+  180-182 name index: #18 -> RuntimeInvisibleAnnotations
+  182-186 length: 59
+  186-188 number of annotations: 1
+  188-245 @Outer(inner=@Inner(n=1), list={1, 2}, flag=true, ch='x', f=1.5f, d=2.0, b=1, sh=2)
+    188-190 type index: #1 -> LOuter;
+    190-192 number of element value pairs: 8
+    192-204 inner=@Inner(n=1)
+      192-194 element name index: #5 -> inner
+      194-204 annotation @Inner(n=1)
+        194-195 tag: '@' (annotation)
+        195-204 @Inner(n=1)
+    204-215 list={1, 2}
+      204-206 element name index: #7 -> list
+      206-215 array {1, 2}
+        206-207 tag: '[' (array)
+        207-209 number of values: 2
+        209-212 int 1
+        212-215 int 2
+    215-220 flag=true
+      215-217 element name index: #8 -> flag
+      217-220 boolean true
+        217-218 tag: 'Z' (boolean)
+        218-220 const value index: #3 -> 1
+    220-225 ch='x'
+      220-222 element name index: #10 -> ch
+      222-225 char 'x'
+        222-223 tag: 'C' (char)
+        223-225 const value index: #9 -> 120
+    225-230 f=1.5f
+      225-227 element name index: #12 -> f
+      227-230 float 1.5f
+        227-228 tag: 'F' (float)
+        228-230 const value index: #11 -> 1.5
+    230-235 d=2.0
+      230-232 element name index: #15 -> d
+      232-235 double 2.0
+        232-233 tag: 'D' (double)
+        233-235 const value index: #13 -> 2
+    235-240 b=1
+      235-237 element name index: #16 -> b
+      237-240 byte 1
+        237-238 tag: 'B' (byte)
+        238-240 const value index: #3 -> 1
+    240-245 sh=2
+      240-242 element name index: #17 -> sh
+      242-245 short 2
+        242-243 tag: 'S' (short)
+        243-245 const value index: #6 -> 2
+`)
+}
+
+func TestElementCharConstant(t *testing.T) {
+	tests := []struct {
+		value int32
+		want  string
+	}{
+		{'x', `'x'`},
+		{'é', `'é'`},
+		{'\'', `'\''`},
+		{'"', `'"'`},
+		{'\\', `'\\'`},
+		{'\n', `'\n'`},
+		{0, `'\u0000'`},
+		{0xD83D, `'\ud83d'`},
+	}
+	for _, test := range tests {
+		p := newSectionParser()
+		p.constantPool = []ConstantPoolItem{intConstant{test.value}}
+		if got := p.elementConstant('C', 1); got != test.want {
+			t.Errorf("char %#04x: rendered as %s, want %s", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// archive is a jar, war or zip file whose entries can be browsed.
+type archive struct {
+	Id      string         `json:"id"`
+	Name    string         `json:"name"`
+	Entries []archiveEntry `json:"entries"`
+	reader  *zip.Reader
+}
+
+type archiveEntry struct {
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+}
+
+// maxArchives is how many uploaded archives we keep around before the
+// oldest are forgotten.
+const maxArchives = 16
+
+type archiveStore struct {
+	sync.Mutex
+	archives map[string]*archive
+	order    []string
+	nextId   int
+}
+
+func newArchiveStore() *archiveStore {
+	return &archiveStore{archives: map[string]*archive{}}
+}
+
+func (s *archiveStore) add(name string, data []byte) (*archive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	a := &archive{Name: name, reader: reader}
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		a.Entries = append(a.Entries, archiveEntry{f.Name, f.UncompressedSize64})
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	a.Id = strconv.Itoa(s.nextId)
+	s.nextId++
+	s.archives[a.Id] = a
+	s.order = append(s.order, a.Id)
+	if len(s.order) > maxArchives {
+		delete(s.archives, s.order[0])
+		s.order = s.order[1:]
+	}
+	return a, nil
+}
+
+func (s *archiveStore) get(id string) (*archive, bool) {
+	s.Lock()
+	defer s.Unlock()
+	a, ok := s.archives[id]
+	return a, ok
+}
+
+func (s *archiveStore) list() []*archive {
+	s.Lock()
+	defer s.Unlock()
+	var archives []*archive
+	for _, id := range s.order {
+		archives = append(archives, s.archives[id])
+	}
+	return archives
+}
+
+// read returns the uncompressed contents of the named entry.
+func (a *archive) read(name string) ([]byte, error) {
+	for _, f := range a.reader.File {
+		if f.Name != name {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		// Don't trust the sizes in the archive's directory.
+		data, err := ioutil.ReadAll(io.LimitReader(r, maxUploadSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxUploadSize {
+			return nil, fmt.Errorf("%s is larger than %d bytes", name, maxUploadSize)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s has no entry called %s", a.Name, name)
+}
+
+// source returns the Java source of the class entry at classPath, for
+// archives such as source jars that ship it alongside the class.
+func (a *archive) source(classPath string, classFile []byte) ([]byte, error) {
+	name, ok := sourcePath(classPath, classFile)
+	if !ok {
+		return nil, fmt.Errorf("%s has no source file", classPath)
+	}
+	data, err := a.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if !isText(data) {
+		return nil, fmt.Errorf("%s is not text", name)
+	}
+	return data, nil
+}
+
+func isClassEntry(name string) bool {
+	return strings.HasSuffix(name, ".class")
+}
+
+// isText guesses whether an entry such as MANIFEST.MF can be shown as text.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testJar returns a jar holding a class, its source, a manifest and a
+// binary resource.
+func testJar(t *testing.T, class []byte) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"META-INF/", nil},
+		{"META-INF/MANIFEST.MF", []byte("Manifest-Version: 1.0\r\n")},
+		{"com/example/Hello11.class", class},
+		{"com/example/Hello11.java", []byte("class Hello11 {}\n")},
+		{"com/example/logo.png", []byte{0x89, 'P', 'N', 'G', 0}},
+	} {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(entry.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := newRouter(nil, nil, newArchiveStore(), nil)
+	class := readFixture(t, "Hello11.class")
+	get := func(method, url string, body []byte, status int, v interface{}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, url, bytes.NewReader(body)))
+		if w.Code != status {
+			t.Errorf("%s %s: status %d: %s", method, url, w.Code, w.Body.String())
+			return
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Errorf("%s %s: %v", method, url, err)
+		}
+	}
+
+	var a archive
+	get("POST", "/archive?name=app.jar", testJar(t, class), http.StatusOK, &a)
+	wantEntries := []archiveEntry{
+		{"META-INF/MANIFEST.MF", 23},
+		{"com/example/Hello11.class", uint64(len(class))},
+		{"com/example/Hello11.java", 17},
+		{"com/example/logo.png", 5},
+	}
+	if a.Id != "0" || a.Name != "app.jar" || !reflect.DeepEqual(a.Entries, wantEntries) {
+		t.Errorf("archive %+v", a)
+	}
+	var list []archive
+	get("GET", "/archive", nil, http.StatusOK, &list)
+	if len(list) != 1 || list[0].Id != "0" {
+		t.Errorf("archives %+v", list)
+	}
+
+	var entry struct {
+		Name   string
+		Source string
+		classResponse
+	}
+	get("GET", "/archive/0/com/example/Hello11.class", nil, http.StatusOK, &entry)
+	sections, _ := parseClass(class)
+	want, _ := json.Marshal(sections)
+	if parsed, _ := json.Marshal(entry.Parsed); !bytes.Equal(parsed, want) {
+		t.Error("class entry: sections differ from those of parseClass")
+	}
+	checkCoverage(t, "class entry", entry.Parsed, len(class))
+	if entry.Name != "com/example/Hello11.class" || entry.Source != "class Hello11 {}\n" {
+		t.Errorf("class entry %q with source %q", entry.Name, entry.Source)
+	}
+
+	var text struct{ Name, Text string }
+	get("GET", "/archive/0/META-INF/MANIFEST.MF", nil, http.StatusOK, &text)
+	if text.Text != "Manifest-Version: 1.0\r\n" {
+		t.Errorf("manifest %q", text.Text)
+	}
+	var binary struct {
+		Name   string
+		Binary int
+	}
+	get("GET", "/archive/0/com/example/logo.png", nil, http.StatusOK, &binary)
+	if binary.Binary != 5 {
+		t.Errorf("binary entry %+v", binary)
+	}
+
+	var failure struct{ Error string }
+	get("GET", "/archive/0/missing.class", nil, http.StatusNotFound, &failure)
+	get("GET", "/archive/7/com/example/Hello11.class", nil, http.StatusNotFound, &failure)
+	get("POST", "/archive?name=bad.jar", class, http.StatusBadRequest, &failure)
+}
+
+func TestArchiveStoreForgetsOldest(t *testing.T) {
+	s := newArchiveStore()
+	jar := testJar(t, nil)
+	for i := 0; i <= maxArchives; i++ {
+		if _, err := s.add("app.jar", jar); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := s.get("0"); ok {
+		t.Error("the oldest archive is still kept")
+	}
+	if list := s.list(); len(list) != maxArchives || list[0].Id != "1" {
+		t.Errorf("%d archives starting with %s", len(list), list[0].Id)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import "fmt"
+
+// parseAttributes reads an attributes_count followed by that many
+// attributes, as found at the end of fields, methods, Code and the class.
+func (p *sectionParser) parseAttributes(bytes []byte, index int) (next int, sections []Section) {
+	next = index + 2
+	parser := newByteParser(bytes, index)
+	attributesCount := int(parser.u2())
+	sections = append(sections, Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("attributes count: %d", attributesCount),
+	})
+	for i := 0; i < attributesCount; i++ {
+		if next+6 > len(bytes) {
+			p.errorf(index, index+2, "attributes count is %d but only %d attributes fit", attributesCount, i)
+			if next < len(bytes) {
+				sections = append(sections, Section{
+					Id:         p.nextId(),
+					StartIndex: next,
+					EndIndex:   len(bytes),
+					Name:       fmt.Sprintf("truncated attribute: %d bytes", len(bytes)-next),
+				})
+			}
+			next = len(bytes)
+			break
+		}
+		var attribute *Section
+		next, attribute = p.parseAttribute(bytes, next)
+		sections = append(sections, *attribute)
+	}
+	return
+}
+
+func (p *sectionParser) parseAttribute(bytes []byte, index int) (next int, section *Section) {
+	parser := newByteParser(bytes, index)
+	nameIndex := parser.u2()
+	length := int(parser.u4())
+	name, ok := p.constantPoolUtf8(nameIndex)
+	if !ok {
+		name = "unknown"
+	}
+	infoStart := index + 6
+	next = infoStart + length
+	if next > len(bytes) || next < infoStart {
+		p.errorf(index+2, infoStart, "attribute %s is %d bytes long but only %d bytes remain", name, length, len(bytes)-infoStart)
+		next = len(bytes)
+	}
+	children := []Section{
+		{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   index + 2,
+			Name:       fmt.Sprintf("name index: %s", p.resolveAt(index, nameIndex, utf8Kind)),
+			Target:     p.constantSectionId(nameIndex),
+		},
+		{
+			Id:         p.nextId(),
+			StartIndex: index + 2,
+			EndIndex:   infoStart,
+			Name:       fmt.Sprintf("length: %d", length),
+		},
+	}
+	children = append(children, p.parseAttributeInfo(name, bytes, infoStart, next)...)
+	section = &Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("attribute %s", name),
+		Children:   children,
+	}
+	return
+}
+
+// parseAttributeInfo decodes the info bytes between start and end for the
+// attributes we understand. Anything else is shown as an opaque blob so
+// that every byte of the attribute is still covered by a section.
+func (p *sectionParser) parseAttributeInfo(name string, bytes []byte, start, end int) []Section {
+	switch name {
+	case "Code":
+		return p.parseCodeAttribute(bytes, start, end)
+	case "StackMapTable":
+		return p.parseStackMapTable(bytes, start, end)
+	case "LineNumberTable":
+		return p.parseLineNumberTable(bytes, start, end)
+	case "LocalVariableTable":
+		return p.parseLocalVariableTable(name, bytes, start, end, "descriptor")
+	case "LocalVariableTypeTable":
+		return p.parseLocalVariableTable(name, bytes, start, end, "signature")
+	case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
+		return p.parseAnnotations(name, bytes, start, end)
+	case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+		return p.parseParameterAnnotations(name, bytes, start, end)
+	case "RuntimeVisibleTypeAnnotations", "RuntimeInvisibleTypeAnnotations":
+		return p.parseTypeAnnotations(name, bytes, start, end)
+	case "AnnotationDefault":
+		return p.parseAnnotationDefault(bytes, start, end)
+	case "ConstantValue":
+		return p.parseConstantValue(bytes, start, end)
+	case "Exceptions":
+		return p.parseIndexTable(name, bytes, start, end, "number of exceptions", "exception index", classKind)
+	case "MethodParameters":
+		return p.parseMethodParameters(bytes, start, end)
+	case "SourceFile":
+		return p.parseSingleIndex(name, bytes, start, end, "source file index", utf8Kind)
+	case "Signature":
+		return p.parseSignature(bytes, start, end)
+	case "NestHost":
+		return p.parseSingleIndex(name, bytes, start, end, "host class index", classKind)
+	case "NestMembers":
+		return p.parseIndexTable(name, bytes, start, end, "number of classes", "class index", classKind)
+	case "PermittedSubclasses":
+		return p.parseIndexTable(name, bytes, start, end, "number of classes", "class index", classKind)
+	case "BootstrapMethods":
+		return p.parseBootstrapMethods(bytes, start, end)
+	case "InnerClasses":
+		return p.parseInnerClasses(bytes, start, end)
+	case "EnclosingMethod":
+		return p.parseEnclosingMethod(bytes, start, end)
+	case "SourceDebugExtension":
+		return p.parseSourceDebugExtension(bytes, start, end)
+	case "Record":
+		return p.parseRecord(bytes, start, end)
+	case "Module":
+		return p.parseModule(bytes, start, end)
+	case "ModulePackages":
+		return p.parseIndexTable(name, bytes, start, end, "package count", "package index", packageKind)
+	case "ModuleMainClass":
+		return p.parseSingleIndex(name, bytes, start, end, "main class index", classKind)
+	}
+	return p.opaqueInfo(start, end)
+}
+
+func (p *sectionParser) opaqueInfo(start, end int) []Section {
+	if start >= end {
+		return nil
+	}
+	return []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   end,
+		Name:       fmt.Sprintf("info: %d bytes", end-start),
+	}}
+}
+
+func (p *sectionParser) parseConstantValue(bytes []byte, start, end int) []Section {
+	if end-start != 2 {
+		return p.malformedInfo("ConstantValue", start, end)
+	}
+	return []Section{p.indexSection(bytes, start, "constant value index", intKind, floatKind, longKind, doubleKind, stringKind)}
+}
+
+// indexSection is a section for a u2 constant pool index that should
+// refer to one of the expected kinds of constant.
+func (p *sectionParser) indexSection(bytes []byte, index int, label string, expected ...string) Section {
+	value := newByteParser(bytes, index).u2()
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 2,
+		Name:       fmt.Sprintf("%s: %s", label, p.resolveAt(index, value, expected...)),
+		Target:     p.constantSectionId(value),
+	}
+}
+
+// optionalIndexSection is indexSection for indexes where zero means none.
+func (p *sectionParser) optionalIndexSection(bytes []byte, index int, label string, expected ...string) Section {
+	value := newByteParser(bytes, index).u2()
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 2,
+		Name:       fmt.Sprintf("%s: %s", label, p.resolveOptionalAt(index, value, expected...)),
+		Target:     p.constantSectionId(value),
+	}
+}
+
+func (p *sectionParser) parseSingleIndex(name string, bytes []byte, start, end int, label string, expected string) []Section {
+	if end-start != 2 {
+		return p.malformedInfo(name, start, end)
+	}
+	return []Section{p.indexSection(bytes, start, label, expected)}
+}
+
+// parseIndexTable reads a u2 count followed by that many u2 constant pool
+// indexes.
+func (p *sectionParser) parseIndexTable(name string, bytes []byte, start, end int, countLabel, label string, expected string) []Section {
+	parser := newByteParser(bytes, start)
+	count := int(parser.u2())
+	if end-start != 2+2*count {
+		return p.malformedInfo(name, start, end)
+	}
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("%s: %d", countLabel, count),
+	}}
+	for i := 0; i < count; i++ {
+		sections = append(sections, p.indexSection(bytes, start+2+2*i, label, expected))
+	}
+	return sections
+}
+
+func (p *sectionParser) parseInnerClasses(bytes []byte, start, end int) []Section {
+	parser := newByteParser(bytes, start)
+	count := int(parser.u2())
+	if end-start != 2+8*count {
+		return p.malformedInfo("InnerClasses", start, end)
+	}
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("number of classes: %d", count),
+	}}
+	for i := 0; i < count; i++ {
+		index := start + 2 + 8*i
+		inner := newByteParser(bytes, index).u2()
+		name, ok := p.constantPoolClassName(inner)
+		if !ok {
+			name = fmt.Sprintf("#%d", inner)
+		}
+		_, flags := p.parseFlags(bytes, index+6, innerClassFlags)
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   index + 8,
+			Name:       fmt.Sprintf("inner class %s", name),
+			Children: []Section{
+				p.indexSection(bytes, index, "inner class info index", classKind),
+				p.optionalIndexSection(bytes, index+2, "outer class info index", classKind),
+				p.optionalIndexSection(bytes, index+4, "inner name index", utf8Kind),
+				*flags,
+			},
+		})
+	}
+	return sections
+}
+
+func (p *sectionParser) parseMethodParameters(bytes []byte, start, end int) []Section {
+	if end-start < 1 {
+		return p.malformedInfo("MethodParameters", start, end)
+	}
+	count := int(bytes[start])
+	if end-start != 1+4*count {
+		return p.malformedInfo("MethodParameters", start, end)
+	}
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 1,
+		Name:       fmt.Sprintf("parameters count: %d", count),
+	}}
+	for i := 0; i < count; i++ {
+		index := start + 1 + 4*i
+		name, ok := p.constantPoolUtf8(newByteParser(bytes, index).u2())
+		if !ok {
+			name = "(unnamed)"
+		}
+		_, flags := p.parseFlags(bytes, index+2, methodParameterFlags)
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   index + 4,
+			Name:       "parameter " + name,
+			Children: []Section{
+				p.optionalIndexSection(bytes, index, "name index", utf8Kind),
+				*flags,
+			},
+		})
+	}
+	return sections
+}
+
+func (p *sectionParser) parseEnclosingMethod(bytes []byte, start, end int) []Section {
+	if end-start != 4 {
+		return p.malformedInfo("EnclosingMethod", start, end)
+	}
+	return []Section{
+		p.indexSection(bytes, start, "class index", classKind),
+		p.optionalIndexSection(bytes, start+2, "method index", nameAndTypeKind),
+	}
+}
+
+func (p *sectionParser) parseSourceDebugExtension(bytes []byte, start, end int) []Section {
+	if start >= end {
+		return nil
+	}
+	return []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   end,
+		Name:       fmt.Sprintf("debug extension: %q", string(bytes[start:end])),
+	}}
+}
+
+func (p *sectionParser) parseRecord(bytes []byte, start, end int) []Section {
+	if end-start < 2 {
+		return p.malformedInfo("Record", start, end)
+	}
+	parser := newByteParser(bytes, start)
+	count := int(parser.u2())
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("components count: %d", count),
+	}}
+	next := start + 2
+	for i := 0; i < count; i++ {
+		if next+6 > end {
+			p.errorf(start, start+2, "components count is %d but only %d components fit", count, i)
+			break
+		}
+		index := next
+		parser = newByteParser(bytes, index)
+		name, _ := p.constantPoolUtf8(parser.u2())
+		descriptor, _ := p.constantPoolUtf8(parser.u2())
+		children := []Section{
+			p.indexSection(bytes, index, "name index", utf8Kind),
+			p.indexSection(bytes, index+2, "descriptor index", utf8Kind),
+		}
+		var attributes []Section
+		p.memberKind, p.memberName = "record component", name
+		next, attributes = p.parseAttributes(bytes[:end], index+4)
+		p.memberKind, p.memberName = "", ""
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   next,
+			Name:       "component " + fieldDeclaration(name, descriptor),
+			Children:   append(children, attributes...),
+		})
+	}
+	return append(sections, p.leftoverInfo("Record", next, end)...)
+}
@@ -0,0 +1,108 @@
+package main
+
+import "testing"
+
+func TestParseClassAttributes(t *testing.T) {
+	checkOutline(t, findSection(t, parsedFixture(t, "Box5.class"), "class has 3 attributes"), 2, `
+829-857 class has 3 attributes
+  829-831 attributes count: 3
+  831-837 attribute Deprecated
+    831-833 name index: #36 -> Deprecated
+    833-837 length: 0
+  837-845 attribute Signature
+    837-839 name index: #7 -> Signature
+    839-843 length: 2
+    843-845 signature: <T extends java.lang.Comparable<T>> extends java.lang.Object implements java.io.Serializable
+  845-857 attribute RuntimeVisibleAnnotations
+    845-847 name index: #39 -> RuntimeVisibleAnnotations
+    847-851 length: 6
+    851-853 number of annotations: 1
+    853-857 @java.lang.Deprecated
+`)
+	checkOutline(t, findSection(t, parsedFixture(t, "Nest11$Inner.class"), "class has 2 attributes"), 3, `
+296-322 class has 2 attributes
+  296-298 attributes count: 2
+  298-306 attribute NestHost
+    298-300 name index: #23 -> NestHost
+    300-304 length: 2
+    304-306 host class index: #16 -> Nest11
+  306-322 attribute InnerClasses
+    306-308 name index: #25 -> InnerClasses
+    308-312 length: 10
+    312-314 number of classes: 1
+    314-322 inner class Nest11$Inner
+      314-316 inner class info index: #4 -> Nest11$Inner
+      316-318 outer class info index: #16 -> Nest11
+      318-320 inner name index: #24 -> Inner
+      320-322 access flags
+`)
+	checkOutline(t, findSection(t, parsedFixture(t, "Nest11.class"), "attribute NestMembers"), 1, `
+667-677 attribute NestMembers
+  667-669 name index: #32 -> NestMembers
+  669-673 length: 4
+  673-675 number of classes: 1
+  675-677 class index: #12 -> Nest11$Inner
+`)
+	checkOutline(t, findSection(t, parsedFixture(t, "Point17.class"), "attribute Record"), 2, `
+969-989 attribute Record
+  969-971 name index: #46 -> Record
+  971-975 length: 14
+  975-977 components count: 2
+  977-983 component int x
+    977-979 name index: #12 -> x
+    979-981 descriptor index: #13 -> I
+    981-983 attributes count: 0
+  983-989 component int y
+    983-985 name index: #17 -> y
+    985-987 descriptor index: #13 -> I
+    987-989 attributes count: 0
+`)
+	checkOutline(t, findSection(t, parsedFixture(t, "Shape25.class"), "attribute PermittedSubclasses"), 1, `
+620-632 attribute PermittedSubclasses
+  620-622 name index: #24 -> PermittedSubclasses
+  622-626 length: 6
+  626-628 number of classes: 2
+  628-630 class index: #21 -> Circle
+  630-632 class index: #23 -> Square
+`)
+
+	// javac writes these for local classes and other compilers, and
+	// anything unknown has to be shown as it is.
+	b := newClassBuilder()
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0020,
+		this:  "Outer$1", super: "java/lang/Object",
+		attributes: [][]byte{
+			b.attribute("EnclosingMethod", u2(b.class("Outer")), u2(b.nameAndType("run", "()V"))),
+			b.attribute("Synthetic"),
+			b.attribute("SourceDebugExtension", []byte("SMAP\n")),
+			b.attribute("Custom", u1(1), u1(2), u1(3)),
+		},
+	})
+	sections, diagnostics := parseClass(class)
+	if len(diagnostics) != 0 {
+		t.Errorf("built class: %v", diagnostics)
+	}
+	checkCoverage(t, "built class", sections, len(class))
+	checkOutline(t, sections[len(sections)-1], 2, `
+147-185 class has 4 attributes
+  147-149 attributes count: 4
+  149-159 attribute EnclosingMethod
+    149-151 name index: #6 -> EnclosingMethod
+    151-155 length: 4
+    155-157 class index: #2 -> Outer
+    157-159 method index: #5 -> run:()V
+  159-165 attribute Synthetic
+    159-161 name index: #7 -> Synthetic
+    161-165 length: 0
+  165-176 attribute SourceDebugExtension
+    165-167 name index: #8 -> SourceDebugExtension
+    167-171 length: 5
+    171-176 debug extension: "SMAP\n"
+  176-185 attribute Custom
+    176-178 name index: #9 -> Custom
+    178-182 length: 3
+    182-185 info: 3 bytes
+`)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bootstrapMethod is an entry of the BootstrapMethods attribute: a method
+// handle that links invokedynamic call sites, and the constants passed to
+// it.
+type bootstrapMethod struct {
+	methodRef uint16
+	arguments []uint16
+}
+
+// bootstrapArgumentKinds are the kinds of constant a bootstrap method can
+// be passed.
+var bootstrapArgumentKinds = []string{intKind, floatKind, longKind, doubleKind, classKind, stringKind, methodHandleKind, methodTypeKind, dynamicKind}
+
+func parseBootstrapMethodsInfo(info []byte) ([]bootstrapMethod, error) {
+	r := newByteParser(info, 0)
+	count := int(r.u2())
+	var methods []bootstrapMethod
+	for i := 0; i < count && r.err == nil; i++ {
+		m := bootstrapMethod{methodRef: r.u2()}
+		arguments := int(r.u2())
+		for j := 0; j < arguments && r.err == nil; j++ {
+			m.arguments = append(m.arguments, r.u2())
+		}
+		methods = append(methods, m)
+	}
+	if r.err != nil || r.pos != len(info) {
+		return nil, errors.New("the BootstrapMethods attribute is malformed")
+	}
+	return methods, nil
+}
+
+// bootstrapMethods returns the entries of the class's BootstrapMethods
+// attribute, along with where its info starts.
+func (c *Class) bootstrapMethods() ([]bootstrapMethod, int, error) {
+	for _, a := range c.attributes {
+		if a.name == "BootstrapMethods" {
+			methods, err := parseBootstrapMethodsInfo(a.info)
+			return methods, a.offset, err
+		}
+	}
+	return nil, 0, errors.New("the class has no BootstrapMethods attribute")
+}
+
+// findBootstrapMethods reads the BootstrapMethods attribute ahead of the
+// constant pool that refers to it, reserving ids for the sections of its
+// entries so that invoke dynamic constants can link to them. Nothing is
+// found if the class can't be read.
+func (p *sectionParser) findBootstrapMethods(data []byte) {
+	c, err := ParseClass(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	methods, offset, err := c.bootstrapMethods()
+	if err != nil {
+		return
+	}
+	p.bootstrapMethods = methods
+	p.bootstrapOffset = offset
+	for range methods {
+		p.bootstrapSections = append(p.bootstrapSections, p.nextId())
+	}
+}
+
+func (p *sectionParser) parseBootstrapMethods(bytes []byte, start, end int) []Section {
+	if end-start < 2 {
+		return p.malformedInfo("BootstrapMethods", start, end)
+	}
+	count := int(newByteParser(bytes, start).u2())
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("number of bootstrap methods: %d", count),
+	}}
+	next := start + 2
+	for i := 0; i < count; i++ {
+		parser := newByteParser(bytes, next)
+		m := bootstrapMethod{methodRef: parser.u2()}
+		arguments := int(parser.u2())
+		if next+4 > end || next+4+2*arguments > end {
+			p.errorf(start, start+2, "number of bootstrap methods is %d but only %d fit", count, i)
+			break
+		}
+		children := []Section{
+			p.indexSection(bytes, next, "bootstrap method ref", methodHandleKind),
+			{
+				Id:         p.nextId(),
+				StartIndex: next + 2,
+				EndIndex:   next + 4,
+				Name:       fmt.Sprintf("number of arguments: %d", arguments),
+			},
+		}
+		for j := 0; j < arguments; j++ {
+			m.arguments = append(m.arguments, parser.u2())
+			children = append(children, p.indexSection(bytes, next+4+2*j, "argument", bootstrapArgumentKinds...))
+		}
+		var id int
+		if start == p.bootstrapOffset && i < len(p.bootstrapSections) {
+			id = p.bootstrapSections[i]
+		} else {
+			id = p.nextId()
+		}
+		sections = append(sections, Section{
+			Id:         id,
+			StartIndex: next,
+			EndIndex:   next + 4 + 2*arguments,
+			Name:       fmt.Sprintf("bootstrap method %d: %s", i, p.describeBootstrapMethod(m)),
+			Children:   children,
+		})
+		next += 4 + 2*arguments
+	}
+	return append(sections, p.leftoverInfo("BootstrapMethods", next, end)...)
+}
+
+// withBootstrapMethod adds a description of the bootstrap method at index
+// to value, the rendering of a dynamic constant or invoke dynamic item.
+// Dynamic constants passed as bootstrap arguments are left as they are,
+// since their bootstrap methods can lead back to the one being described.
+func (p *sectionParser) withBootstrapMethod(value string, index uint16) string {
+	if p.describingBootstrap || int(index) >= len(p.bootstrapMethods) {
+		return value
+	}
+	return fmt.Sprintf("%s (%s)", value, p.bootstrapMethodAt(index))
+}
+
+// bootstrapMethodAt describes the i'th bootstrap method, as found by
+// findBootstrapMethods.
+func (p *sectionParser) bootstrapMethodAt(i uint16) string {
+	if int(i) >= len(p.bootstrapMethods) {
+		return fmt.Sprintf("<bootstrap method %d is out of range>", i)
+	}
+	return p.describeBootstrapMethod(p.bootstrapMethods[i])
+}
+
+// describeBootstrapMethod renders a bootstrap method. The ones javac uses
+// for lambdas and string concatenation are shown by what they produce,
+// e.g. "LambdaMetafactory.metafactory -> lambda$main$0 ()V".
+func (p *sectionParser) describeBootstrapMethod(m bootstrapMethod) string {
+	class, name, _, ok := p.methodHandleTarget(m.methodRef)
+	if !ok {
+		return p.resolve(m.methodRef, methodHandleKind)
+	}
+	simpleName := class[strings.LastIndex(class, "/")+1:]
+	argument := func(i int) ConstantPoolItem {
+		if i >= len(m.arguments) {
+			return nil
+		}
+		item, _ := p.constantPoolItem(m.arguments[i])
+		return item
+	}
+	switch class + "." + name {
+	case "java/lang/invoke/LambdaMetafactory.metafactory", "java/lang/invoke/LambdaMetafactory.altMetafactory":
+		if _, ok := argument(1).(methodHandle); ok {
+			if _, implementation, descriptor, ok := p.methodHandleTarget(m.arguments[1]); ok {
+				return fmt.Sprintf("%s.%s -> %s %s", simpleName, name, implementation, descriptor)
+			}
+		}
+	case "java/lang/invoke/StringConcatFactory.makeConcatWithConstants":
+		if recipe, ok := argument(0).(stringConstant); ok {
+			if s, ok := p.constantPoolUtf8(recipe.utf8Index); ok {
+				return fmt.Sprintf("%s recipe %s", simpleName, javaQuote(s))
+			}
+		}
+	}
+	var arguments []string
+	// Dynamic constants among the arguments are shown without their own
+	// bootstrap methods, which may well be this one.
+	if !p.describingBootstrap {
+		p.describingBootstrap = true
+		defer func() { p.describingBootstrap = false }()
+	}
+	for _, a := range m.arguments {
+		if marker := p.checkConstant(a, bootstrapArgumentKinds...); marker != "" {
+			arguments = append(arguments, marker)
+		} else {
+			arguments = append(arguments, p.constantValue(a))
+		}
+	}
+	return fmt.Sprintf("%s.%s(%s)", simpleName, name, strings.Join(arguments, ", "))
+}
+
+// methodHandleTarget returns the member a method handle constant refers
+// to.
+func (p *sectionParser) methodHandleTarget(index uint16) (class, name, descriptor string, ok bool) {
+	item, _ := p.constantPoolItem(index)
+	handle, ok := item.(methodHandle)
+	if !ok {
+		return "", "", "", false
+	}
+	target, _ := p.constantPoolItem(handle.referenceIndex)
+	var classIndex, natIndex uint16
+	switch ref := target.(type) {
+	case fieldRef:
+		classIndex, natIndex = ref.classIndex, ref.nameAndTypeIndex
+	case methodRef:
+		classIndex, natIndex = ref.classIndex, ref.nameAndTypeIndex
+	case interfaceMethodRef:
+		classIndex, natIndex = ref.classIndex, ref.nameAndTypeIndex
+	default:
+		return "", "", "", false
+	}
+	class, classOk := p.constantPoolClassName(classIndex)
+	natItem, _ := p.constantPoolItem(natIndex)
+	nat, natOk := natItem.(nameAndType)
+	if !classOk || !natOk {
+		return "", "", "", false
+	}
+	name, nameOk := p.constantPoolUtf8(nat.nameIndex)
+	descriptor, descriptorOk := p.constantPoolUtf8(nat.descriptorIndex)
+	return class, name, descriptor, nameOk && descriptorOk
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestParseBootstrapMethods(t *testing.T) {
+	sections := parsedFixture(t, "Lambda8.class")
+	checkOutline(t, findSection(t, sections, "attribute BootstrapMethods"), 2, `
+980-998 attribute BootstrapMethods
+  980-982 name index: #18 -> BootstrapMethods
+  982-986 length: 12
+  986-988 number of bootstrap methods: 1
+  988-998 bootstrap method 0: LambdaMetafactory.metafactory -> lambda$main$0 ()Ljava/lang/String;
+    988-990 bootstrap method ref: #7 -> REF_invokeStatic java/lang/invoke/LambdaMetafactory.metafactory:(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodHandle;Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/CallSite;
+    990-992 number of arguments: 3
+    992-994 argument: #9 -> ()Ljava/lang/Object;
+    994-996 argument: #16 -> REF_invokeStatic Lambda8.lambda$main$0:()Ljava/lang/String;
+    996-998 argument: #17 -> ()Ljava/lang/String;
+`)
+	constant := findSection(t, sections, "[29] invoke dynamic")
+	checkOutline(t, constant, 1, `
+498-503 [29] invoke dynamic: #0:get:()Ljava/util/function/Supplier; (LambdaMetafactory.metafactory -> lambda$main$0 ()Ljava/lang/String;)
+  498-499 tag: 18
+  499-501 bootstrap method attribute index: 0 -> LambdaMetafactory.metafactory -> lambda$main$0 ()Ljava/lang/String;
+  501-503 name and type index: #28 -> get:()Ljava/util/function/Supplier;
+`)
+	checkOutline(t, findSection(t, sections, "method void main", "0: invokedynamic"), 1, `
+907-912 0: invokedynamic #29 // InvokeDynamic #0:get:()Ljava/util/function/Supplier; (LambdaMetafactory.metafactory -> lambda$main$0 ()Ljava/lang/String;)
+  907-908 opcode 0xba: invokedynamic
+  908-910 constant pool index: #29 -> #0:get:()Ljava/util/function/Supplier; (LambdaMetafactory.metafactory -> lambda$main$0 ()Ljava/lang/String;)
+  910-912 reserved: 0
+`)
+	if method := findSection(t, sections, "bootstrap method 0"); constant.Children[1].Target != method.Id {
+		t.Errorf("invoke dynamic links to %d, want bootstrap method 0 at %d", constant.Children[1].Target, method.Id)
+	}
+
+	checkOutline(t, findSection(t, parsedFixture(t, "Nest11.class"), "[29] invoke dynamic"), 1, `
+422-427 [29] invoke dynamic: #0:makeConcatWithConstants:(I)Ljava/lang/String; (StringConcatFactory recipe "count=\u0001")
+  422-423 tag: 18
+  423-425 bootstrap method attribute index: 0 -> StringConcatFactory recipe "count=\u0001"
+  425-427 name and type index: #28 -> makeConcatWithConstants:(I)Ljava/lang/String;
+`)
+
+	// An index past the end of the BootstrapMethods attribute, or without
+	// one at all, is marked.
+	b := newClassBuilder()
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "I", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0009, "m", "()Ljava/lang/Runnable;",
+				b.code(1, 0, join(
+					op("invokedynamic"), u2(b.invokeDynamic(3, "run", "()Ljava/lang/Runnable;")), u2(0),
+					op("areturn"),
+				), nil)),
+		},
+	})
+	sections, _ = parseClass(class)
+	checkCoverage(t, "built class", sections, len(class))
+	checkOutline(t, findSection(t, sections, "constant pool", "[4] invoke dynamic"), 1, `
+46-51 [4] invoke dynamic: #3:run:()Ljava/lang/Runnable;
+  46-47 tag: 18
+  47-49 bootstrap method attribute index: 3 -> <bootstrap method 3 is out of range>
+  49-51 name and type index: #3 -> run:()Ljava/lang/Runnable;
+`)
+}
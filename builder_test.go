@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// classBuilder assembles class files for tests. Constants are added to the
+// pool the first time they're asked for and shared after that, as javac
+// does, so a test can ask for an index wherever it needs one.
+type classBuilder struct {
+	pool    bytes.Buffer
+	count   int
+	indexes map[string]int
+}
+
+func newClassBuilder() *classBuilder {
+	return &classBuilder{count: 1, indexes: map[string]int{}}
+}
+
+func (b *classBuilder) constant(key string, wide bool, entry ...[]byte) int {
+	if index, ok := b.indexes[key]; ok {
+		return index
+	}
+	b.pool.Write(join(entry...))
+	index := b.count
+	b.count++
+	if wide {
+		b.count++
+	}
+	b.indexes[key] = index
+	return index
+}
+
+func (b *classBuilder) utf8(s string) int {
+	return b.modifiedUTF8([]byte(s))
+}
+
+// modifiedUTF8 adds a UTF-8 constant holding exactly the bytes given, for
+// tests that need encodings plain Go strings don't produce.
+func (b *classBuilder) modifiedUTF8(s []byte) int {
+	return b.constant(fmt.Sprintf("utf8 %q", s), false, u1(1), u2(len(s)), s)
+}
+
+func (b *classBuilder) integer(v int32) int {
+	return b.constant(fmt.Sprint("int ", v), false, u1(3), u4(int(v)))
+}
+
+func (b *classBuilder) float(v float32) int {
+	return b.constant(fmt.Sprint("float ", v), false, u1(4), u4(int(math.Float32bits(v))))
+}
+
+func (b *classBuilder) long(v int64) int {
+	return b.constant(fmt.Sprint("long ", v), true, u1(5), u8(uint64(v)))
+}
+
+func (b *classBuilder) double(v float64) int {
+	return b.constant(fmt.Sprint("double ", v), true, u1(6), u8(math.Float64bits(v)))
+}
+
+func (b *classBuilder) class(name string) int {
+	return b.constant("class "+name, false, u1(7), u2(b.utf8(name)))
+}
+
+func (b *classBuilder) string(s string) int {
+	return b.constant("string "+s, false, u1(8), u2(b.utf8(s)))
+}
+
+func (b *classBuilder) nameAndType(name, descriptor string) int {
+	return b.constant("nat "+name+" "+descriptor, false, u1(12), u2(b.utf8(name)), u2(b.utf8(descriptor)))
+}
+
+func (b *classBuilder) fieldref(class, name, descriptor string) int {
+	return b.constant("field "+class+"."+name+":"+descriptor, false, u1(9), u2(b.class(class)), u2(b.nameAndType(name, descriptor)))
+}
+
+func (b *classBuilder) methodref(class, name, descriptor string) int {
+	return b.constant("method "+class+"."+name+":"+descriptor, false, u1(10), u2(b.class(class)), u2(b.nameAndType(name, descriptor)))
+}
+
+func (b *classBuilder) interfaceMethodref(class, name, descriptor string) int {
+	return b.constant("imethod "+class+"."+name+":"+descriptor, false, u1(11), u2(b.class(class)), u2(b.nameAndType(name, descriptor)))
+}
+
+func (b *classBuilder) methodHandle(kind, reference int) int {
+	return b.constant(fmt.Sprint("handle ", kind, " ", reference), false, u1(15), u1(kind), u2(reference))
+}
+
+func (b *classBuilder) methodType(descriptor string) int {
+	return b.constant("type "+descriptor, false, u1(16), u2(b.utf8(descriptor)))
+}
+
+func (b *classBuilder) dynamic(bootstrap int, name, descriptor string) int {
+	return b.constant(fmt.Sprint("dynamic ", bootstrap, " ", name, ":", descriptor), false, u1(17), u2(bootstrap), u2(b.nameAndType(name, descriptor)))
+}
+
+func (b *classBuilder) invokeDynamic(bootstrap int, name, descriptor string) int {
+	return b.constant(fmt.Sprint("indy ", bootstrap, " ", name, ":", descriptor), false, u1(18), u2(bootstrap), u2(b.nameAndType(name, descriptor)))
+}
+
+func (b *classBuilder) module(name string) int {
+	return b.constant("module "+name, false, u1(19), u2(b.utf8(name)))
+}
+
+func (b *classBuilder) pkg(name string) int {
+	return b.constant("package "+name, false, u1(20), u2(b.utf8(name)))
+}
+
+// attribute returns an attribute with the given info bytes.
+func (b *classBuilder) attribute(name string, info ...[]byte) []byte {
+	data := join(info...)
+	return join(u2(b.utf8(name)), u4(len(data)), data)
+}
+
+func attributes(attributes ...[]byte) []byte {
+	return join(u2(len(attributes)), join(attributes...))
+}
+
+// member returns a field_info or method_info structure.
+func (b *classBuilder) member(flags int, name, descriptor string, attrs ...[]byte) []byte {
+	return join(u2(flags), u2(b.utf8(name)), u2(b.utf8(descriptor)), attributes(attrs...))
+}
+
+type handler struct {
+	start, end, pc int
+	catchType      string
+}
+
+// code returns a Code attribute.
+func (b *classBuilder) code(maxStack, maxLocals int, code []byte, handlers []handler, attrs ...[]byte) []byte {
+	table := u2(len(handlers))
+	for _, h := range handlers {
+		catchType := 0
+		if h.catchType != "" {
+			catchType = b.class(h.catchType)
+		}
+		table = join(table, u2(h.start), u2(h.end), u2(h.pc), u2(catchType))
+	}
+	return b.attribute("Code", u2(maxStack), u2(maxLocals), u4(len(code)), code, table, attributes(attrs...))
+}
+
+type classSpec struct {
+	minor, major int
+	flags        int
+	this, super  string
+	interfaces   []string
+	fields       [][]byte
+	methods      [][]byte
+	attributes   [][]byte
+}
+
+// build returns the class file described by s, with the constant pool
+// holding everything asked of b so far.
+func (b *classBuilder) build(s classSpec) []byte {
+	body := join(u2(s.flags), u2(b.class(s.this)))
+	if s.super != "" {
+		body = join(body, u2(b.class(s.super)))
+	} else {
+		body = join(body, u2(0))
+	}
+	body = join(body, u2(len(s.interfaces)))
+	for _, i := range s.interfaces {
+		body = join(body, u2(b.class(i)))
+	}
+	body = join(body,
+		u2(len(s.fields)), join(s.fields...),
+		u2(len(s.methods)), join(s.methods...),
+		attributes(s.attributes...))
+	return join(u4(0xCAFEBABE), u2(s.minor), u2(s.major), u2(b.count), b.pool.Bytes(), body)
+}
+
+// op returns the opcode with the given mnemonic.
+func op(name string) []byte {
+	for i, o := range opcodes {
+		if o.name == name {
+			return []byte{byte(i)}
+		}
+	}
+	panic("no opcode " + name)
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func u1(v int) []byte {
+	return []byte{byte(v)}
+}
+
+func u2(v int) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func u4(v int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func u8(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// formatChecker makes the format checks of JVMS §4.8 that need the whole
+// class in hand: descriptors, names, combinations of access flags and
+// duplicate members. Problems met while reading the bytes, such as a bad
+// magic number, trailing bytes, constants of the wrong kind or attribute
+// lengths that don't add up, are reported by parseClass instead.
+type formatChecker struct {
+	diagnostics
+	class *Class
+}
+
+// checkClass returns the format checking violations in c.
+func checkClass(c *Class) []Diagnostic {
+	f := &formatChecker{class: c}
+	f.checkConstantPool()
+	f.checkClassFlags()
+	if c.AccessFlags&Module != 0 {
+		f.checkModule()
+	} else {
+		f.checkSuperClass()
+	}
+	f.checkFields()
+	f.checkMethods()
+	sortDiagnostics(f.diagnostics)
+	return f.diagnostics
+}
+
+// checkClassFile parses data and returns everything wrong with it: the
+// problems parseClass meets along the way, those found by checkClass and
+// the first instruction in each method that fails verification. Classes
+// too broken to parse into a Class only get the first.
+func checkClassFile(data []byte) ([]Section, []Diagnostic) {
+	sections, diagnostics := parseClass(data)
+	c, err := ParseClass(bytes.NewReader(data))
+	if err == nil {
+		diagnostics = append(diagnostics, checkClass(&c)...)
+		diagnostics = append(diagnostics, verifyClass(&c)...)
+		sortDiagnostics(diagnostics)
+	}
+	return sections, diagnostics
+}
+
+func (f *formatChecker) utf8(index uint16) (string, bool) {
+	s, err := f.class.utf8At(index)
+	return s, err == nil
+}
+
+// natDescriptor returns the descriptor of the name and type at index.
+func (f *formatChecker) natDescriptor(index uint16) (name, descriptor string, ok bool) {
+	if index == 0 || int(index) > len(f.class.ConstantPoolItems) {
+		return "", "", false
+	}
+	nat, ok := f.class.ConstantPoolItems[index-1].(nameAndType)
+	if !ok {
+		return "", "", false
+	}
+	name, nameOk := f.utf8(nat.nameIndex)
+	descriptor, descriptorOk := f.utf8(nat.descriptorIndex)
+	return name, descriptor, nameOk && descriptorOk
+}
+
+func (f *formatChecker) checkConstantPool() {
+	c := f.class
+	bootstrapMethods, _, bootstrapErr := c.bootstrapMethods()
+	for i, item := range c.ConstantPoolItems {
+		offset := c.constantPoolOffsets[i]
+		index := i + 1
+		switch item := item.(type) {
+		case classInfo:
+			name, ok := f.utf8(item.nameIndex)
+			if ok && !validReferenceName(name) {
+				f.errorf(offset+1, offset+3, "class info #%d has an invalid name %q", index, name)
+			}
+		case nameAndType:
+			descriptor, ok := f.utf8(item.descriptorIndex)
+			if ok && !validFieldDescriptor(descriptor) && !validMethodDescriptor(descriptor) {
+				f.errorf(offset+3, offset+5, "name and type #%d has an invalid descriptor %q", index, descriptor)
+			}
+		case fieldRef:
+			_, descriptor, ok := f.natDescriptor(item.nameAndTypeIndex)
+			if ok && !validFieldDescriptor(descriptor) {
+				f.errorf(offset+3, offset+5, "field ref #%d has %q, which is not a field descriptor", index, descriptor)
+			}
+		case methodRef:
+			f.checkMethodRef(index, offset, item.nameAndTypeIndex)
+		case interfaceMethodRef:
+			f.checkMethodRef(index, offset, item.nameAndTypeIndex)
+		case methodType:
+			descriptor, ok := f.utf8(item.descriptorIndex)
+			if ok && !validMethodDescriptor(descriptor) {
+				f.errorf(offset+1, offset+3, "method type #%d has %q, which is not a method descriptor", index, descriptor)
+			}
+		case dynamicConstant:
+			f.checkBootstrapIndex(dynamicKind, index, offset, item.bootstrapMethodAttrIndex, len(bootstrapMethods), bootstrapErr)
+			_, descriptor, ok := f.natDescriptor(item.nameAndTypeIndex)
+			if ok && !validFieldDescriptor(descriptor) {
+				f.errorf(offset+3, offset+5, "dynamic #%d has %q, which is not a field descriptor", index, descriptor)
+			}
+		case invokeDynamic:
+			f.checkBootstrapIndex(invokeDynamicKind, index, offset, item.bootstrapMethodAttrIndex, len(bootstrapMethods), bootstrapErr)
+		case moduleInfo:
+			if c.AccessFlags&Module == 0 {
+				f.errorf(offset, offset+1, "module #%d can only appear in a module-info class", index)
+			}
+		case packageInfo:
+			if c.AccessFlags&Module == 0 {
+				f.errorf(offset, offset+1, "package #%d can only appear in a module-info class", index)
+			}
+		}
+	}
+}
+
+// checkBootstrapIndex reports a dynamic or invoke dynamic item that refers
+// to a bootstrap method the class doesn't have.
+func (f *formatChecker) checkBootstrapIndex(kind string, index, offset int, bootstrap uint16, count int, err error) {
+	if err != nil {
+		f.errorf(offset+1, offset+3, "%s #%d refers to bootstrap method %d, but %v", kind, index, bootstrap, err)
+	} else if int(bootstrap) >= count {
+		f.errorf(offset+1, offset+3, "%s #%d refers to bootstrap method %d, but there are only %d", kind, index, bootstrap, count)
+	}
+}
+
+func (f *formatChecker) checkMethodRef(index, offset int, natIndex uint16) {
+	name, descriptor, ok := f.natDescriptor(natIndex)
+	if !ok {
+		return
+	}
+	d, err := parseMethodDescriptor(descriptor)
+	switch {
+	case err != nil:
+		f.errorf(offset+3, offset+5, "method ref #%d has %q, which is not a method descriptor", index, descriptor)
+	case strings.HasPrefix(name, "<") && name != "<init>":
+		f.errorf(offset+3, offset+5, "method ref #%d refers to %s, which can't be invoked", index, name)
+	case name == "<init>" && d.result != nil:
+		f.errorf(offset+3, offset+5, "method ref #%d refers to <init> with return type other than void", index)
+	}
+}
+
+func (f *formatChecker) checkClassFlags() {
+	c := f.class
+	flags := c.AccessFlags
+	start, end := c.accessFlagsOffset, c.accessFlagsOffset+2
+	if flags&Module != 0 {
+		if others := flags &^ Module; others != 0 {
+			f.errorf(start, end, "module-info classes can't be %s", flagNames(others, classFlags))
+		}
+	} else if flags&Interface != 0 {
+		if flags&Abstract == 0 {
+			f.errorf(start, end, "interfaces must be abstract")
+		}
+		if conflicts := flags & (Final | Super | Enum); conflicts != 0 {
+			f.errorf(start, end, "interfaces can't be %s", flagNames(conflicts, classFlags))
+		}
+	} else {
+		if flags&Annotation != 0 {
+			f.errorf(start, end, "only interfaces can be annotations")
+		}
+		if flags&Final != 0 && flags&Abstract != 0 {
+			f.errorf(start, end, "classes can't be both final and abstract")
+		}
+	}
+}
+
+func (f *formatChecker) checkSuperClass() {
+	c := f.class
+	start := c.accessFlagsOffset + 4
+	name, _ := c.classNameAt(c.thisClass)
+	if c.superClass == 0 {
+		if name != "java/lang/Object" {
+			f.errorf(start, start+2, "only java/lang/Object can have no super class")
+		}
+		return
+	}
+	super, err := c.classNameAt(c.superClass)
+	if err == nil && c.AccessFlags&Interface != 0 && super != "java/lang/Object" {
+		f.errorf(start, start+2, "the super class of an interface must be java/lang/Object, not %s", super)
+	}
+}
+
+// checkModule makes the checks of JVMS §4.1 that only apply to module-info
+// classes, which declare a module rather than a class.
+func (f *formatChecker) checkModule() {
+	c := f.class
+	if c.MajorVersion < 53 {
+		f.errorf(6, 8, "module-info classes need version 53 or later, not %d", c.MajorVersion)
+	}
+	start := c.accessFlagsOffset + 2
+	if name, err := c.classNameAt(c.thisClass); err == nil && name != "module-info" {
+		f.errorf(start, start+2, "a module's class must be named module-info, not %s", name)
+	}
+	if c.superClass != 0 {
+		f.errorf(start+2, start+4, "module-info classes can't have a super class")
+	}
+	if len(c.interfaces) > 0 {
+		f.errorf(start+4, start+6, "module-info classes can't have interfaces")
+	}
+	if len(c.fields) > 0 {
+		f.errorf(c.fields[0].offset, c.fields[0].offset+2, "module-info classes can't have fields")
+	}
+	if len(c.methods) > 0 {
+		f.errorf(c.methods[0].offset, c.methods[0].offset+2, "module-info classes can't have methods")
+	}
+	for _, a := range c.attributes {
+		if a.name == "Module" {
+			return
+		}
+	}
+	f.errorf(start, start+2, "module-info classes must have a Module attribute")
+}
+
+// checkVisibility reports members with more than one of public, private
+// and protected.
+func (f *formatChecker) checkVisibility(flags accessFlags, start int, kind string) {
+	visibility := flags & (Public | Private | Protected)
+	if visibility&(visibility-1) != 0 {
+		f.errorf(start, start+2, "%s can have only one of public, private and protected", kind)
+	}
+}
+
+func (f *formatChecker) checkFields() {
+	c := f.class
+	seen := map[string]bool{}
+	for _, field := range c.fields {
+		flags := field.accessFlags
+		start := field.offset
+		f.checkVisibility(flags, start, "fields")
+		if flags&Final != 0 && flags&Volatile != 0 {
+			f.errorf(start, start+2, "fields can't be both final and volatile")
+		}
+		if c.AccessFlags&Interface != 0 {
+			if flags&(Public|Static|Final) != Public|Static|Final || flags&^(Public|Static|Final|Synthetic) != 0 {
+				f.errorf(start, start+2, "interface fields must be public, static and final and nothing else but synthetic")
+			}
+		}
+
+		name, nameOk := f.utf8(field.nameIndex)
+		if nameOk && !validUnqualifiedName(name, false) {
+			f.errorf(start+2, start+4, "invalid field name %q", name)
+		}
+		descriptor, descriptorOk := f.utf8(field.descriptorIndex)
+		if descriptorOk {
+			if _, err := parseFieldDescriptor(descriptor); err != nil {
+				f.errorf(start+4, start+6, "invalid field descriptor %q: %v", descriptor, err)
+			}
+		}
+		if nameOk && descriptorOk {
+			key := name + " " + descriptor
+			if seen[key] {
+				f.errorf(start+2, start+6, "duplicate field %s %s", name, descriptor)
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func (f *formatChecker) checkMethods() {
+	c := f.class
+	seen := map[string]bool{}
+	for _, m := range c.methods {
+		start := m.offset
+		name, nameOk := f.utf8(m.nameIndex)
+		descriptor, descriptorOk := f.utf8(m.descriptorIndex)
+		if nameOk {
+			f.checkMethodFlags(m.accessFlags, name, start)
+			if name != "<init>" && name != "<clinit>" && !validUnqualifiedName(name, true) {
+				f.errorf(start+2, start+4, "invalid method name %q", name)
+			}
+		}
+		if descriptorOk {
+			d, err := parseMethodDescriptor(descriptor)
+			slots := d.slots()
+			if m.accessFlags&Static == 0 {
+				slots++
+			}
+			switch {
+			case err != nil:
+				f.errorf(start+4, start+6, "invalid method descriptor %q: %v", descriptor, err)
+			case slots > 255:
+				f.errorf(start+4, start+6, "method parameters take %d slots, more than the limit of 255", slots)
+			case (name == "<init>" || name == "<clinit>") && d.result != nil:
+				f.errorf(start+4, start+6, "%s must return void", name)
+			}
+		}
+		if nameOk && descriptorOk {
+			key := name + descriptor
+			if seen[key] {
+				f.errorf(start+2, start+6, "duplicate method %s%s", name, descriptor)
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func (f *formatChecker) checkMethodFlags(flags accessFlags, name string, start int) {
+	c := f.class
+	end := start + 2
+	if name == "<clinit>" {
+		// Only ACC_STATIC and ACC_STRICT matter, and the rest are ignored.
+		return
+	}
+	f.checkVisibility(flags, start, "methods")
+	if name == "<init>" {
+		if c.AccessFlags&Interface != 0 {
+			f.errorf(start+2, start+4, "interfaces can't have instance initialisers")
+		}
+		if others := flags &^ (Public | Private | Protected | Varargs | Strict | Synthetic); others != 0 {
+			f.errorf(start, end, "<init> can't be %s", flagNames(others, methodFlags))
+		}
+		return
+	}
+	if c.AccessFlags&Interface != 0 {
+		if c.MajorVersion < 52 {
+			if flags&(Public|Abstract) != Public|Abstract {
+				f.errorf(start, end, "interface methods must be public and abstract before class file version 52")
+			}
+		} else {
+			if flags&(Public|Private) == 0 {
+				f.errorf(start, end, "interface methods must be public or private")
+			}
+			if conflicts := flags & (Protected | Final | Synchronized | Native); conflicts != 0 {
+				f.errorf(start, end, "interface methods can't be %s", flagNames(conflicts, methodFlags))
+			}
+		}
+	}
+	if flags&Abstract != 0 {
+		forbidden := accessFlags(Private | Static | Final | Synchronized | Native)
+		// ACC_STRICT was dropped in Java 17, class file version 61.
+		if c.MajorVersion >= 46 && c.MajorVersion < 61 {
+			forbidden |= Strict
+		}
+		if conflicts := flags & forbidden; conflicts != 0 {
+			f.errorf(start, end, "abstract methods can't be %s", flagNames(conflicts, methodFlags))
+		}
+	}
+}
+
+// flagNames lists the names of the flags set in flags, e.g. "final and
+// abstract".
+func flagNames(flags accessFlags, descriptions []flagDescription) string {
+	var names []string
+	for _, d := range descriptions {
+		if flags&d.flag != 0 {
+			names = append(names, d.name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("0x%04x", uint16(flags))
+	}
+	return joinKinds(names)
+}
+
+// validUnqualifiedName checks a field or method name as described in JVMS
+// §4.2.2.
+func validUnqualifiedName(name string, method bool) bool {
+	if name == "" || strings.ContainsAny(name, ".;[/") {
+		return false
+	}
+	return !method || !strings.ContainsAny(name, "<>")
+}
+
+// validClassName checks a class name in the internal form of JVMS §4.2.1,
+// e.g. "java/lang/Object".
+func validClassName(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if !validUnqualifiedName(part, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// validReferenceName reports whether name is a class name or the
+// descriptor of an array, as found in class info constants.
+func validReferenceName(name string) bool {
+	if strings.HasPrefix(name, "[") {
+		return validFieldDescriptor(name)
+	}
+	return validClassName(name)
+}
+
+func validFieldDescriptor(s string) bool {
+	_, err := parseFieldDescriptor(s)
+	return err == nil
+}
+
+func validMethodDescriptor(s string) bool {
+	_, err := parseMethodDescriptor(s)
+	return err == nil
+}
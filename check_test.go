@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCommand isn't a test but the icf command itself, run by runCommand
+// so that exit codes can be checked.
+func TestCommand(t *testing.T) {
+	if os.Getenv("ICF_COMMAND") != "1" {
+		return
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	os.Args = append([]string{"icf"}, args...)
+	main()
+	os.Exit(0)
+}
+
+// runCommand runs icf with args and returns what it printed and the code
+// it exited with.
+func runCommand(t *testing.T, args ...string) (string, int) {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestCommand$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), "ICF_COMMAND=1")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return out.String(), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), 0
+}
+
+// checkedClass returns a class with a field and a constructor, changed by
+// edit before it is built.
+func checkedClass(edit func(b *classBuilder, s *classSpec)) []byte {
+	b := newClassBuilder()
+	s := classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "C", super: "java/lang/Object",
+		fields:  [][]byte{b.member(0x0002, "f", "I")},
+		methods: [][]byte{b.constructor("java/lang/Object")},
+	}
+	if edit != nil {
+		edit(b, &s)
+	}
+	return b.build(s)
+}
+
+func TestCheck(t *testing.T) {
+	valid := checkedClass(nil)
+	tests := []struct {
+		name  string
+		class []byte
+		want  []string
+		exit  int
+	}{
+		{
+			name:  "valid",
+			class: valid,
+		},
+		{
+			name:  "bad magic number",
+			class: append([]byte{0xca, 0xfe, 0xd0, 0x0d}, valid[4:]...),
+			want:  []string{"0-4: error: magic number is 0xcafed00d, expected 0xCAFEBABE"},
+			exit:  1,
+		},
+		{
+			name:  "trailing bytes",
+			class: append(append([]byte(nil), valid...), 0, 0),
+			want:  []string{"132-134: error: 2 bytes after the end of the class"},
+			exit:  1,
+		},
+		{
+			name: "index of the wrong kind",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				b.constant("field ref with a UTF-8 class", false, u1(9), u2(b.utf8("C")), u2(b.nameAndType("f", "I")))
+			}),
+			want: []string{"82-84: error: constant pool index #10: expected class info, found UTF-8 string"},
+			exit: 1,
+		},
+		{
+			name: "bad field descriptor",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.fields = [][]byte{b.member(0x0002, "f", "Ljava/lang/String")}
+			}),
+			want: []string{`113-115: error: invalid field descriptor "Ljava/lang/String": the class name at offset 0 is missing its ';'`},
+			exit: 1,
+		},
+		{
+			name: "bad method descriptor",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.methods = append(s.methods, b.member(0x0000, "m", "(I", b.code(0, 1, op("return"), nil)))
+			}),
+			want: []string{`143-145: error: invalid method descriptor "(I": the parameters are missing their closing ')'`},
+			exit: 1,
+		},
+		{
+			name: "public and private method",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.methods = append(s.methods, b.member(0x0003, "m", "()V", b.code(0, 1, op("return"), nil)))
+			}),
+			want: []string{"134-136: error: methods can have only one of public, private and protected"},
+			exit: 1,
+		},
+		{
+			name: "final volatile field",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.fields = [][]byte{b.member(0x0050, "f", "I")}
+			}),
+			want: []string{"89-91: error: fields can't be both final and volatile"},
+			exit: 1,
+		},
+		{
+			name: "interface that isn't abstract",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.flags = 0x0201
+				s.fields = nil
+				s.methods = nil
+			}),
+			want: []string{"79-81: error: interfaces must be abstract"},
+			exit: 1,
+		},
+		{
+			name: "duplicate field",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.fields = append(s.fields, b.member(0x0001, "f", "I"))
+			}),
+			want: []string{"99-103: error: duplicate field f I"},
+			exit: 1,
+		},
+		{
+			name: "duplicate method",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.methods = append(s.methods, s.methods[0])
+			}),
+			want: []string{"132-136: error: duplicate method <init>()V"},
+			exit: 1,
+		},
+		{
+			name: "attribute length mismatch",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.fields = [][]byte{b.member(0x0018, "f", "I",
+					b.attribute("ConstantValue", u2(b.integer(1)), u1(0)))}
+			}),
+			want: []string{"124-127: error: ConstantValue attribute does not fit its length of 3 bytes"},
+			exit: 1,
+		},
+		{
+			name: "bad signature",
+			class: checkedClass(func(b *classBuilder, s *classSpec) {
+				s.fields = [][]byte{b.member(0x0002, "f", "I", b.attribute("Signature", u2(b.utf8("Ljava/util/List<"))))}
+			}),
+			want: []string{`134-136: warning: invalid field signature "Ljava/util/List<": expected a reference type but the signature ended at offset 16`},
+		},
+	}
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, test := range tests {
+		path := filepath.Join(dir, strings.Replace(test.name, " ", "-", -1)+".class")
+		if err := ioutil.WriteFile(path, test.class, 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, exit := runCommand(t, "-check", path)
+		var want string
+		for _, line := range test.want {
+			want += path + ":" + line + "\n"
+		}
+		if out != want {
+			t.Errorf("%s: printed\n%s\nwant\n%s", test.name, out, want)
+		}
+		if exit != test.exit {
+			t.Errorf("%s: exit code %d, want %d", test.name, exit, test.exit)
+		}
+	}
+}
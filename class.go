@@ -6,8 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
+	"sort"
+	"strings"
 )
 
 type ConstantPoolItem interface {
@@ -18,16 +19,29 @@ type ConstantPoolItem interface {
 type accessFlags uint16
 
 const (
-	Public     accessFlags = 0x0001
-	Static                 = 0x0008
-	Final                  = 0x0010
-	Super                  = 0x0020
-	Native                 = 0x0100
-	Interface              = 0x0200
-	Abstract               = 0x0400
-	Synthetic              = 0x1000
-	Annotation             = 0x2000
-	Enum                   = 0x4000
+	Public       accessFlags = 0x0001
+	Private                  = 0x0002
+	Protected                = 0x0004
+	Static                   = 0x0008
+	Final                    = 0x0010
+	Super                    = 0x0020
+	Synchronized             = 0x0020
+	Open                     = 0x0020
+	Transitive               = 0x0020
+	Volatile                 = 0x0040
+	Bridge                   = 0x0040
+	StaticPhase              = 0x0040
+	Transient                = 0x0080
+	Varargs                  = 0x0080
+	Native                   = 0x0100
+	Interface                = 0x0200
+	Abstract                 = 0x0400
+	Strict                   = 0x0800
+	Synthetic                = 0x1000
+	Annotation               = 0x2000
+	Enum                     = 0x4000
+	Module                   = 0x8000
+	Mandated                 = 0x8000
 )
 
 type Code struct {
@@ -35,6 +49,10 @@ type Code struct {
 	maxLocals         uint16
 	Instructions      []byte
 	ExceptionHandlers []ExceptionHandler
+	attributes        []attribute
+
+	// offset is where the instructions start in the class file.
+	offset int
 }
 
 type Class struct {
@@ -48,7 +66,21 @@ type Class struct {
 	interfaces        []uint16
 	fields            []field
 	methods           []Method
+	attributes        []attribute
 	initialised       bool
+
+	// Where things were found in the class file, so that problems with
+	// them can be pointed at.
+	constantPoolOffsets []int
+	accessFlagsOffset   int
+}
+
+// attribute is an attribute whose contents we keep undecoded. offset is
+// where info starts in the class file.
+type attribute struct {
+	name   string
+	offset int
+	info   []byte
 }
 
 type ExceptionHandler struct {
@@ -59,15 +91,14 @@ type ExceptionHandler struct {
 	Class     string
 }
 
-func parseCode(cr byteParser, length uint32, method *Method) {
+// parseCode reads the contents of a Code attribute of a method of class.
+func parseCode(cr *byteParser, class *Class) Code {
 	var c Code
 	c.maxStack = cr.u2()
 	c.maxLocals = cr.u2()
 	codeLength := cr.u4()
-	c.Instructions = make([]byte, codeLength)
-	for k := 0; k < len(c.Instructions); k++ {
-		c.Instructions[k] = cr.u1()
-	}
+	c.offset = cr.pos
+	c.Instructions = cr.readBytes(codeLength)
 	numExceptionHandlers := cr.u2()
 	c.ExceptionHandlers = make([]ExceptionHandler, numExceptionHandlers)
 	for i := 0; i < len(c.ExceptionHandlers); i++ {
@@ -77,149 +108,274 @@ func parseCode(cr byteParser, length uint32, method *Method) {
 		catchType := cr.u2()
 		if catchType != 0 {
 			c.ExceptionHandlers[i].CatchType = catchType
-			info := method.class.ConstantPoolItems[catchType-1].(classInfo)
-			name := method.class.ConstantPoolItems[info.nameIndex-1].(utf8String)
-			c.ExceptionHandlers[i].Class = name.contents
+			name, err := class.classNameAt(catchType)
+			cr.fail(err)
+			c.ExceptionHandlers[i].Class = name
 		}
 	}
-	for k := uint32(8) + codeLength + 2 + uint32(numExceptionHandlers)*8; k < length; k++ {
-		_ = cr.u1()
-	}
-	method.Code = c
+	c.attributes = parseAttributes(class, cr)
+	return c
 }
 
 type byteParser struct {
 	reader io.Reader
 	err    error
+
+	// pos is how many bytes have been read so far.
+	pos int
 }
 
-func newByteParser(byteSlice []byte, start int) byteParser {
-	return byteParser{
+func newByteParser(byteSlice []byte, start int) *byteParser {
+	if start > len(byteSlice) {
+		start = len(byteSlice)
+	}
+	return &byteParser{
 		reader: bytes.NewReader(byteSlice[start:]),
 	}
 }
 
-func (r byteParser) u8() uint64 {
+func (r *byteParser) u8() uint64 {
 	if r.err != nil {
 		return 0
 	}
 	var x uint64
 	r.err = binary.Read(r.reader, binary.BigEndian, &x)
+	if r.err == nil {
+		r.pos += 8
+	}
 	return x
 }
 
-func (r byteParser) u4() uint32 {
+func (r *byteParser) u4() uint32 {
 	if r.err != nil {
 		return 0
 	}
 	var x uint32
 	r.err = binary.Read(r.reader, binary.BigEndian, &x)
+	if r.err == nil {
+		r.pos += 4
+	}
 	return x
 }
 
-func (r byteParser) u2() uint16 {
+func (r *byteParser) u2() uint16 {
 	if r.err != nil {
 		return 0
 	}
 	var x uint16
 	r.err = binary.Read(r.reader, binary.BigEndian, &x)
+	if r.err == nil {
+		r.pos += 2
+	}
 	return x
 }
 
-func (r byteParser) u1() uint8 {
+func (r *byteParser) u1() uint8 {
 	if r.err != nil {
 		return 0
 	}
 	var x uint8
 	r.err = binary.Read(r.reader, binary.BigEndian, &x)
+	if r.err == nil {
+		r.pos += 1
+	}
 	return x
 }
 
-func newClassDecoder(r io.Reader) byteParser {
-	cr := byteParser{r, nil}
+// readBytes reads n bytes, growing the buffer as data arrives so that a
+// bogus length can't make us allocate more than the input holds.
+func (r *byteParser) readBytes(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	_, r.err = io.CopyN(&buf, r.reader, int64(n))
+	r.pos += buf.Len()
+	return buf.Bytes()
+}
+
+// fail records err unless an earlier error has already been recorded.
+func (r *byteParser) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func newClassDecoder(r io.Reader) *byteParser {
+	cr := &byteParser{reader: r}
 	magic := cr.u4()
 	if magic != 0xCAFEBABE {
-		cr.err = errors.New("Bad magic number")
+		cr.fail(errors.New("Bad magic number"))
 	}
 	return cr
 }
 
+var constantPoolParsers = map[uint8]func(*Class, *byteParser) ConstantPoolItem{
+	1:  parseUTF8String,
+	3:  parseIntConstant,
+	4:  parseFloatConstant,
+	5:  parseLongConstant,
+	6:  parseDoubleConstant,
+	7:  parseClassInfo,
+	8:  parseStringConstant,
+	9:  parseFieldRef,
+	10: parseMethodRef,
+	11: parseInterfaceMethodRef,
+	12: parseNameAndType,
+	15: parseMethodHandle,
+	16: parseMethodType,
+	17: parseDynamicConstant,
+	18: parseInvokeDynamic,
+	19: parseModuleInfo,
+	20: parsePackageInfo,
+}
+
+func parseConstantPool(c *Class, cr *byteParser, count int) []ConstantPoolItem {
+	items := make([]ConstantPoolItem, 0, count)
+	for len(items) < count && cr.err == nil {
+		offset := cr.pos
+		tag := cr.u1()
+		parse, ok := constantPoolParsers[tag]
+		if !ok {
+			cr.fail(fmt.Errorf("unknown constant pool tag %d at index %d", tag, len(items)+1))
+			break
+		}
+		item := parse(c, cr)
+		if cr.err != nil {
+			break
+		}
+		c.constantPoolOffsets = append(c.constantPoolOffsets, offset)
+		items = append(items, item)
+		// Longs and doubles take up two entries in the pool.
+		if tag == 5 || tag == 6 {
+			items = append(items, WideConstantPart2{})
+			c.constantPoolOffsets = append(c.constantPoolOffsets, c.constantPoolOffsets[len(c.constantPoolOffsets)-1])
+		}
+	}
+	return items
+}
+
+func parseAttributes(c *Class, cr *byteParser) []attribute {
+	count := cr.u2()
+	var attributes []attribute
+	for i := uint16(0); i < count && cr.err == nil; i++ {
+		nameIndex := cr.u2()
+		length := cr.u4()
+		name, err := c.utf8At(nameIndex)
+		cr.fail(err)
+		offset := cr.pos
+		info := cr.readBytes(length)
+		if cr.err == nil {
+			attributes = append(attributes, attribute{name, offset, info})
+		}
+	}
+	return attributes
+}
+
+// ParseClass reads a class file. If it is malformed, the error comes with
+// what was read before the problem: every table holds just the entries
+// that were read in full, so callers can use each one, and those after
+// the problem are empty.
 func ParseClass(r io.Reader) (c Class, err error) {
 	cr := newClassDecoder(r)
+	c.magic = 0xCAFEBABE
 	c.MinorVersion = cr.u2() // minor version
 	c.MajorVersion = cr.u2() // major version
 	cpc := cr.u2()
-	//constantPoolCount := cpc - 1
 	if cpc != 0 {
-		//c.ConstantPoolItems = parseConstantPool(&c, cr, constantPoolCount)
+		c.ConstantPoolItems = parseConstantPool(&c, cr, int(cpc)-1)
 	}
 
+	c.accessFlagsOffset = cr.pos
 	c.AccessFlags = accessFlags(cr.u2())
 	c.thisClass = cr.u2()
 	c.superClass = cr.u2()
 
 	interfacesCount := cr.u2()
-	c.interfaces = make([]uint16, interfacesCount)
-	for i := uint16(0); i < interfacesCount; i++ {
-		c.interfaces[i] = cr.u2()
+	c.interfaces = make([]uint16, 0, interfacesCount)
+	for i := uint16(0); i < interfacesCount && cr.err == nil; i++ {
+		if index := cr.u2(); cr.err == nil {
+			c.interfaces = append(c.interfaces, index)
+		}
 	}
 
 	fieldsCount := cr.u2()
-	c.fields = make([]field, fieldsCount)
-	for i := uint16(0); i < fieldsCount; i++ {
-		c.fields[i].accessFlags = accessFlags(cr.u2())
-		c.fields[i].nameIndex = cr.u2()
-		c.fields[i].descriptorIndex = cr.u2()
-
-		attrCount := cr.u2()
-		for j := uint16(0); j < attrCount; j++ {
-			_ = cr.u2()
-			length := cr.u4()
-			for k := uint32(0); k < length; k++ {
-				_ = cr.u1() // throw away bytes
-			}
+	c.fields = make([]field, 0, fieldsCount)
+	for i := uint16(0); i < fieldsCount && cr.err == nil; i++ {
+		var f field
+		f.offset = cr.pos
+		f.accessFlags = accessFlags(cr.u2())
+		f.nameIndex = cr.u2()
+		f.descriptorIndex = cr.u2()
+		f.attributes = parseAttributes(&c, cr)
+		if cr.err == nil {
+			c.fields = append(c.fields, f)
 		}
 	}
 
 	methodsCount := cr.u2()
-	c.methods = make([]Method, methodsCount)
-	for i := uint16(0); i < methodsCount; i++ {
-		c.methods[i].class = &c
-		c.methods[i].accessFlags = accessFlags(cr.u2())
-		c.methods[i].nameIndex = cr.u2()
-		c.methods[i].descriptorIndex = cr.u2()
-
-		var sig string
-		sig = c.ConstantPoolItems[c.methods[i].descriptorIndex-1].(utf8String).contents
-		c.methods[i].Signiture = parseSigniture(sig)
-		c.methods[i].RawSigniture = sig
-
-		attrCount := cr.u2()
-		for j := uint16(0); j < attrCount; j++ {
-			name := cr.u2()
-			length := cr.u4()
-			actualName := (c.ConstantPoolItems[name-1]).(utf8String)
-			if actualName.contents == "Code" {
-				parseCode(cr, length, &c.methods[i])
-			} else {
-				for k := uint32(0); k < length; k++ {
-					_ = cr.u1() // throw away bytes
-				}
+	c.methods = make([]Method, 0, methodsCount)
+	for i := uint16(0); i < methodsCount && cr.err == nil; i++ {
+		var m Method
+		m.class = &c
+		m.offset = cr.pos
+		m.accessFlags = accessFlags(cr.u2())
+		m.nameIndex = cr.u2()
+		m.descriptorIndex = cr.u2()
+
+		sig, err := c.utf8At(m.descriptorIndex)
+		cr.fail(err)
+		if err == nil {
+			// Invalid descriptors are left for checkClass to report.
+			if d, err := parseMethodDescriptor(sig); err == nil {
+				m.Signiture = d
+				m.RawSigniture = sig
 			}
 		}
-	}
-	attrCount := cr.u2()
-	for j := uint16(0); j < attrCount; j++ {
-		_ = cr.u2()
-		length := cr.u4()
-		for k := uint32(0); k < length; k++ {
-			_ = cr.u1() // throw away bytes
+
+		m.attributes = parseAttributes(&c, cr)
+		for _, a := range m.attributes {
+			if a.name == "Code" {
+				code := newByteParser(a.info, 0)
+				code.pos = a.offset
+				m.Code = parseCode(code, &c)
+				cr.fail(code.err)
+			}
+		}
+		if cr.err == nil {
+			c.methods = append(c.methods, m)
 		}
 	}
+	c.attributes = parseAttributes(&c, cr)
 
 	return c, cr.err
 }
 
+// utf8At returns the UTF-8 string at index, or an error if there isn't one.
+func (c *Class) utf8At(index uint16) (string, error) {
+	if index == 0 || int(index) > len(c.ConstantPoolItems) {
+		return "", fmt.Errorf("constant pool index %d is out of range", index)
+	}
+	u, ok := c.ConstantPoolItems[index-1].(utf8String)
+	if !ok {
+		return "", fmt.Errorf("constant pool index %d is %v, not a UTF-8 string", index, c.ConstantPoolItems[index-1])
+	}
+	return u.contents, nil
+}
+
+// classNameAt returns the name of the class info at index, or an error if
+// there isn't one.
+func (c *Class) classNameAt(index uint16) (string, error) {
+	if index == 0 || int(index) > len(c.ConstantPoolItems) {
+		return "", fmt.Errorf("constant pool index %d is out of range", index)
+	}
+	info, ok := c.ConstantPoolItems[index-1].(classInfo)
+	if !ok {
+		return "", fmt.Errorf("constant pool index %d is %v, not a class", index, c.ConstantPoolItems[index-1])
+	}
+	return c.utf8At(info.nameIndex)
+}
+
 func (c *Class) hasMethodCalled(name string) bool {
 	for _, m := range c.methods {
 		n := c.ConstantPoolItems[m.nameIndex-1].(utf8String).contents
@@ -354,58 +510,6 @@ func (m fieldRef) className() string {
 	return c
 }
 
-func parseSigniture(sig string) []string {
-	s := make([]string, 0)
-	className := false
-	for _, c := range sig {
-		//TODO: save the name of the class. Maybe
-		if className {
-			if c == ';' {
-				className = false
-			}
-			continue
-		}
-		switch c {
-		case '(', ')', '[':
-			break
-		case 'B':
-			s = append(s, "byte")
-			break
-		case 'C':
-			s = append(s, "char")
-			break
-		case 'D':
-			s = append(s, "double")
-			break
-		case 'F':
-			s = append(s, "float")
-			break
-		case 'I':
-			s = append(s, "int")
-			break
-		case 'J':
-			s = append(s, "long")
-			break
-		case 'S':
-			s = append(s, "short")
-			break
-		case 'Z':
-			s = append(s, "boolean")
-			break
-		case 'V':
-			s = append(s, "void")
-			break
-		case 'L':
-			s = append(s, "reference")
-			className = true
-			break
-		default:
-			log.Panicf("Can't parse signiture: %s", sig)
-		}
-	}
-	return s
-}
-
 type methodType struct {
 	descriptorIndex uint16
 }
@@ -416,7 +520,7 @@ func (n methodType) String() string {
 	return fmt.Sprintf("(MethodType)")
 }
 
-func parseMethodType(c *Class, cr byteParser) ConstantPoolItem {
+func parseMethodType(c *Class, cr *byteParser) ConstantPoolItem {
 	return methodType{cr.u2()}
 }
 
@@ -431,7 +535,7 @@ func (n methodHandle) String() string {
 	return fmt.Sprintf("(MethodHandle)")
 }
 
-func parseMethodHandle(c *Class, cr byteParser) ConstantPoolItem {
+func parseMethodHandle(c *Class, cr *byteParser) ConstantPoolItem {
 	return methodHandle{cr.u1(), cr.u2()}
 }
 
@@ -446,10 +550,57 @@ func (n invokeDynamic) String() string {
 	return fmt.Sprintf("(InvokeDynamic) bootstrapMethodAttrIndex: %d, nameAndType: %d", n.bootstrapMethodAttrIndex, n.nameAndTypeIndex)
 }
 
-func parseInvokeDynamic(c *Class, cr byteParser) ConstantPoolItem {
+func parseInvokeDynamic(c *Class, cr *byteParser) ConstantPoolItem {
 	return invokeDynamic{cr.u2(), cr.u2()}
 }
 
+// dynamicConstant is a constant whose value is computed by a bootstrap
+// method the first time it is loaded.
+type dynamicConstant struct {
+	bootstrapMethodAttrIndex uint16
+	nameAndTypeIndex         uint16
+}
+
+func (_ dynamicConstant) isConstantPoolItem() {}
+
+func (n dynamicConstant) String() string {
+	return fmt.Sprintf("(Dynamic) bootstrapMethodAttrIndex: %d, nameAndType: %d", n.bootstrapMethodAttrIndex, n.nameAndTypeIndex)
+}
+
+func parseDynamicConstant(c *Class, cr *byteParser) ConstantPoolItem {
+	return dynamicConstant{cr.u2(), cr.u2()}
+}
+
+// moduleInfo names a module, and only appears in module-info classes.
+type moduleInfo struct {
+	nameIndex uint16
+}
+
+func (_ moduleInfo) isConstantPoolItem() {}
+
+func (m moduleInfo) String() string {
+	return fmt.Sprintf("(Module) name: %d", m.nameIndex)
+}
+
+func parseModuleInfo(c *Class, cr *byteParser) ConstantPoolItem {
+	return moduleInfo{cr.u2()}
+}
+
+// packageInfo names a package exported or opened by a module.
+type packageInfo struct {
+	nameIndex uint16
+}
+
+func (_ packageInfo) isConstantPoolItem() {}
+
+func (p packageInfo) String() string {
+	return fmt.Sprintf("(Package) name: %d", p.nameIndex)
+}
+
+func parsePackageInfo(c *Class, cr *byteParser) ConstantPoolItem {
+	return packageInfo{cr.u2()}
+}
+
 type nameAndType struct {
 	nameIndex       uint16
 	descriptorIndex uint16
@@ -461,7 +612,7 @@ func (n nameAndType) String() string {
 	return fmt.Sprintf("(NameAndType) name: %d, type: %d", n.nameIndex, n.descriptorIndex)
 }
 
-func parseNameAndType(c *Class, cr byteParser) ConstantPoolItem {
+func parseNameAndType(c *Class, cr *byteParser) ConstantPoolItem {
 	nameIndex := cr.u2()
 	descriptorIndex := cr.u2()
 	return nameAndType{nameIndex, descriptorIndex}
@@ -477,13 +628,13 @@ func (u utf8String) String() string {
 	return "(String) \"" + u.contents + "\""
 }
 
-func parseUTF8String(c *Class, cr byteParser) ConstantPoolItem {
+func parseUTF8String(c *Class, cr *byteParser) ConstantPoolItem {
 	length := cr.u2()
 	bytes := make([]byte, length)
 	for i := uint16(0); i < length; i++ {
 		bytes[i] = cr.u1()
 	}
-	return utf8String{string(bytes)}
+	return utf8String{javaString(decodeModifiedUTF8(bytes, nil))}
 }
 
 type classInfo struct {
@@ -497,7 +648,7 @@ func (c classInfo) String() string {
 	return fmt.Sprintf("(ClassInfo) %d", c.nameIndex)
 }
 
-func parseClassInfo(c *Class, cr byteParser) ConstantPoolItem {
+func parseClassInfo(c *Class, cr *byteParser) ConstantPoolItem {
 	nameIndex := cr.u2()
 	return classInfo{c, nameIndex}
 }
@@ -514,7 +665,7 @@ func (m methodRef) String() string {
 	return fmt.Sprintf("(MethodRef) class: %d, name: %d", m.classIndex, m.nameAndTypeIndex)
 }
 
-func parseMethodRef(c *Class, cr byteParser) ConstantPoolItem {
+func parseMethodRef(c *Class, cr *byteParser) ConstantPoolItem {
 	classIndex := cr.u2()
 	nameAndTypeIndex := cr.u2()
 	return methodRef{c, classIndex, nameAndTypeIndex}
@@ -532,7 +683,7 @@ func (i interfaceMethodRef) String() string {
 	return fmt.Sprintf("(InterfaceMethodRef) class: %d, name: %d", i.classIndex, i.nameAndTypeIndex)
 }
 
-func parseInterfaceMethodRef(c *Class, cr byteParser) ConstantPoolItem {
+func parseInterfaceMethodRef(c *Class, cr *byteParser) ConstantPoolItem {
 	classIndex := cr.u2()
 	nameAndTypeIndex := cr.u2()
 	return interfaceMethodRef{c, classIndex, nameAndTypeIndex}
@@ -550,7 +701,7 @@ func (f fieldRef) String() string {
 	return fmt.Sprintf("(FieldRef) class: %d, name %d", f.classIndex, f.nameAndTypeIndex)
 }
 
-func parseFieldRef(c *Class, cr byteParser) ConstantPoolItem {
+func parseFieldRef(c *Class, cr *byteParser) ConstantPoolItem {
 	classIndex := cr.u2()
 	nameAndTypeIndex := cr.u2()
 	return fieldRef{c, classIndex, nameAndTypeIndex}
@@ -566,7 +717,7 @@ func (s stringConstant) String() string {
 	return fmt.Sprintf("(StringConst) index: %d", s.utf8Index)
 }
 
-func parseStringConstant(c *Class, cr byteParser) ConstantPoolItem {
+func parseStringConstant(c *Class, cr *byteParser) ConstantPoolItem {
 	utf8Index := cr.u2()
 	return stringConstant{utf8Index}
 }
@@ -581,7 +732,7 @@ func (i intConstant) String() string {
 	return fmt.Sprintf("(Int) %d", i.value)
 }
 
-func parseIntConstant(c *Class, cr byteParser) ConstantPoolItem {
+func parseIntConstant(c *Class, cr *byteParser) ConstantPoolItem {
 	i := int32(cr.u4())
 	return intConstant{i}
 }
@@ -596,7 +747,7 @@ func (l longConstant) String() string {
 	return fmt.Sprintf("(Long) %d", l.value)
 }
 
-func parseLongConstant(c *Class, cr byteParser) ConstantPoolItem {
+func parseLongConstant(c *Class, cr *byteParser) ConstantPoolItem {
 	long := int64(cr.u4()) << 32
 	long += int64(cr.u4())
 	return longConstant{long}
@@ -621,7 +772,7 @@ func (f floatConstant) String() string {
 	return fmt.Sprintf("(Float) %f", f.value)
 }
 
-func parseFloatConstant(c *Class, cr byteParser) ConstantPoolItem {
+func parseFloatConstant(c *Class, cr *byteParser) ConstantPoolItem {
 	bits := cr.u4()
 	return floatConstant{math.Float32frombits(bits)}
 }
@@ -636,25 +787,29 @@ func (f doubleConstant) String() string {
 	return fmt.Sprintf("(Double) %v", f.value)
 }
 
-func parseDoubleConstant(c *Class, cr byteParser) ConstantPoolItem {
+func parseDoubleConstant(c *Class, cr *byteParser) ConstantPoolItem {
 	bits := cr.u8()
 	return doubleConstant{math.Float64frombits(bits)}
 }
 
 type field struct {
+	offset          int
 	accessFlags     accessFlags
 	nameIndex       uint16
 	descriptorIndex uint16
+	attributes      []attribute
 	value           interface{}
 }
 
 type Method struct {
 	class           *Class
-	Signiture       []string
+	offset          int
+	Signiture       methodDescriptor
 	RawSigniture    string
 	accessFlags     accessFlags
 	nameIndex       uint16
 	descriptorIndex uint16
+	attributes      []attribute
 	Code            Code
 }
 
@@ -675,91 +830,159 @@ func (m *Method) Native() bool {
 }
 
 func (m *Method) numArgs() int {
-	return len(m.Signiture) - 1
+	return len(m.Signiture.parameters)
 }
 
-func (m *Method) Sig() []string {
+func (m *Method) Sig() methodDescriptor {
 	return m.Signiture
 }
 
-var globalId int
+// sectionParser holds the state of a single parseClass call, so that
+// many classes can be parsed at once.
+type sectionParser struct {
+	id int
+
+	// constantPool holds the items read by parseConstantPool so that
+	// later stages can look up the names their indexes refer to.
+	constantPool []ConstantPoolItem
+
+	// constantPoolSections maps constant pool indexes to the id of the
+	// section describing that item, so that sections holding an index
+	// can link to it.
+	constantPoolSections map[uint16]int
+
+	// instructionSections maps the offsets of the instructions in the
+	// Code attribute being parsed to the ids of their sections, so that
+	// its StackMapTable frames can link to them.
+	instructionSections map[int]int
+
+	// bootstrapMethods are the entries of the BootstrapMethods attribute,
+	// read before the rest of the class so that the constant pool can
+	// refer to them. bootstrapSections holds the ids reserved for their
+	// sections and bootstrapOffset is where the attribute's info starts.
+	bootstrapMethods  []bootstrapMethod
+	bootstrapSections []int
+	bootstrapOffset   int
+
+	// memberKind and memberName say which field, method or record
+	// component the attributes being read belong to, for attributes such
+	// as Signature whose meaning depends on it. memberKind is "" for the
+	// class's own attributes.
+	memberKind, memberName string
+
+	// describingBootstrap is set while the arguments of a bootstrap
+	// method are being rendered, as they can lead back to it.
+	describingBootstrap bool
+
+	// lineNumbers are the entries of the LineNumberTables found in the
+	// Code attribute being parsed.
+	lineNumbers []lineNumber
+
+	// diagnostics are the problems found so far. Once abandoned is set
+	// the rest of the class can't be located, so later stages are
+	// skipped.
+	diagnostics
+	abandoned bool
+}
+
+func newSectionParser() *sectionParser {
+	return &sectionParser{
+		constantPoolSections: map[uint16]int{},
+
+		// Ids start at one so that a zero Target means there is none.
+		id: 1,
+	}
+}
 
-func nextId() (id int) {
-	id = globalId
-	globalId++
+func (p *sectionParser) nextId() (id int) {
+	id = p.id
+	p.id++
 	return
 }
 
-func parseMagicNumber(bytes []byte, index int) (next int, section *Section) {
+func (p *sectionParser) parseMagicNumber(bytes []byte, index int) (next int, section *Section) {
 	next = index
-	if len(bytes) >= 4 {
-		magic := newByteParser(bytes, index).u4()
-		if magic == 0xCAFEBABE {
-			next += 4
-			section = &Section{
-				Id:         nextId(),
-				StartIndex: index,
-				EndIndex:   next,
-				Name:       "magic number",
-			}
-		}
+	if p.truncated(bytes, index, 4, "magic number") {
+		return
+	}
+	magic := newByteParser(bytes, index).u4()
+	next += 4
+	name := "magic number"
+	if magic != 0xCAFEBABE {
+		// Carry on regardless; the rest may still be recognisable.
+		name = fmt.Sprintf("magic number: 0x%08x", magic)
+		p.errorf(index, next, "magic number is 0x%08x, expected 0xCAFEBABE", magic)
+	}
+	section = &Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       name,
 	}
 	return
 }
 
-func parseVersion(bytes []byte, index int) (next int, section *Section) {
+func (p *sectionParser) parseVersion(bytes []byte, index int) (next int, section *Section) {
 	next = index
-	if len(bytes) >= 4 {
-		parser := newByteParser(bytes, index)
-		minorVersion := parser.u2()
-		majorVersion := parser.u2()
-		if parser.err == nil {
-			next += 4
-			minorVersionSection := Section{
-				Id:         nextId(),
-				StartIndex: index,
-				EndIndex:   index + 2,
-				Name:       fmt.Sprintf("minor version: %d", minorVersion),
-			}
-			majorVersionSection := Section{
-				Id:         nextId(),
-				StartIndex: index + 2,
-				EndIndex:   index + 4,
-				Name:       fmt.Sprintf("major version: %d", majorVersion),
-			}
-			section = &Section{
-				Id:         nextId(),
-				StartIndex: index,
-				EndIndex:   next,
-				Name:       fmt.Sprintf("version %d.%d", majorVersion, minorVersion),
-				Children:   []Section{minorVersionSection, majorVersionSection},
-			}
-		}
+	if p.truncated(bytes, index, 4, "version") {
+		return
+	}
+	parser := newByteParser(bytes, index)
+	minorVersion := parser.u2()
+	majorVersion := parser.u2()
+	next += 4
+	minorVersionSection := Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 2,
+		Name:       fmt.Sprintf("minor version: %d", minorVersion),
+	}
+	majorVersionSection := Section{
+		Id:         p.nextId(),
+		StartIndex: index + 2,
+		EndIndex:   index + 4,
+		Name:       fmt.Sprintf("major version: %d", majorVersion),
+	}
+	section = &Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("version %d.%d", majorVersion, minorVersion),
+		Children:   []Section{minorVersionSection, majorVersionSection},
 	}
 	return
 }
 
-func parse(bytes []byte, index int) (next int, section *Section) {
+func (p *sectionParser) parseInterfaces(bytes []byte, index int) (next int, section *Section) {
 	next = index
-	return
-}
-
-func parseInterfaces(bytes []byte, index int) (next int, section *Section) {
-	next = index + 2
+	if p.truncated(bytes, index, 2, "interfaces count") {
+		return
+	}
 	parser := newByteParser(bytes, index)
 	interfacesCount := int(parser.u2())
-	var children []Section
+	if p.truncated(bytes, index, 2+2*interfacesCount, "interfaces") {
+		return
+	}
+	next += 2
+	children := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("interfaces count: %d", interfacesCount),
+	}}
 	for i := 0; i < interfacesCount; i++ {
+		iface := parser.u2()
 		children = append(children, Section{
-			Id:         nextId(),
+			Id:         p.nextId(),
 			StartIndex: next,
 			EndIndex:   next + 2,
-			Name:       fmt.Sprintf("constant pool index for interface: ", parser.u2()),
+			Name:       fmt.Sprintf("interface: %s", p.resolveAt(next, iface, classKind)),
+			Target:     p.constantSectionId(iface),
 		})
 		next += 2
 	}
 	section = &Section{
-		Id:         nextId(),
+		Id:         p.nextId(),
 		StartIndex: index,
 		EndIndex:   next,
 		Name:       fmt.Sprintf("class implements %v interfaces", interfacesCount),
@@ -768,138 +991,356 @@ func parseInterfaces(bytes []byte, index int) (next int, section *Section) {
 	return
 }
 
-func parseThisClass(bytes []byte, index int) (next int, section *Section) {
-	next = index + 2
+func (p *sectionParser) parseFields(bytes []byte, index int) (next int, section *Section) {
+	return p.parseMembers(bytes, index, "fields", fieldFlags, fieldLabel)
+}
+
+func (p *sectionParser) parseMethods(bytes []byte, index int) (next int, section *Section) {
+	return p.parseMembers(bytes, index, "methods", methodFlags, methodLabel)
+}
+
+// fieldLabel and methodLabel name the section of a member after how it is
+// declared in Java, e.g. "method void main(java.lang.String[])". Members
+// with malformed descriptors, which checkClass reports, are named after
+// the descriptor as it is.
+func fieldLabel(name, descriptor string) string {
+	return "field " + fieldDeclaration(name, descriptor)
+}
+
+func methodLabel(name, descriptor string) string {
+	if d, err := parseMethodDescriptor(descriptor); err == nil {
+		return "method " + d.declaration(name)
+	}
+	return fmt.Sprintf("method %s%s", name, descriptor)
+}
+
+// fieldDeclaration renders a field or record component as it is declared
+// in Java, e.g. "java.lang.String name".
+func fieldDeclaration(name, descriptor string) string {
+	if t, err := parseFieldDescriptor(descriptor); err == nil {
+		return fmt.Sprintf("%s %s", t, name)
+	}
+	return fmt.Sprintf("%s %s", name, descriptor)
+}
+
+// parseMembers reads a fields or methods table. Both share the same layout
+// and differ only in which access flags apply and how they are labelled;
+// label receives the member's name and descriptor.
+func (p *sectionParser) parseMembers(bytes []byte, index int, kind string, flags []flagDescription, label func(name, descriptor string) string) (next int, section *Section) {
+	next = index
+	if p.truncated(bytes, index, 2, kind+" count") {
+		return
+	}
+	next += 2
 	parser := newByteParser(bytes, index)
-	this := parser.u2()
+	count := int(parser.u2())
+	children := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("%s count: %d", kind, count),
+	}}
+	p.memberKind = strings.TrimSuffix(kind, "s")
+	for i := 0; i < count; i++ {
+		// Access flags, name, descriptor and attributes count.
+		if p.truncated(bytes, next, 8, kind) {
+			break
+		}
+		var member *Section
+		next, member = p.parseMember(bytes, next, flags, label)
+		children = append(children, *member)
+	}
+	p.memberKind, p.memberName = "", ""
 	section = &Section{
-		Id:         nextId(),
+		Id:         p.nextId(),
 		StartIndex: index,
 		EndIndex:   next,
-		Name:       fmt.Sprintf("constant pool index for this class: %v", this),
+		Name:       fmt.Sprintf("class has %v %s", count, kind),
+		Children:   children,
 	}
 	return
 }
 
-func parseSuperClass(bytes []byte, index int) (next int, section *Section) {
-	next = index + 2
-	parser := newByteParser(bytes, index)
-	super := parser.u2()
+func (p *sectionParser) parseMember(bytes []byte, index int, flagDescriptions []flagDescription, label func(name, descriptor string) string) (next int, section *Section) {
+	var flags *Section
+	next, flags = p.parseFlags(bytes, index, flagDescriptions)
+	parser := newByteParser(bytes, next)
+	nameIndex := parser.u2()
+	descriptorIndex := parser.u2()
+	children := []Section{
+		*flags,
+		{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 2,
+			Name:       fmt.Sprintf("name index: %s", p.resolveAt(next, nameIndex, utf8Kind)),
+			Target:     p.constantSectionId(nameIndex),
+		},
+		{
+			Id:         p.nextId(),
+			StartIndex: next + 2,
+			EndIndex:   next + 4,
+			Name:       fmt.Sprintf("descriptor index: %s", p.resolveAt(next+2, descriptorIndex, utf8Kind)),
+			Target:     p.constantSectionId(descriptorIndex),
+		},
+	}
+	name, _ := p.constantPoolUtf8(nameIndex)
+	descriptor, _ := p.constantPoolUtf8(descriptorIndex)
+	p.memberName = name
+	var attributes []Section
+	next, attributes = p.parseAttributes(bytes, next+4)
+	children = append(children, attributes...)
 	section = &Section{
-		Id:         nextId(),
+		Id:         p.nextId(),
 		StartIndex: index,
 		EndIndex:   next,
-		Name:       fmt.Sprintf("constant pool index for super class: %v", super),
+		Name:       label(name, descriptor),
+		Children:   children,
 	}
 	return
 }
 
-func parseAccessFlags(bytes []byte, index int) (next int, section *Section) {
-	next = index + 2
-	parser := newByteParser(bytes, index)
-	flags := accessFlags(parser.u2())
-	publicSec := Section{
-		Id:         nextId(),
-		StartIndex: index + 1,
-		EndIndex:   index + 2,
-		Name:       fmt.Sprintf("0x0001 public: %v", flags&Public != 0),
-	}
-	staticSec := Section{
-		Id:         nextId(),
-		StartIndex: index + 1,
-		EndIndex:   index + 2,
-		Name:       fmt.Sprintf("0x0008 static: %v", flags&Static != 0),
-	}
-	finalSec := Section{
-		Id:         nextId(),
-		StartIndex: index + 1,
-		EndIndex:   index + 2,
-		Name:       fmt.Sprintf("0x0010 final: %v", flags&Final != 0),
-	}
-	superSec := Section{
-		Id:         nextId(),
-		StartIndex: index + 1,
-		EndIndex:   index + 2,
-		Name:       fmt.Sprintf("0x0020 super: %v", flags&Super != 0),
-	}
-	nativeSec := Section{
-		Id:         nextId(),
-		StartIndex: index,
-		EndIndex:   index + 1,
-		Name:       fmt.Sprintf("0x0100 native: %v", flags&Native != 0),
+func (p *sectionParser) parseThisClass(bytes []byte, index int) (next int, section *Section) {
+	next = index
+	if p.truncated(bytes, index, 2, "this class") {
+		return
 	}
-	interfaceSec := Section{
-		Id:         nextId(),
+	next += 2
+	parser := newByteParser(bytes, index)
+	this := parser.u2()
+	section = &Section{
+		Id:         p.nextId(),
 		StartIndex: index,
-		EndIndex:   index + 1,
-		Name:       fmt.Sprintf("0x0200 interface: %v", flags&Interface != 0),
+		EndIndex:   next,
+		Name:       fmt.Sprintf("this class: %s", p.resolveAt(index, this, classKind)),
+		Target:     p.constantSectionId(this),
 	}
-	abstractSec := Section{
-		Id:         nextId(),
-		StartIndex: index,
-		EndIndex:   index + 1,
-		Name:       fmt.Sprintf("0x0400 abstract: %v", flags&Abstract != 0),
+	return
+}
+
+func (p *sectionParser) parseSuperClass(bytes []byte, index int) (next int, section *Section) {
+	next = index
+	if p.truncated(bytes, index, 2, "super class") {
+		return
 	}
-	syntheticSec := Section{
-		Id:         nextId(),
+	next += 2
+	parser := newByteParser(bytes, index)
+	super := parser.u2()
+	section = &Section{
+		Id:         p.nextId(),
 		StartIndex: index,
-		EndIndex:   index + 1,
-		Name:       fmt.Sprintf("0x1000 synthetic: %v", flags&Synthetic != 0),
+		EndIndex:   next,
+		Name:       fmt.Sprintf("super class: %s", p.resolveOptionalAt(index, super, classKind)),
+		Target:     p.constantSectionId(super),
 	}
-	annotationSec := Section{
-		Id:         nextId(),
-		StartIndex: index,
-		EndIndex:   index + 1,
-		Name:       fmt.Sprintf("0x2000 annotation: %v", flags&Annotation != 0),
+	return
+}
+
+type flagDescription struct {
+	flag accessFlags
+	name string
+}
+
+var classFlags = []flagDescription{
+	{Public, "public"},
+	{Static, "static"},
+	{Final, "final"},
+	{Super, "super"},
+	{Native, "native"},
+	{Interface, "interface"},
+	{Abstract, "abstract"},
+	{Synthetic, "synthetic"},
+	{Annotation, "annotation"},
+	{Enum, "enum"},
+	{Module, "module"},
+}
+
+var fieldFlags = []flagDescription{
+	{Public, "public"},
+	{Private, "private"},
+	{Protected, "protected"},
+	{Static, "static"},
+	{Final, "final"},
+	{Volatile, "volatile"},
+	{Transient, "transient"},
+	{Synthetic, "synthetic"},
+	{Enum, "enum"},
+}
+
+var innerClassFlags = []flagDescription{
+	{Public, "public"},
+	{Private, "private"},
+	{Protected, "protected"},
+	{Static, "static"},
+	{Final, "final"},
+	{Interface, "interface"},
+	{Abstract, "abstract"},
+	{Synthetic, "synthetic"},
+	{Annotation, "annotation"},
+	{Enum, "enum"},
+}
+
+var methodFlags = []flagDescription{
+	{Public, "public"},
+	{Private, "private"},
+	{Protected, "protected"},
+	{Static, "static"},
+	{Final, "final"},
+	{Synchronized, "synchronized"},
+	{Bridge, "bridge"},
+	{Varargs, "varargs"},
+	{Native, "native"},
+	{Abstract, "abstract"},
+	{Strict, "strict"},
+	{Synthetic, "synthetic"},
+}
+
+var methodParameterFlags = []flagDescription{
+	{Final, "final"},
+	{Synthetic, "synthetic"},
+	{Mandated, "mandated"},
+}
+
+func (p *sectionParser) parseFlags(bytes []byte, index int, descriptions []flagDescription) (next int, section *Section) {
+	next = index + 2
+	parser := newByteParser(bytes, index)
+	flags := accessFlags(parser.u2())
+	var children []Section
+	var described [2]bool
+	for _, d := range descriptions {
+		// Each flag lives in either the high or the low byte of the u2.
+		start := index + 1
+		if d.flag >= 0x0100 {
+			start = index
+		}
+		described[start-index] = true
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: start,
+			EndIndex:   start + 1,
+			Name:       fmt.Sprintf("0x%04x %s: %v", uint16(d.flag), d.name, flags&d.flag != 0),
+		})
 	}
-	enumSec := Section{
-		Id:         nextId(),
-		StartIndex: index,
-		EndIndex:   index + 1,
-		Name:       fmt.Sprintf("0x4000 enum: %v", flags&Enum != 0),
+	// A byte holding none of the flags still gets a section, so that
+	// every byte of the class is covered.
+	for i, ok := range described {
+		if !ok {
+			children = append(children, Section{
+				Id:         p.nextId(),
+				StartIndex: index + i,
+				EndIndex:   index + i + 1,
+				Name:       fmt.Sprintf("reserved: 0x%02x", bytes[index+i]),
+			})
+		}
 	}
 	section = &Section{
-		Id:         nextId(),
+		Id:         p.nextId(),
 		StartIndex: index,
-		EndIndex:   index + 2,
+		EndIndex:   next,
 		Name:       "access flags",
-		Children: []Section{
-			publicSec,
-			staticSec,
-			finalSec,
-			superSec,
-			nativeSec,
-			interfaceSec,
-			abstractSec,
-			syntheticSec,
-			annotationSec,
-			enumSec,
-		},
+		Children:   children,
 	}
 	return
 }
 
-func parseConstantPool(bytes []byte, index int) (next int, section *Section) {
+func (p *sectionParser) parseAccessFlags(bytes []byte, index int) (next int, section *Section) {
+	if p.truncated(bytes, index, 2, "access flags") {
+		return index, nil
+	}
+	return p.parseFlags(bytes, index, classFlags)
+}
+
+// constantInfoSizes is the number of bytes following the tag of each kind
+// of constant pool item. UTF-8 strings are followed by that many bytes
+// again.
+var constantInfoSizes = map[uint8]int{
+	1:  2,
+	3:  4,
+	4:  4,
+	5:  8,
+	6:  8,
+	7:  2,
+	8:  2,
+	9:  4,
+	10: 4,
+	11: 4,
+	12: 4,
+	15: 3,
+	16: 2,
+	17: 4,
+	18: 4,
+	19: 2,
+	20: 2,
+}
+
+func (p *sectionParser) parseConstantPool(bytes []byte, index int) (next int, section *Section) {
 	next = index
+	if p.truncated(bytes, index, 2, "constant pool count") {
+		return
+	}
 	parser := newByteParser(bytes, index)
 	constantPoolCount := parser.u2()
 	next += 2
 	var children []Section
+
+	// Items can refer to entries later in the pool, so the labels of
+	// index-bearing sections are filled in by a second pass once every
+	// item has been read.
+	type reference struct {
+		item, child int
+		label       string
+		index       uint16
+		expected    []string
+	}
+	var references []reference
+	refSection := func(item *Section, label string, index uint16, start int, expected ...string) Section {
+		references = append(references, reference{len(children), len(item.Children), label, index, expected})
+		return Section{
+			Id:         p.nextId(),
+			StartIndex: start,
+			EndIndex:   start + 2,
+		}
+	}
+	// Invoke dynamic items refer to the BootstrapMethods attribute rather
+	// than the pool, but their methods' arguments are in the pool too.
+	var bootstrapReferences []reference
+	type resolvedItem struct {
+		child int
+		index uint16
+	}
+	var resolvedItems []resolvedItem
+
 	children = append(children, Section{
-		Id:         nextId(),
+		Id:         p.nextId(),
 		StartIndex: index,
 		EndIndex:   next,
 		Name:       fmt.Sprintf("constant pool count: %d", constantPoolCount),
 	})
 
+	if constantPoolCount == 0 {
+		p.errorf(index, next, "constant pool count must be at least 1")
+	}
+
 loop:
-	for i := 0; i < int(constantPoolCount-1); i++ {
+	for i := 0; i < int(constantPoolCount)-1; i++ {
+		if p.truncated(bytes, next, 1, "constant pool") {
+			break
+		}
+		start, slot := next, uint16(i+1)
+		tag := parser.u1()
+		size, ok := constantInfoSizes[tag]
+		if !ok {
+			p.abandon(start, start+1, "constant pool item %d has unknown tag %d", i+1, tag)
+			break
+		}
+		if p.truncated(bytes, start+1, size, "constant pool") {
+			break
+		}
 		var item Section
-		item.Id = nextId()
+		item.Id = p.nextId()
 		var tagSec Section
-		tagSec.Id = nextId()
+		tagSec.Id = p.nextId()
 		item.StartIndex = next
-		tag := parser.u1()
 		tagSec.StartIndex = next
 		next++
 		tagSec.EndIndex = next
@@ -908,23 +1349,32 @@ loop:
 			item.Name = fmt.Sprintf("[%d] UTF-8 string", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			length := parser.u2()
+			if p.truncated(bytes, next+2, int(length), "constant pool") {
+				next = start
+				break loop
+			}
 			strBytes := make([]byte, length)
 			for i := uint16(0); i < length; i++ {
 				strBytes[i] = parser.u1()
 			}
+			units := decodeModifiedUTF8(strBytes, func(offset, size int, format string, args ...interface{}) {
+				at := next + 2 + offset
+				p.errorf(at, at+size, "UTF-8 string #%d has %s", i+1, fmt.Sprintf(format, args...))
+			})
+			p.constantPool = append(p.constantPool, utf8String{javaString(units)})
 			item.Children = append(item.Children, tagSec)
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 2,
 				Name:       fmt.Sprintf("length: %d", length),
 			})
 			next += 2
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + int(length),
-				Name:       fmt.Sprintf("string: \"%s\"", string(strBytes)),
+				Name:       "string: " + quoteJavaString(units),
 			})
 			next += int(length)
 		case 3:
@@ -932,8 +1382,9 @@ loop:
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			x := int32(parser.u4())
+			p.constantPool = append(p.constantPool, intConstant{x})
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 4,
 				Name:       fmt.Sprintf("%d", x),
@@ -944,8 +1395,9 @@ loop:
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			bits := parser.u4()
+			p.constantPool = append(p.constantPool, floatConstant{math.Float32frombits(bits)})
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 4,
 				Name:       fmt.Sprintf("%v", math.Float32frombits(bits)),
@@ -955,131 +1407,95 @@ loop:
 			item.Name = fmt.Sprintf("[%d] long", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
-			x := parser.u8()
+			x := int64(parser.u8())
+			p.constantPool = append(p.constantPool, longConstant{x}, WideConstantPart2{})
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 8,
-				Name:       fmt.Sprintf("%v", x),
+				Name:       fmt.Sprintf("%dl", x),
 			})
 			next += 8
+			if i+1 == int(constantPoolCount)-1 {
+				p.errorf(item.StartIndex, next, "%s takes two constant pool slots but is the last item", constantKind(p.constantPool[i]))
+			}
 			i++
 		case 6:
 			item.Name = fmt.Sprintf("[%d] double", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			x := parser.u8()
+			p.constantPool = append(p.constantPool, doubleConstant{math.Float64frombits(x)}, WideConstantPart2{})
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 8,
 				Name:       fmt.Sprintf("%v", math.Float64frombits(x)),
 			})
 			next += 8
+			if i+1 == int(constantPoolCount)-1 {
+				p.errorf(item.StartIndex, next, "%s takes two constant pool slots but is the last item", constantKind(p.constantPool[i]))
+			}
 			i++
 		case 7:
 			item.Name = fmt.Sprintf("[%d] class info", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			x := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("name index: %v", x),
-			})
+			p.constantPool = append(p.constantPool, classInfo{nil, x})
+			item.Children = append(item.Children, refSection(&item, "name index", x, next, utf8Kind))
 			next += 2
 		case 8:
 			item.Name = fmt.Sprintf("[%d] string constant", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			utf8Index := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("UTF-8 constant index: %v", utf8Index),
-			})
+			p.constantPool = append(p.constantPool, stringConstant{utf8Index})
+			item.Children = append(item.Children, refSection(&item, "UTF-8 constant index", utf8Index, next, utf8Kind))
 			next += 2
 		case 9:
 			item.Name = fmt.Sprintf("[%d] field ref", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			classIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("class info index: %v", classIndex),
-			})
+			item.Children = append(item.Children, refSection(&item, "class info index", classIndex, next, classKind))
 			next += 2
 			nameAndTypeIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("name and type index: %v", nameAndTypeIndex),
-			})
+			p.constantPool = append(p.constantPool, fieldRef{nil, classIndex, nameAndTypeIndex})
+			item.Children = append(item.Children, refSection(&item, "name and type index", nameAndTypeIndex, next, nameAndTypeKind))
 			next += 2
 		case 10:
 			item.Name = fmt.Sprintf("[%d] method ref", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			classIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("class info index: %v", classIndex),
-			})
+			item.Children = append(item.Children, refSection(&item, "class info index", classIndex, next, classKind))
 			next += 2
 			nameAndTypeIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("name and type index: %v", nameAndTypeIndex),
-			})
+			p.constantPool = append(p.constantPool, methodRef{nil, classIndex, nameAndTypeIndex})
+			item.Children = append(item.Children, refSection(&item, "name and type index", nameAndTypeIndex, next, nameAndTypeKind))
 			next += 2
 		case 11:
 			item.Name = fmt.Sprintf("[%d] interface method ref", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			classIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("class info index: %v", classIndex),
-			})
+			item.Children = append(item.Children, refSection(&item, "class info index", classIndex, next, classKind))
 			next += 2
 			nameAndTypeIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("name and type index: %v", nameAndTypeIndex),
-			})
+			p.constantPool = append(p.constantPool, interfaceMethodRef{nil, classIndex, nameAndTypeIndex})
+			item.Children = append(item.Children, refSection(&item, "name and type index", nameAndTypeIndex, next, nameAndTypeKind))
 			next += 2
 		case 12:
 			item.Name = fmt.Sprintf("[%d] name and type", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			nameIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("name index: %v", nameIndex),
-			})
+			item.Children = append(item.Children, refSection(&item, "name index", nameIndex, next, utf8Kind))
 			next += 2
 			descriptorIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("descriptor index: %v", descriptorIndex),
-			})
+			p.constantPool = append(p.constantPool, nameAndType{nameIndex, descriptorIndex})
+			item.Children = append(item.Children, refSection(&item, "descriptor index", descriptorIndex, next, utf8Kind))
 			next += 2
 		case 15:
 			item.Name = fmt.Sprintf("[%d] method handle", i+1)
@@ -1087,62 +1503,92 @@ loop:
 			item.Children = append(item.Children, tagSec)
 			referenceKind := parser.u1()
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 1,
-				Name:       fmt.Sprintf("reference kind: %v", referenceKind),
+				Name:       fmt.Sprintf("reference kind: %v (%s)", referenceKind, referenceKindNames[referenceKind]),
 			})
+			if _, ok := referenceKindNames[referenceKind]; !ok {
+				p.errorf(next, next+1, "unknown method handle reference kind %d", referenceKind)
+			}
 			next += 1
 			referenceIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("reference index: %v", referenceIndex),
-			})
+			p.constantPool = append(p.constantPool, methodHandle{referenceKind, referenceIndex})
+			item.Children = append(item.Children, refSection(&item, "reference index", referenceIndex, next, referenceKindTargets(referenceKind)...))
 			next += 2
 		case 16:
 			item.Name = fmt.Sprintf("[%d] method type", i+1)
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			descriptorIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("descriptor index: %v", descriptorIndex),
-			})
+			p.constantPool = append(p.constantPool, methodType{descriptorIndex})
+			item.Children = append(item.Children, refSection(&item, "descriptor index", descriptorIndex, next, utf8Kind))
 			next += 2
-		case 18:
+		case 17, 18:
 			item.Name = fmt.Sprintf("[%d] invoke dynamic", i+1)
+			if tag == 17 {
+				item.Name = fmt.Sprintf("[%d] dynamic", i+1)
+			}
 			tagSec.Name = fmt.Sprintf("tag: %d", tag)
 			item.Children = append(item.Children, tagSec)
 			bootstrapMethodIndex := parser.u2()
+			bootstrapReferences = append(bootstrapReferences, reference{len(children), len(item.Children), "bootstrap method attribute index", bootstrapMethodIndex, nil})
 			item.Children = append(item.Children, Section{
-				Id:         nextId(),
+				Id:         p.nextId(),
 				StartIndex: next,
 				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("bootstrap method attribute index: %v", bootstrapMethodIndex),
 			})
 			next += 2
 			nameAndTypeIndex := parser.u2()
-			item.Children = append(item.Children, Section{
-				Id:         nextId(),
-				StartIndex: next,
-				EndIndex:   next + 2,
-				Name:       fmt.Sprintf("name and type index: %v", nameAndTypeIndex),
-			})
+			if tag == 17 {
+				p.constantPool = append(p.constantPool, dynamicConstant{bootstrapMethodIndex, nameAndTypeIndex})
+			} else {
+				p.constantPool = append(p.constantPool, invokeDynamic{bootstrapMethodIndex, nameAndTypeIndex})
+			}
+			item.Children = append(item.Children, refSection(&item, "name and type index", nameAndTypeIndex, next, nameAndTypeKind))
+			next += 2
+		case 19, 20:
+			item.Name = fmt.Sprintf("[%d] module", i+1)
+			if tag == 20 {
+				item.Name = fmt.Sprintf("[%d] package", i+1)
+			}
+			tagSec.Name = fmt.Sprintf("tag: %d", tag)
+			item.Children = append(item.Children, tagSec)
+			nameIndex := parser.u2()
+			if tag == 19 {
+				p.constantPool = append(p.constantPool, moduleInfo{nameIndex})
+			} else {
+				p.constantPool = append(p.constantPool, packageInfo{nameIndex})
+			}
+			item.Children = append(item.Children, refSection(&item, "name index", nameIndex, next, utf8Kind))
 			next += 2
-		default:
-			log.Printf("What is a tag %d\n", tag)
-			break loop
 		}
 		item.EndIndex = next
+		p.constantPoolSections[slot] = item.Id
+		if len(references) > 0 && references[len(references)-1].item == len(children) {
+			resolvedItems = append(resolvedItems, resolvedItem{len(children), slot})
+		}
 		children = append(children, item)
 	}
 
+	for _, r := range references {
+		ref := &children[r.item].Children[r.child]
+		ref.Name = fmt.Sprintf("%s: %s", r.label, p.resolveAt(ref.StartIndex, r.index, r.expected...))
+		ref.Target = p.constantSectionId(r.index)
+	}
+	for _, r := range bootstrapReferences {
+		ref := &children[r.item].Children[r.child]
+		ref.Name = fmt.Sprintf("%s: %d -> %s", r.label, r.index, p.bootstrapMethodAt(r.index))
+		if int(r.index) < len(p.bootstrapSections) {
+			ref.Target = p.bootstrapSections[r.index]
+		}
+	}
+	for _, r := range resolvedItems {
+		children[r.child].Name += ": " + p.constantValue(r.index)
+	}
+
 	section = &Section{
-		Id:         nextId(),
+		Id:         p.nextId(),
 		StartIndex: index,
 		EndIndex:   next,
 		Name:       fmt.Sprintf("constant pool with %d items", len(children)-1),
@@ -1151,26 +1597,97 @@ loop:
 	return
 }
 
-var parsingFuncs = []func([]byte, int) (int, *Section){
-	parseMagicNumber,
-	parseVersion,
-	parseConstantPool,
-	parseAccessFlags,
-	parseThisClass,
-	parseSuperClass,
-	parseInterfaces,
+func (p *sectionParser) parseClassAttributes(bytes []byte, index int) (next int, section *Section) {
+	if p.truncated(bytes, index, 2, "attributes count") {
+		return index, nil
+	}
+	attributesCount := newByteParser(bytes, index).u2()
+	var children []Section
+	next, children = p.parseAttributes(bytes, index)
+	section = &Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("class has %v attributes", attributesCount),
+		Children:   children,
+	}
+	return
 }
 
-func parseClass(bytes []byte) []Section {
-	globalId = 0
+var parsingFuncs = []func(*sectionParser, []byte, int) (int, *Section){
+	(*sectionParser).parseMagicNumber,
+	(*sectionParser).parseVersion,
+	(*sectionParser).parseConstantPool,
+	(*sectionParser).parseAccessFlags,
+	(*sectionParser).parseThisClass,
+	(*sectionParser).parseSuperClass,
+	(*sectionParser).parseInterfaces,
+	(*sectionParser).parseFields,
+	(*sectionParser).parseMethods,
+	(*sectionParser).parseClassAttributes,
+}
+
+// parseClass splits a class file into sections, along with any problems
+// found on the way. Malformed classes are parsed as far as possible.
+func parseClass(bytes []byte) ([]Section, []Diagnostic) {
+	p := newSectionParser()
+	p.findBootstrapMethods(bytes)
 	index := 0
 	var section *Section
 	var sections []Section
 	for _, f := range parsingFuncs {
-		index, section = f(bytes, index)
+		index, section = f(p, bytes, index)
 		if section != nil {
 			sections = append(sections, *section)
 		}
+		if p.abandoned {
+			break
+		}
+	}
+	if index < len(bytes) {
+		if !p.abandoned {
+			p.errorf(index, len(bytes), "%d bytes after the end of the class", len(bytes)-index)
+		}
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   len(bytes),
+			Name:       fmt.Sprintf("unparsed: %d bytes", len(bytes)-index),
+		})
+	}
+	numberSections(sections)
+	sortDiagnostics(p.diagnostics)
+	return sections, p.diagnostics
+}
+
+// numberSections renumbers the sections from one, in the order their ids
+// were handed out. Parsing gives out ids before it knows whether an item
+// is whole, and throws away the sections of those that aren't, so this
+// keeps their ids from leaving gaps. Targets follow the sections they
+// point at and are dropped if those were thrown away.
+func numberSections(sections []Section) {
+	var ids []int
+	var collect func([]Section)
+	collect = func(sections []Section) {
+		for _, s := range sections {
+			ids = append(ids, s.Id)
+			collect(s.Children)
+		}
+	}
+	collect(sections)
+	sort.Ints(ids)
+	renumbered := make(map[int]int, len(ids))
+	for i, id := range ids {
+		renumbered[id] = i + 1
+	}
+	var renumber func([]Section)
+	renumber = func(sections []Section) {
+		for i := range sections {
+			s := &sections[i]
+			s.Id = renumbered[s.Id]
+			s.Target = renumbered[s.Target]
+			renumber(s.Children)
+		}
 	}
-	return sections
+	renumber(sections)
 }
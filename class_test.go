@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// constantTypes is the type ParseClass should give each constant pool tag.
+var constantTypes = map[byte]ConstantPoolItem{
+	1:  utf8String{},
+	3:  intConstant{},
+	4:  floatConstant{},
+	5:  longConstant{},
+	6:  doubleConstant{},
+	7:  classInfo{},
+	8:  stringConstant{},
+	9:  fieldRef{},
+	10: methodRef{},
+	11: interfaceMethodRef{},
+	12: nameAndType{},
+	15: methodHandle{},
+	16: methodType{},
+	17: dynamicConstant{},
+	18: invokeDynamic{},
+	19: moduleInfo{},
+	20: packageInfo{},
+}
+
+func attributeNames(attributes []attribute) string {
+	var names []string
+	for _, a := range attributes {
+		names = append(names, a.name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestParseClass(t *testing.T) {
+	tests := []struct {
+		file         string
+		major, minor uint16
+		name         string
+		fields       []string
+		methods      []string
+		attributes   string
+	}{
+		{
+			file: "Hello11.class", major: 45, minor: 3,
+			name: "Hello11",
+			methods: []string{
+				"main([Ljava/lang/String;)V 9 bytes, 0 handlers: Code",
+				"<init>()V 5 bytes, 0 handlers: Code",
+			},
+			attributes: "SourceFile",
+		},
+		{
+			file: "Box5.class", major: 49,
+			name: "Box5",
+			fields: []string{
+				"serialVersionUID J: ConstantValue",
+				"value Ljava/lang/Comparable;: Signature",
+			},
+			methods: []string{
+				"<init>(Ljava/lang/Comparable;)V 10 bytes, 0 handlers: Code Signature",
+				"get()Ljava/lang/Comparable; 5 bytes, 0 handlers: Code Signature",
+				"check()V 1 bytes, 0 handlers: Code Exceptions Signature",
+				"of([Ljava/lang/Comparable;)LBox5; 11 bytes, 0 handlers: Code Signature",
+			},
+			attributes: "Deprecated Signature RuntimeVisibleAnnotations",
+		},
+		{
+			file: "Branches6.class", major: 50,
+			name: "Branches6",
+			methods: []string{
+				"<init>()V 5 bytes, 0 handlers: Code",
+				"max(II)I 11 bytes, 0 handlers: Code",
+				"sum([I)I 24 bytes, 0 handlers: Code",
+				"name(I)Ljava/lang/String; 33 bytes, 0 handlers: Code",
+				"kind(I)I 34 bytes, 0 handlers: Code",
+				"parse(Ljava/lang/String;)I 8 bytes, 1 handlers: Code",
+				"count(I)I 28 bytes, 0 handlers: Code",
+			},
+		},
+		{
+			file: "HelloWorld.class", major: 52,
+			name: "HelloWorld",
+			methods: []string{
+				"<init>()V 5 bytes, 0 handlers: Code",
+				"main()V 9 bytes, 0 handlers: Code",
+			},
+			attributes: "SourceFile",
+		},
+		{
+			file: "Lambda8.class", major: 52,
+			name: "Lambda8",
+			methods: []string{
+				"<init>()V 5 bytes, 0 handlers: Code",
+				"main([Ljava/lang/String;)V 22 bytes, 0 handlers: Code",
+				"lambda$main$0()Ljava/lang/String; 3 bytes, 0 handlers: Code",
+			},
+			attributes: "InnerClasses BootstrapMethods",
+		},
+		{
+			file: "Nest11.class", major: 55,
+			name:   "Nest11",
+			fields: []string{"count I: "},
+			methods: []string{
+				"<init>()V 5 bytes, 0 handlers: Code",
+				"describe()Ljava/lang/String; 10 bytes, 0 handlers: Code",
+			},
+			attributes: "NestMembers BootstrapMethods InnerClasses",
+		},
+		{
+			file: "Annotated.class", major: 55,
+			name:   "Annotated",
+			fields: []string{"names Ljava/util/List;: Deprecated Signature RuntimeVisibleAnnotations RuntimeVisibleTypeAnnotations"},
+			methods: []string{
+				"<init>()V 5 bytes, 0 handlers: Code",
+				"m(I[Ljava/lang/String;)Ljava/lang/String; 20 bytes, 1 handlers: Code Exceptions RuntimeVisibleParameterAnnotations RuntimeVisibleTypeAnnotations",
+			},
+			attributes: "Signature RuntimeVisibleAnnotations RuntimeVisibleTypeAnnotations",
+		},
+		{
+			file: "Condy.class", major: 55,
+			name: "Condy",
+			methods: []string{
+				"value()Ljava/lang/Object; 3 bytes, 0 handlers: Code",
+				"type()Ljava/lang/Class; 3 bytes, 0 handlers: Code",
+			},
+			attributes: "BootstrapMethods InnerClasses",
+		},
+		{
+			file: "module-info.class", major: 55,
+			name:       "module-info",
+			attributes: "Module ModulePackages ModuleMainClass",
+		},
+		{
+			file: "Point17.class", major: 61,
+			name: "Point17",
+			fields: []string{
+				"x I: ",
+				"y I: ",
+			},
+			methods: []string{
+				"<init>(II)V 15 bytes, 0 handlers: Code MethodParameters",
+				"toString()Ljava/lang/String; 7 bytes, 0 handlers: Code",
+				"hashCode()I 7 bytes, 0 handlers: Code",
+				"equals(Ljava/lang/Object;)Z 8 bytes, 0 handlers: Code",
+				"x()I 5 bytes, 0 handlers: Code",
+				"y()I 5 bytes, 0 handlers: Code",
+			},
+			attributes: "Record BootstrapMethods InnerClasses",
+		},
+		{
+			file: "Shape25.class", major: 69,
+			name: "Shape25",
+			methods: []string{
+				"area()D 0 bytes, 0 handlers: ",
+				"describe()Ljava/lang/String; 12 bytes, 0 handlers: Code",
+			},
+			attributes: "PermittedSubclasses BootstrapMethods InnerClasses",
+		},
+	}
+	for _, test := range tests {
+		data := readFixture(t, test.file)
+		c, err := ParseClass(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+			continue
+		}
+		if c.MajorVersion != test.major || c.MinorVersion != test.minor {
+			t.Errorf("%s: version %d.%d, want %d.%d", test.file, c.MajorVersion, c.MinorVersion, test.major, test.minor)
+		}
+		if name := c.Name(); name != test.name {
+			t.Errorf("%s: name %q, want %q", test.file, name, test.name)
+		}
+
+		count := int(data[8])<<8 | int(data[9])
+		if len(c.ConstantPoolItems) != count-1 || len(c.constantPoolOffsets) != count-1 {
+			t.Errorf("%s: %d constants at %d offsets, want %d", test.file, len(c.ConstantPoolItems), len(c.constantPoolOffsets), count-1)
+			continue
+		}
+		for i, item := range c.ConstantPoolItems {
+			tag := data[c.constantPoolOffsets[i]]
+			if i > 0 && (tag == 5 || tag == 6) && c.constantPoolOffsets[i] == c.constantPoolOffsets[i-1] {
+				if _, ok := item.(WideConstantPart2); !ok {
+					t.Errorf("%s: constant #%d is %T, want the second half of #%d", test.file, i+1, item, i)
+				}
+				continue
+			}
+			if want := constantTypes[tag]; reflect.TypeOf(item) != reflect.TypeOf(want) {
+				t.Errorf("%s: constant #%d with tag %d is %T, want %T", test.file, i+1, tag, item, want)
+			}
+		}
+
+		var fields []string
+		for _, f := range c.fields {
+			name, _ := c.utf8At(f.nameIndex)
+			descriptor, _ := c.utf8At(f.descriptorIndex)
+			fields = append(fields, fmt.Sprintf("%s %s: %s", name, descriptor, attributeNames(f.attributes)))
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s: fields\n%s\nwant\n%s", test.file, strings.Join(fields, "\n"), strings.Join(test.fields, "\n"))
+		}
+
+		var methods []string
+		for _, m := range c.methods {
+			methods = append(methods, fmt.Sprintf("%s%s %d bytes, %d handlers: %s",
+				m.Name(), m.RawSigniture, len(m.Code.Instructions), len(m.Code.ExceptionHandlers), attributeNames(m.attributes)))
+		}
+		if !reflect.DeepEqual(methods, test.methods) {
+			t.Errorf("%s: methods\n%s\nwant\n%s", test.file, strings.Join(methods, "\n"), strings.Join(test.methods, "\n"))
+		}
+
+		if names := attributeNames(c.attributes); names != test.attributes {
+			t.Errorf("%s: attributes %q, want %q", test.file, names, test.attributes)
+		}
+	}
+}
+
+// TestParseClassTruncated checks that a class cut short keeps just the
+// entries of each table that come before the cut.
+func TestParseClassTruncated(t *testing.T) {
+	data := readFixture(t, "Branches6.class")
+	whole, err := ParseClass(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methodOffsets []int
+	for _, m := range whole.methods {
+		methodOffsets = append(methodOffsets, m.offset)
+	}
+	for n := 0; n < len(data); n++ {
+		c, err := ParseClass(bytes.NewReader(data[:n]))
+		if err == nil {
+			t.Errorf("%d bytes: no error", n)
+		}
+		if len(c.ConstantPoolItems) != len(c.constantPoolOffsets) || !isPrefix(c.constantPoolOffsets, whole.constantPoolOffsets) {
+			t.Errorf("%d bytes: %d constants at %v", n, len(c.ConstantPoolItems), c.constantPoolOffsets)
+		}
+		if !isPrefix(c.interfaces, whole.interfaces) {
+			t.Errorf("%d bytes: interfaces %v", n, c.interfaces)
+		}
+		if !isPrefix(c.fields, whole.fields) {
+			t.Errorf("%d bytes: fields %v", n, c.fields)
+		}
+		var offsets []int
+		for _, m := range c.methods {
+			if m.Class() == nil {
+				t.Fatalf("%d bytes: method at %d has no class", n, m.offset)
+			}
+			if name := m.Name(); name == "" {
+				t.Errorf("%d bytes: method at %d has no name", n, m.offset)
+			}
+			offsets = append(offsets, m.offset)
+		}
+		if !isPrefix(offsets, methodOffsets) {
+			t.Errorf("%d bytes: methods at %v", n, offsets)
+		}
+		if !isPrefix(c.attributes, whole.attributes) {
+			t.Errorf("%d bytes: attributes %v", n, c.attributes)
+		}
+	}
+}
+
+// isPrefix reports whether the slice part is equal to the start of the
+// slice whole.
+func isPrefix(part, whole interface{}) bool {
+	p, w := reflect.ValueOf(part), reflect.ValueOf(whole)
+	return p.Len() <= w.Len() && (p.Len() == 0 || reflect.DeepEqual(part, w.Slice(0, p.Len()).Interface()))
+}
+
+// parsedFixture returns the section tree of a fixture, failing unless it
+// parses without diagnostics and its sections cover every byte.
+func parsedFixture(t *testing.T, name string) []Section {
+	t.Helper()
+	data := readFixture(t, name)
+	sections, diagnostics := parseClass(data)
+	for _, d := range diagnostics {
+		t.Errorf("%s: %d-%d: %s: %s", name, d.StartIndex, d.EndIndex, d.Severity, d.Message)
+	}
+	checkCoverage(t, name, sections, len(data))
+	return sections
+}
+
+// findSection returns the section reached by looking for each element of
+// path in turn, depth first, among the descendants of the last one found.
+// Elements match the start of a section's name.
+func findSection(t *testing.T, sections []Section, path ...string) Section {
+	t.Helper()
+	var find func(sections []Section, prefix string) *Section
+	find = func(sections []Section, prefix string) *Section {
+		for i := range sections {
+			if strings.HasPrefix(sections[i].Name, prefix) {
+				return &sections[i]
+			}
+			if s := find(sections[i].Children, prefix); s != nil {
+				return s
+			}
+		}
+		return nil
+	}
+	var found Section
+	for _, prefix := range path {
+		s := find(sections, prefix)
+		if s == nil {
+			t.Fatalf("no section %q in %v", prefix, path)
+		}
+		found, sections = *s, s.Children
+	}
+	return found
+}
+
+// outline renders s and its descendants down to depth levels below it as
+// "start-end name" lines, indented two spaces a level.
+func outline(s Section, depth int) string {
+	var b strings.Builder
+	var write func(s Section, level int)
+	write = func(s Section, level int) {
+		fmt.Fprintf(&b, "%s%d-%d %s\n", strings.Repeat("  ", level), s.StartIndex, s.EndIndex, s.Name)
+		if level < depth {
+			for _, child := range s.Children {
+				write(child, level+1)
+			}
+		}
+	}
+	write(s, 0)
+	return b.String()
+}
+
+// checkOutline compares the outline of s with want, ignoring the newline
+// that starts a raw string literal.
+func checkOutline(t *testing.T, s Section, depth int, want string) {
+	t.Helper()
+	want = strings.TrimPrefix(want, "\n")
+	if got := outline(s, depth); got != want {
+		t.Errorf("%s:\n%s\nwant\n%s", s.Name, got, want)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	sections := parsedFixture(t, "Box5.class")
+	checkOutline(t, findSection(t, sections, "class has 2 fields"), 3, `
+620-654 class has 2 fields
+  620-622 fields count: 2
+  622-638 field long serialVersionUID
+    622-624 access flags
+      623-624 0x0001 public: true
+      623-624 0x0002 private: false
+      623-624 0x0004 protected: false
+      623-624 0x0008 static: true
+      623-624 0x0010 final: true
+      623-624 0x0040 volatile: false
+      623-624 0x0080 transient: false
+      622-623 0x1000 synthetic: false
+      622-623 0x4000 enum: false
+    624-626 name index: #4 -> serialVersionUID
+    626-628 descriptor index: #5 -> J
+    628-630 attributes count: 1
+    630-638 attribute ConstantValue
+      630-632 name index: #3 -> ConstantValue
+      632-636 length: 2
+      636-638 constant value index: #1 -> 1
+  638-654 field java.lang.Comparable value
+    638-640 access flags
+      639-640 0x0001 public: false
+      639-640 0x0002 private: true
+      639-640 0x0004 protected: false
+      639-640 0x0008 static: false
+      639-640 0x0010 final: false
+      639-640 0x0040 volatile: false
+      639-640 0x0080 transient: false
+      638-639 0x1000 synthetic: false
+      638-639 0x4000 enum: false
+    640-642 name index: #8 -> value
+    642-644 descriptor index: #9 -> Ljava/lang/Comparable;
+    644-646 attributes count: 1
+    646-654 attribute Signature
+      646-648 name index: #7 -> Signature
+      648-652 length: 2
+      652-654 signature: T value
+`)
+
+	// A class without fields still shows the count.
+	checkOutline(t, findSection(t, parsedFixture(t, "Branches6.class"), "class has 0 fields"), 1, `
+325-327 class has 0 fields
+  325-327 fields count: 0
+`)
+}
+
+func TestParseMethods(t *testing.T) {
+	sections := parsedFixture(t, "Box5.class")
+	checkOutline(t, findSection(t, sections, "class has 4 methods"), 1, `
+654-829 class has 4 methods
+  654-656 methods count: 4
+  656-700 method void <init>(java.lang.Comparable)
+  700-739 method java.lang.Comparable get()
+  739-784 method void check()
+  784-829 method Box5 of(java.lang.Comparable[])
+`)
+	checkOutline(t, findSection(t, sections, "method void check()"), 2, `
+739-784 method void check()
+  739-741 access flags
+    740-741 0x0001 public: true
+    740-741 0x0002 private: false
+    740-741 0x0004 protected: false
+    740-741 0x0008 static: false
+    740-741 0x0010 final: false
+    740-741 0x0020 synchronized: false
+    740-741 0x0040 bridge: false
+    740-741 0x0080 varargs: false
+    739-740 0x0100 native: false
+    739-740 0x0400 abstract: false
+    739-740 0x0800 strict: false
+    739-740 0x1000 synthetic: false
+  741-743 name index: #30 -> check
+  743-745 descriptor index: #13 -> ()V
+  745-747 attributes count: 3
+  747-766 attribute Code
+    747-749 name index: #20 -> Code
+    749-753 length: 13
+    753-755 max stack: 0
+    755-757 max locals: 1
+    757-761 code length: 1
+    761-762 code: 1 bytes
+    762-764 0 exception handlers
+    764-766 attributes count: 0
+  766-776 attribute Exceptions
+    766-768 name index: #28 -> Exceptions
+    768-772 length: 4
+    772-774 number of exceptions: 1
+    774-776 exception index: #27 -> java/lang/Exception
+  776-784 attribute Signature
+    776-778 name index: #7 -> Signature
+    778-782 length: 2
+    782-784 signature: <E extends java.lang.Exception> void check() throws E
+`)
+	checkOutline(t, findSection(t, parsedFixture(t, "Point17.class"), "method void <init>", "attribute MethodParameters"), 2, `
+790-805 attribute MethodParameters
+  790-792 name index: #29 -> MethodParameters
+  792-796 length: 9
+  796-797 parameters count: 2
+  797-801 parameter x
+    797-799 name index: #12 -> x
+    799-801 access flags
+  801-805 parameter y
+    801-803 name index: #17 -> y
+    803-805 access flags
+`)
+}
+
+func TestParseDynamicModuleAndPackageConstants(t *testing.T) {
+	condy := parsedFixture(t, "Condy.class")
+	checkOutline(t, findSection(t, condy, "[16] dynamic"), 1, `
+334-339 [16] dynamic: #0:_:Ljava/lang/Object; (ConstantBootstraps.nullConstant())
+  334-335 tag: 17
+  335-337 bootstrap method attribute index: 0 -> ConstantBootstraps.nullConstant()
+  337-339 name and type index: #15 -> _:Ljava/lang/Object;
+`)
+	checkOutline(t, findSection(t, condy, "[23] dynamic"), 1, `
+406-411 [23] dynamic: #1:I:Ljava/lang/Class; (ConstantBootstraps.primitiveClass())
+  406-407 tag: 17
+  407-409 bootstrap method attribute index: 1 -> ConstantBootstraps.primitiveClass()
+  409-411 name and type index: #22 -> I:Ljava/lang/Class;
+`)
+	checkOutline(t, findSection(t, condy, "0: ldc #23"), 1, `
+658-660 0: ldc #23 // Dynamic #1:I:Ljava/lang/Class; (ConstantBootstraps.primitiveClass())
+  658-659 opcode 0x12: ldc
+  659-660 constant pool index: #23 -> #1:I:Ljava/lang/Class; (ConstantBootstraps.primitiveClass())
+`)
+
+	module := parsedFixture(t, "module-info.class")
+	checkOutline(t, findSection(t, module, "[3] module"), 1, `
+33-36 [3] module: com.example.app
+  33-34 tag: 19
+  34-36 name index: #2 -> com.example.app
+`)
+	checkOutline(t, findSection(t, module, "[11] package"), 1, `
+105-108 [11] package: com/example/app/api
+  105-106 tag: 20
+  106-108 name index: #10 -> com/example/app/api
+`)
+}
+
+func TestParseLongConstants(t *testing.T) {
+	class := checkedClass(func(b *classBuilder, s *classSpec) {
+		b.long(-1)
+		b.long(math.MinInt64)
+	})
+	sections, _ := parseClass(class)
+	checkOutline(t, findSection(t, sections, "constant pool", "[10] long"), 1, `
+72-81 [10] long
+  72-73 tag: 5
+  73-81 -1l
+`)
+	checkOutline(t, findSection(t, sections, "constant pool", "[12] long"), 1, `
+81-90 [12] long
+  81-82 tag: 5
+  82-90 -9223372036854775808l
+`)
+}
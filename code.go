@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (p *sectionParser) parseCodeAttribute(bytes []byte, start, end int) []Section {
+	if end-start < 12 {
+		return p.malformedInfo("Code", start, end)
+	}
+	parser := newByteParser(bytes, start)
+	maxStack := parser.u2()
+	maxLocals := parser.u2()
+	codeLength := int(parser.u4())
+	codeStart := start + 8
+	codeEnd := codeStart + codeLength
+	// The exception table and attributes counts follow the code.
+	if codeEnd+4 > end || codeEnd < codeStart {
+		p.errorf(start+4, codeStart, "code length %d overruns the Code attribute", codeLength)
+		return p.opaqueInfo(start, end)
+	}
+	children := []Section{
+		{
+			Id:         p.nextId(),
+			StartIndex: start,
+			EndIndex:   start + 2,
+			Name:       fmt.Sprintf("max stack: %d", maxStack),
+		},
+		{
+			Id:         p.nextId(),
+			StartIndex: start + 2,
+			EndIndex:   start + 4,
+			Name:       fmt.Sprintf("max locals: %d", maxLocals),
+		},
+		{
+			Id:         p.nextId(),
+			StartIndex: start + 4,
+			EndIndex:   codeStart,
+			Name:       fmt.Sprintf("code length: %d", codeLength),
+		},
+		{
+			Id:         p.nextId(),
+			StartIndex: codeStart,
+			EndIndex:   codeEnd,
+			Name:       fmt.Sprintf("code: %d bytes", codeLength),
+			Children:   p.parseInstructions(bytes[codeStart:codeEnd], codeStart),
+		},
+	}
+
+	next := codeEnd
+	parser = newByteParser(bytes, next)
+	handlersCount := int(parser.u2())
+	handlers := []Section{{
+		Id:         p.nextId(),
+		StartIndex: next,
+		EndIndex:   next + 2,
+		Name:       fmt.Sprintf("exception table length: %d", handlersCount),
+	}}
+	next += 2
+	for i := 0; i < handlersCount; i++ {
+		if next+8 > end {
+			p.errorf(codeEnd, codeEnd+2, "exception table length is %d but only %d handlers fit", handlersCount, i)
+			break
+		}
+		handlers = append(handlers, p.parseExceptionHandler(bytes, next))
+		next += 8
+	}
+	children = append(children, Section{
+		Id:         p.nextId(),
+		StartIndex: codeEnd,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("%d exception handlers", handlersCount),
+		Children:   handlers,
+	})
+
+	p.lineNumbers = nil
+	if next+2 <= end {
+		var attributes []Section
+		next, attributes = p.parseAttributes(bytes[:end], next)
+		children = append(children, attributes...)
+	}
+	setLines(children[3].Children, codeStart, p.lineNumbers)
+	p.instructionSections = nil
+	p.lineNumbers = nil
+	return append(children, p.leftoverInfo("Code", next, end)...)
+}
+
+func (p *sectionParser) parseExceptionHandler(bytes []byte, index int) Section {
+	parser := newByteParser(bytes, index)
+	startPc := parser.u2()
+	endPc := parser.u2()
+	handlerPc := parser.u2()
+	catchType := parser.u2()
+	catches := "any"
+	if catchType != 0 {
+		catches = p.describeConstant(catchType)
+	}
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 8,
+		Name:       fmt.Sprintf("%d to %d handled at %d: %s", startPc, endPc, handlerPc, catches),
+		Children: []Section{
+			{
+				Id:         p.nextId(),
+				StartIndex: index,
+				EndIndex:   index + 2,
+				Name:       fmt.Sprintf("start pc: %d", startPc),
+			},
+			{
+				Id:         p.nextId(),
+				StartIndex: index + 2,
+				EndIndex:   index + 4,
+				Name:       fmt.Sprintf("end pc: %d", endPc),
+			},
+			{
+				Id:         p.nextId(),
+				StartIndex: index + 4,
+				EndIndex:   index + 6,
+				Name:       fmt.Sprintf("handler pc: %d", handlerPc),
+			},
+			p.optionalIndexSection(bytes, index+6, "catch type", classKind),
+		},
+	}
+}
+
+// parseInstructions disassembles a code array. offset is the position of
+// the code array within the class file.
+func (p *sectionParser) parseInstructions(code []byte, offset int) []Section {
+	var sections []Section
+	p.instructionSections = map[int]int{}
+	for pc := 0; pc < len(code); {
+		inst, err := decodeInstruction(code, pc)
+		var section Section
+		if err != nil {
+			p.errorf(offset+pc, offset+pc+inst.length, "%d: %s", pc, err)
+			section = Section{
+				Id:         p.nextId(),
+				StartIndex: offset + pc,
+				EndIndex:   offset + pc + inst.length,
+				Name:       fmt.Sprintf("%d: %s", pc, err),
+			}
+		} else {
+			section = p.instructionSection(inst, offset)
+		}
+		p.instructionSections[pc] = section.Id
+		sections = append(sections, section)
+		pc += inst.length
+	}
+	return sections
+}
+
+func (p *sectionParser) instructionSection(inst instruction, offset int) Section {
+	start := offset + inst.pc
+	var children []Section
+	if inst.wide {
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: start,
+			EndIndex:   start + 1,
+			Name:       "wide",
+		})
+		start++
+	}
+	children = append(children, Section{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 1,
+		Name:       fmt.Sprintf("opcode 0x%02x: %s", inst.opcode, inst.name()),
+	})
+	for _, o := range inst.operands {
+		operandSec := Section{
+			Id:         p.nextId(),
+			StartIndex: offset + o.start,
+			EndIndex:   offset + o.start + o.size,
+			Name:       fmt.Sprintf("%s: %s", operandKindNames[o.kind], p.operandText(inst, o, true)),
+		}
+		if o.kind == constantIndex {
+			p.checkReference(operandSec.StartIndex, operandSec.EndIndex, uint16(o.value), constantOperandKinds(inst.opcode)...)
+			operandSec.Target = p.constantSectionId(uint16(o.value))
+		}
+		children = append(children, operandSec)
+	}
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: offset + inst.pc,
+		EndIndex:   offset + inst.pc + inst.length,
+		Name:       fmt.Sprintf("%d: %s", inst.pc, p.instructionText(inst)),
+		Children:   children,
+	}
+}
+
+// instructionText renders an instruction on one line, in roughly the form
+// javap uses.
+func (p *sectionParser) instructionText(inst instruction) string {
+	name := inst.name()
+	if inst.wide {
+		name = "wide " + name
+	}
+	switch opcodes[inst.opcode].operands {
+	case tableSwitchOperands:
+		low, _ := inst.operand(lowValue)
+		high, _ := inst.operand(highValue)
+		def, _ := inst.operand(defaultOffset)
+		return fmt.Sprintf("%s %d to %d, default: %d", name, low.value, high.value, inst.pc+def.value)
+	case lookupSwitchOperands:
+		pairs, _ := inst.operand(pairCount)
+		def, _ := inst.operand(defaultOffset)
+		return fmt.Sprintf("%s %d pairs, default: %d", name, pairs.value, inst.pc+def.value)
+	}
+	var args []string
+	var comment string
+	for _, o := range inst.operands {
+		if o.kind == reserved {
+			continue
+		}
+		args = append(args, p.operandText(inst, o, false))
+		if o.kind == constantIndex {
+			comment = " // " + p.describeConstant(uint16(o.value))
+		}
+	}
+	if len(args) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(args, ", ") + comment
+}
+
+// operandText renders a single operand. When verbose is set, constant pool
+// indexes are followed by what they refer to.
+func (p *sectionParser) operandText(inst instruction, o operand, verbose bool) string {
+	switch o.kind {
+	case constantIndex:
+		if verbose {
+			return p.resolve(uint16(o.value), constantOperandKinds(inst.opcode)...)
+		}
+		return fmt.Sprintf("#%d", o.value)
+	case branchOffset, defaultOffset:
+		if verbose {
+			return fmt.Sprintf("%+d -> %d", o.value, inst.pc+o.value)
+		}
+		return fmt.Sprintf("%d", inst.pc+o.value)
+	case arrayType:
+		if t, ok := arrayTypes[o.value]; ok {
+			return t
+		}
+	case padding:
+		return fmt.Sprintf("%d bytes", o.size)
+	}
+	return fmt.Sprintf("%d", o.value)
+}
@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestParseCode(t *testing.T) {
+	sections := parsedFixture(t, "Branches6.class")
+	checkOutline(t, findSection(t, sections, "method java.lang.String name(int)", "code: "), 2, `
+496-529 code: 33 bytes
+  496-497 0: iload_0
+    496-497 opcode 0x1a: iload_0
+  497-520 1: tableswitch 1 to 2, default: 30
+    497-498 opcode 0xaa: tableswitch
+    498-500 padding: 2 bytes
+    500-504 default offset: +29 -> 30
+    504-508 low: 1
+    508-512 high: 2
+    512-516 branch offset: +23 -> 24
+    516-520 branch offset: +26 -> 27
+  520-522 24: ldc #14 // String "one"
+    520-521 opcode 0x12: ldc
+    521-522 constant pool index: #14 -> "one"
+  522-523 26: areturn
+    522-523 opcode 0xb0: areturn
+  523-525 27: ldc #16 // String "two"
+    523-524 opcode 0x12: ldc
+    524-525 constant pool index: #16 -> "two"
+  525-526 29: areturn
+    525-526 opcode 0xb0: areturn
+  526-528 30: ldc #18 // String "many"
+    526-527 opcode 0x12: ldc
+    527-528 constant pool index: #18 -> "many"
+  528-529 32: areturn
+    528-529 opcode 0xb0: areturn
+`)
+	checkOutline(t, findSection(t, sections, "1: lookupswitch"), 1, `
+567-594 1: lookupswitch 2 pairs, default: 32
+  567-568 opcode 0xab: lookupswitch
+  568-570 padding: 2 bytes
+  570-574 default offset: +31 -> 32
+  574-578 pair count: 2
+  578-582 match: -1
+  582-586 branch offset: +27 -> 28
+  586-590 match: 1000
+  590-594 branch offset: +29 -> 30
+`)
+
+	b := newClassBuilder()
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "W", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0008, "m", "()V",
+				b.code(1, 301, join(
+					op("wide"), op("iinc"), u2(300), u2(0xfc18),
+					op("wide"), op("iload"), u2(256),
+					op("pop"),
+					op("return"),
+				), nil)),
+		},
+	})
+	sections, diagnostics := parseClass(class)
+	if len(diagnostics) != 0 {
+		t.Errorf("wide: %v", diagnostics)
+	}
+	checkCoverage(t, "wide", sections, len(class))
+	checkOutline(t, findSection(t, sections, "code: "), 2, `
+90-102 code: 12 bytes
+  90-96 0: wide iinc 300, -1000
+    90-91 wide
+    91-92 opcode 0x84: iinc
+    92-94 local variable index: 300
+    94-96 value: -1000
+  96-100 6: wide iload 256
+    96-97 wide
+    97-98 opcode 0x15: iload
+    98-100 local variable index: 256
+  100-101 10: pop
+    100-101 opcode 0x57: pop
+  101-102 11: return
+    101-102 opcode 0xb1: return
+`)
+}
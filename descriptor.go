@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fieldType is a field descriptor (JVMS §4.3.2): a primitive type or a
+// class, as an array of the given number of dimensions if any.
+type fieldType struct {
+	dimensions int
+
+	// base is the descriptor letter of a primitive type, or L for a class
+	// named by className.
+	base      byte
+	className string
+}
+
+// descriptor renders t as it appears in a class file, e.g.
+// "[Ljava/lang/String;".
+func (t fieldType) descriptor() string {
+	d := strings.Repeat("[", t.dimensions)
+	if t.base == 'L' {
+		return d + "L" + t.className + ";"
+	}
+	return d + string(rune(t.base))
+}
+
+// String renders t as it would be written in Java, e.g.
+// "java.lang.String[]".
+func (t fieldType) String() string {
+	name := primitiveTypeNames[string(rune(t.base))]
+	if t.base == 'L' {
+		name = strings.Replace(t.className, "/", ".", -1)
+	}
+	return name + strings.Repeat("[]", t.dimensions)
+}
+
+// slots is the number of local variable slots a value of type t takes.
+func (t fieldType) slots() int {
+	if t.dimensions == 0 && (t.base == 'J' || t.base == 'D') {
+		return 2
+	}
+	return 1
+}
+
+// methodDescriptor is a method descriptor (JVMS §4.3.3). result is nil
+// for methods that return void.
+type methodDescriptor struct {
+	parameters []fieldType
+	result     *fieldType
+}
+
+// slots is the number of local variable slots the parameters take, not
+// counting this.
+func (d methodDescriptor) slots() int {
+	slots := 0
+	for _, p := range d.parameters {
+		slots += p.slots()
+	}
+	return slots
+}
+
+// resultDescriptor is the descriptor of the return type, or V.
+func (d methodDescriptor) resultDescriptor() string {
+	if d.result == nil {
+		return "V"
+	}
+	return d.result.descriptor()
+}
+
+// declaration renders the descriptor as the declaration of a method called
+// name, e.g. "void main(java.lang.String[])".
+func (d methodDescriptor) declaration(name string) string {
+	result := "void"
+	if d.result != nil {
+		result = d.result.String()
+	}
+	parameters := make([]string, len(d.parameters))
+	for i, p := range d.parameters {
+		parameters[i] = p.String()
+	}
+	return fmt.Sprintf("%s %s(%s)", result, name, strings.Join(parameters, ", "))
+}
+
+func parseFieldDescriptor(s string) (fieldType, error) {
+	t, next, err := scanFieldType(s, 0)
+	if err == nil && next < len(s) {
+		err = fmt.Errorf("unexpected %q after the type at offset %d", s[next], next)
+	}
+	return t, err
+}
+
+func parseMethodDescriptor(s string) (methodDescriptor, error) {
+	var d methodDescriptor
+	if s == "" || s[0] != '(' {
+		return d, fmt.Errorf("method descriptors start with '('")
+	}
+	i := 1
+	for i < len(s) && s[i] != ')' {
+		t, next, err := scanFieldType(s, i)
+		if err != nil {
+			return methodDescriptor{}, err
+		}
+		d.parameters = append(d.parameters, t)
+		i = next
+	}
+	if i >= len(s) {
+		return methodDescriptor{}, fmt.Errorf("the parameters are missing their closing ')'")
+	}
+	i++
+	if s[i:] == "V" {
+		return d, nil
+	}
+	result, next, err := scanFieldType(s, i)
+	if err == nil && next < len(s) {
+		err = fmt.Errorf("unexpected %q after the return type at offset %d", s[next], next)
+	}
+	if err != nil {
+		return methodDescriptor{}, err
+	}
+	d.result = &result
+	return d, nil
+}
+
+// scanFieldType reads the field descriptor starting at s[i], returning it
+// along with the index just past it.
+func scanFieldType(s string, i int) (t fieldType, next int, err error) {
+	start := i
+	for i < len(s) && s[i] == '[' {
+		t.dimensions++
+		i++
+	}
+	if t.dimensions > 255 {
+		return fieldType{}, 0, fmt.Errorf("the array at offset %d has more than 255 dimensions", start)
+	}
+	if i >= len(s) {
+		return fieldType{}, 0, fmt.Errorf("expected a type at offset %d but the descriptor ended", i)
+	}
+	t.base = s[i]
+	switch t.base {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		return t, i + 1, nil
+	case 'L':
+		end := strings.IndexByte(s[i:], ';')
+		if end < 0 {
+			return fieldType{}, 0, fmt.Errorf("the class name at offset %d is missing its ';'", i)
+		}
+		t.className = s[i+1 : i+end]
+		if !validClassName(t.className) {
+			return fieldType{}, 0, fmt.Errorf("%q at offset %d is not a valid class name", t.className, i+1)
+		}
+		return t, i + end + 1, nil
+	}
+	return fieldType{}, 0, fmt.Errorf("expected a type at offset %d but found %q", i, s[i])
+}
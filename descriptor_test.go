@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFieldDescriptor(t *testing.T) {
+	tests := []struct {
+		descriptor, want, err string
+		slots                 int
+	}{
+		{"I", "int", "", 1},
+		{"J", "long", "", 2},
+		{"[D", "double[]", "", 1},
+		{"Ljava/lang/String;", "java.lang.String", "", 1},
+		{"[[Ljava/util/Map$Entry;", "java.util.Map$Entry[][]", "", 1},
+		{strings.Repeat("[", 255) + "Z", "boolean" + strings.Repeat("[]", 255), "", 1},
+		{strings.Repeat("[", 256) + "Z", "", "the array at offset 0 has more than 255 dimensions", 0},
+		{"", "", "expected a type at offset 0 but the descriptor ended", 0},
+		{"V", "", "expected a type at offset 0 but found 'V'", 0},
+		{"[", "", "expected a type at offset 1 but the descriptor ended", 0},
+		{"Ljava/lang/String", "", "the class name at offset 0 is missing its ';'", 0},
+		{"L;", "", `"" at offset 1 is not a valid class name`, 0},
+		{"Ljava/lang.String;", "", `"java/lang.String" at offset 1 is not a valid class name`, 0},
+		{"Ljava//String;", "", `"java//String" at offset 1 is not a valid class name`, 0},
+		{"Ljava/lang/String;;", "", "unexpected ';' after the type at offset 18", 0},
+		{"II", "", "unexpected 'I' after the type at offset 1", 0},
+	}
+	for _, test := range tests {
+		d, err := parseFieldDescriptor(test.descriptor)
+		if !checkDescriptorError(t, test.descriptor, err, test.err) {
+			continue
+		}
+		if d.String() != test.want || d.slots() != test.slots || d.descriptor() != test.descriptor {
+			t.Errorf("%q: parsed as %q taking %d slots, want %q taking %d", test.descriptor, d, d.slots(), test.want, test.slots)
+		}
+	}
+}
+
+func TestParseMethodDescriptor(t *testing.T) {
+	tests := []struct {
+		descriptor, want, err string
+		slots                 int
+	}{
+		{"()V", "void m()", "", 0},
+		{"([Ljava/lang/String;)V", "void m(java.lang.String[])", "", 1},
+		{"(IJDLjava/lang/Object;)[[B", "byte[][] m(int, long, double, java.lang.Object)", "", 6},
+		{"(" + strings.Repeat("[", 255) + "I)V", "void m(int" + strings.Repeat("[]", 255) + ")", "", 1},
+		{"(" + strings.Repeat("[", 256) + "I)V", "", "the array at offset 1 has more than 255 dimensions", 0},
+		{"", "", "method descriptors start with '('", 0},
+		{"V", "", "method descriptors start with '('", 0},
+		{"(", "", "the parameters are missing their closing ')'", 0},
+		{"(I", "", "the parameters are missing their closing ')'", 0},
+		{"(V)V", "", "expected a type at offset 1 but found 'V'", 0},
+		{"()", "", "expected a type at offset 2 but the descriptor ended", 0},
+		{"()[V", "", "expected a type at offset 3 but found 'V'", 0},
+		{"(Ljava/lang/String)V", "", "the class name at offset 1 is missing its ';'", 0},
+		{"(Ljava/lang/[I;)V", "", `"java/lang/[I" at offset 2 is not a valid class name`, 0},
+		{"()VV", "", "expected a type at offset 2 but found 'V'", 0},
+		{"()II", "", "unexpected 'I' after the return type at offset 3", 0},
+	}
+	for _, test := range tests {
+		d, err := parseMethodDescriptor(test.descriptor)
+		if !checkDescriptorError(t, test.descriptor, err, test.err) {
+			continue
+		}
+		if d.declaration("m") != test.want || d.slots() != test.slots {
+			t.Errorf("%q: parsed as %q taking %d slots, want %q taking %d", test.descriptor, d.declaration("m"), d.slots(), test.want, test.slots)
+		}
+	}
+}
+
+// checkDescriptorError fails unless a descriptor failed to parse with
+// wantErr, or parsed if wantErr is empty, and reports whether it parsed.
+func checkDescriptorError(t *testing.T, descriptor string, err error, wantErr string) bool {
+	t.Helper()
+	if err == nil && wantErr != "" {
+		t.Errorf("%q: parsed, want error %q", descriptor, wantErr)
+	} else if err != nil && err.Error() != wantErr {
+		t.Errorf("%q: error %q, want %q", descriptor, err, wantErr)
+	}
+	return err == nil && wantErr == ""
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is a problem found while parsing, attached to the bytes that
+// caused it.
+type Diagnostic struct {
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	StartIndex int
+	EndIndex   int
+
+	// Detail is any further explanation, such as the frames the
+	// verifier was comparing.
+	Detail string `json:"detail,omitempty"`
+}
+
+const (
+	errorSeverity   = "error"
+	warningSeverity = "warning"
+)
+
+// diagnostics collects the problems found in a class.
+type diagnostics []Diagnostic
+
+func (d *diagnostics) add(severity string, start, end int, format string, args ...interface{}) {
+	*d = append(*d, Diagnostic{
+		Severity:   severity,
+		Message:    fmt.Sprintf(format, args...),
+		StartIndex: start,
+		EndIndex:   end,
+	})
+}
+
+func (d *diagnostics) errorf(start, end int, format string, args ...interface{}) {
+	d.add(errorSeverity, start, end, format, args...)
+}
+
+func (d *diagnostics) warnf(start, end int, format string, args ...interface{}) {
+	d.add(warningSeverity, start, end, format, args...)
+}
+
+// sortDiagnostics orders diagnostics by where they occur in the class.
+func sortDiagnostics(d []Diagnostic) {
+	sort.SliceStable(d, func(i, j int) bool {
+		return d[i].StartIndex < d[j].StartIndex
+	})
+}
+
+// hasErrors reports whether any of d are errors rather than warnings.
+func hasErrors(d []Diagnostic) bool {
+	for _, x := range d {
+		if x.Severity == errorSeverity {
+			return true
+		}
+	}
+	return false
+}
+
+// truncated reports whether fewer than n bytes remain at index. If so the
+// rest of the class can't be made sense of, so parsing is abandoned.
+func (p *sectionParser) truncated(bytes []byte, index, n int, what string) bool {
+	if index+n <= len(bytes) {
+		return false
+	}
+	if index >= len(bytes) {
+		p.abandon(len(bytes), len(bytes), "class file ends before the %s", what)
+	} else {
+		p.abandon(index, len(bytes), "class file ends in the middle of the %s", what)
+	}
+	return true
+}
+
+// abandon records an error after which the rest of the class can't be
+// parsed, such as an unknown constant pool tag.
+func (p *sectionParser) abandon(start, end int, format string, args ...interface{}) {
+	p.errorf(start, end, format, args...)
+	p.abandoned = true
+}
+
+// checkReference records an error if the constant pool index held in the
+// bytes between start and end doesn't refer to one of the expected kinds
+// of constant.
+func (p *sectionParser) checkReference(start, end int, index uint16, expected ...string) {
+	if _, ok := p.constantPoolItem(index); !ok {
+		p.errorf(start, end, "constant pool index #%d is out of range", index)
+	} else if marker := p.checkConstant(index, expected...); marker != "" {
+		p.errorf(start, end, "constant pool index #%d: %s", index, strings.Trim(marker, "<>"))
+	}
+}
+
+// resolveAt is resolve for the u2 index stored at start, recording an
+// error if it is not a valid reference.
+func (p *sectionParser) resolveAt(start int, index uint16, expected ...string) string {
+	p.checkReference(start, start+2, index, expected...)
+	return p.resolve(index, expected...)
+}
+
+// resolveOptionalAt is resolveAt for indexes where zero means "none".
+func (p *sectionParser) resolveOptionalAt(start int, index uint16, expected ...string) string {
+	if index == 0 {
+		return p.resolveOptional(index)
+	}
+	return p.resolveAt(start, index, expected...)
+}
+
+// malformedInfo records that an attribute's contents don't match its
+// length and shows them as an opaque blob instead.
+func (p *sectionParser) malformedInfo(name string, start, end int) []Section {
+	p.errorf(start, end, "%s attribute does not fit its length of %d bytes", name, end-start)
+	return p.opaqueInfo(start, end)
+}
+
+// leftoverInfo records an error for any bytes of an attribute that its
+// contents didn't account for, and shows them as an opaque blob.
+func (p *sectionParser) leftoverInfo(name string, start, end int) []Section {
+	if start < end {
+		p.errorf(start, end, "%d unexpected bytes at the end of the %s attribute", end-start, name)
+	}
+	return p.opaqueInfo(start, end)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// coverage returns a description of the first place the sections fail to
+// cover the bytes between start and end, or "" if they cover all of them.
+// When tile is set the sections must follow one another without gaps or
+// overlaps; children may share bytes, as the bits of access flags do.
+func coverage(sections []Section, start, end int, tile bool) string {
+	covered := make([]bool, end-start)
+	next := start
+	for _, s := range sections {
+		if s.StartIndex < start || s.EndIndex > end || s.StartIndex > s.EndIndex {
+			return fmt.Sprintf("%q at %d-%d is outside %d-%d", s.Name, s.StartIndex, s.EndIndex, start, end)
+		}
+		if tile && s.StartIndex != next {
+			return fmt.Sprintf("%q starts at %d, not %d", s.Name, s.StartIndex, next)
+		}
+		next = s.EndIndex
+		if len(s.Children) > 0 {
+			if problem := coverage(s.Children, s.StartIndex, s.EndIndex, false); problem != "" {
+				return problem
+			}
+		}
+		for i := s.StartIndex; i < s.EndIndex; i++ {
+			covered[i-start] = true
+		}
+	}
+	for i, ok := range covered {
+		if !ok {
+			return fmt.Sprintf("nothing covers byte %d", start+i)
+		}
+	}
+	return ""
+}
+
+// checkCoverage fails unless the top-level sections cover a class of
+// length bytes one after another, and each section's children cover it.
+func checkCoverage(t *testing.T, name string, sections []Section, length int) {
+	t.Helper()
+	if problem := coverage(sections, 0, length, true); problem != "" {
+		t.Errorf("%s: %s", name, problem)
+	}
+}
+
+func TestParseClassCoverage(t *testing.T) {
+	var names []string
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append(names, "HelloWorld.class")
+	for _, name := range names {
+		data := readFixture(t, name)
+		for n := 0; n <= len(data); n++ {
+			sections, _ := parseClass(data[:n])
+			checkCoverage(t, fmt.Sprintf("%s cut to %d bytes", name, n), sections, n)
+		}
+	}
+
+	data := readFixture(t, "Branches6.class")
+	for n := range data {
+		for _, b := range []byte{0x00, 0xff} {
+			corrupt := append([]byte(nil), data...)
+			corrupt[n] = b
+			sections, _ := parseClass(corrupt)
+			checkCoverage(t, fmt.Sprintf("%s with byte %d set to %#x", "Branches6.class", n, b), sections, len(corrupt))
+		}
+	}
+}
+
+func TestParseClassDiagnostics(t *testing.T) {
+	data := readFixture(t, "Branches6.class")
+	set := func(at int, b ...byte) []byte {
+		corrupt := append([]byte(nil), data...)
+		copy(corrupt[at:], b)
+		return corrupt
+	}
+	// Offsets are into Branches6.class: the constant pool's third item
+	// starts at 32, the first method at 327 and its Code attribute at 337.
+	tests := []struct {
+		name  string
+		class []byte
+		last  string
+		want  []Diagnostic
+	}{
+		{
+			name: "empty",
+			want: []Diagnostic{{Severity: "error", StartIndex: 0, EndIndex: 0, Message: "class file ends before the magic number"}},
+		},
+		{
+			name:  "cut short in the constant pool",
+			class: data[:40],
+			last:  "unparsed: 8 bytes",
+			want:  []Diagnostic{{Severity: "error", StartIndex: 35, EndIndex: 40, Message: "class file ends in the middle of the constant pool"}},
+		},
+		{
+			name:  "cut short in a Code attribute",
+			class: data[:400],
+			last:  "class has 7 methods",
+			want: []Diagnostic{
+				{Severity: "error", StartIndex: 370, EndIndex: 374, Message: "attribute Code is 34 bytes long but only 26 bytes remain"},
+				{Severity: "error", StartIndex: 395, EndIndex: 397, Message: "attributes count is 1 but only 0 attributes fit"},
+				{Severity: "error", StartIndex: 400, EndIndex: 400, Message: "class file ends before the methods"},
+			},
+		},
+		{
+			name:  "unknown constant pool tag",
+			class: set(32, 2),
+			last:  "unparsed: 714 bytes",
+			want:  []Diagnostic{{Severity: "error", StartIndex: 32, EndIndex: 33, Message: "constant pool item 3 has unknown tag 2"}},
+		},
+		{
+			name:  "bad magic number",
+			class: set(0, 0xca, 0xfe, 0xd0, 0x0d),
+			last:  "class has 0 attributes",
+			want:  []Diagnostic{{Severity: "error", StartIndex: 0, EndIndex: 4, Message: "magic number is 0xcafed00d, expected 0xCAFEBABE"}},
+		},
+		{
+			name:  "method name index out of range",
+			class: set(331, 0, 99),
+			last:  "class has 0 attributes",
+			want:  []Diagnostic{{Severity: "error", StartIndex: 331, EndIndex: 333, Message: "constant pool index #99 is out of range"}},
+		},
+		{
+			name:  "attribute name index of the wrong kind",
+			class: set(337, 0, 2),
+			last:  "class has 0 attributes",
+			want:  []Diagnostic{{Severity: "error", StartIndex: 337, EndIndex: 339, Message: "constant pool index #2: expected UTF-8 string, found class info"}},
+		},
+		{
+			name:  "attribute longer than the class",
+			class: set(339, 0, 0, 0xff, 0xff),
+			last:  "class has 7 methods",
+			want: []Diagnostic{
+				{Severity: "error", StartIndex: 339, EndIndex: 343, Message: "attribute Code is 65535 bytes long but only 403 bytes remain"},
+				{Severity: "error", StartIndex: 360, EndIndex: 746, Message: "386 unexpected bytes at the end of the Code attribute"},
+				{Severity: "error", StartIndex: 746, EndIndex: 746, Message: "class file ends before the methods"},
+			},
+		},
+		{
+			name:  "trailing bytes",
+			class: append(append([]byte(nil), data...), 1, 2, 3),
+			last:  "unparsed: 3 bytes",
+			want:  []Diagnostic{{Severity: "error", StartIndex: 746, EndIndex: 749, Message: "3 bytes after the end of the class"}},
+		},
+	}
+	for _, test := range tests {
+		sections, diagnostics := parseClass(test.class)
+		checkCoverage(t, test.name, sections, len(test.class))
+		if len(sections) > 0 {
+			if last := sections[len(sections)-1].Name; last != test.last {
+				t.Errorf("%s: parsing stopped at %q, want %q", test.name, last, test.last)
+			}
+		}
+		if !reflect.DeepEqual(diagnostics, test.want) {
+			t.Errorf("%s: diagnostics\n%v\nwant\n%v", test.name, diagnostics, test.want)
+		}
+	}
+}
@@ -0,0 +1,789 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the files in testdata")
+
+// fixtures builds the class files checked in under testdata. They are
+// assembled by hand, not compiled: each is a class of the version named in
+// its comment for the source shown there, but no javac wrote its bytes.
+var fixtures = map[string]func() []byte{
+	"Hello11.class":      hello11,
+	"Box5.class":         box5,
+	"Branches6.class":    branches6,
+	"Lambda8.class":      lambda8,
+	"Nest11.class":       nest11,
+	"Nest11$Inner.class": nest11Inner,
+	"Tag.class":          tag,
+	"Annotated.class":    annotated,
+	"Condy.class":        condy,
+	"module-info.class":  moduleInfo11,
+	"Point17.class":      point17,
+	"Shape25.class":      shape25,
+}
+
+func TestFixtures(t *testing.T) {
+	var names []string
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join("testdata", name)
+		got := fixtures[name]()
+		if *update {
+			if err := ioutil.WriteFile(path, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date, run go test -update", path)
+		}
+	}
+}
+
+// readFixture returns a class file from testdata, or the HelloWorld class
+// that javac 8 compiled for the server's front page.
+func readFixture(t *testing.T, name string) []byte {
+	path := filepath.Join("testdata", name)
+	if name == "HelloWorld.class" {
+		path = filepath.Join("static", name)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// constructor returns the default constructor javac adds to a class.
+func (b *classBuilder) constructor(super string, attrs ...[]byte) []byte {
+	return b.member(0x0001, "<init>", "()V",
+		b.code(1, 1, join(
+			op("aload_0"),
+			op("invokespecial"), u2(b.methodref(super, "<init>", "()V")),
+			op("return"),
+		), nil, attrs...))
+}
+
+// lookup returns the InnerClasses entry javac adds for MethodHandles.Lookup
+// to classes that use invokedynamic.
+func (b *classBuilder) lookup() []byte {
+	return join(
+		u2(b.class("java/lang/invoke/MethodHandles$Lookup")),
+		u2(b.class("java/lang/invoke/MethodHandles")),
+		u2(b.utf8("Lookup")),
+		u2(0x0019))
+}
+
+// Hello11.class, assembled by hand at class file version 45.3 (Java 1.1)
+// with the debug attributes of -g, for
+//
+//	public class Hello11 {
+//	    public static void main(String[] args) {
+//	        System.out.println("Hello, 1.1");
+//	    }
+//	}
+func hello11() []byte {
+	b := newClassBuilder()
+	main := b.member(0x0009, "main", "([Ljava/lang/String;)V",
+		b.code(2, 1, join(
+			op("getstatic"), u2(b.fieldref("java/lang/System", "out", "Ljava/io/PrintStream;")),
+			op("ldc"), u1(b.string("Hello, 1.1")),
+			op("invokevirtual"), u2(b.methodref("java/io/PrintStream", "println", "(Ljava/lang/String;)V")),
+			op("return"),
+		), nil,
+			b.attribute("LineNumberTable", u2(2), u2(0), u2(3), u2(8), u2(4)),
+			b.attribute("LocalVariableTable", u2(1),
+				u2(0), u2(9), u2(b.utf8("args")), u2(b.utf8("[Ljava/lang/String;")), u2(0))))
+	init := b.constructor("java/lang/Object",
+		b.attribute("LineNumberTable", u2(1), u2(0), u2(1)),
+		b.attribute("LocalVariableTable", u2(1),
+			u2(0), u2(5), u2(b.utf8("this")), u2(b.utf8("LHello11;")), u2(0)))
+	return b.build(classSpec{
+		minor: 3, major: 45,
+		flags: 0x0021,
+		this:  "Hello11", super: "java/lang/Object",
+		methods:    [][]byte{main, init},
+		attributes: [][]byte{b.attribute("SourceFile", u2(b.utf8("Hello11.java")))},
+	})
+}
+
+// Box5.class, assembled by hand at class file version 49 (Java 5) for
+//
+//	@Deprecated
+//	public class Box5<T extends Comparable<T>> implements java.io.Serializable {
+//	    public static final long serialVersionUID = 1L;
+//	    private T value;
+//	    public Box5(T value) { this.value = value; }
+//	    public T get() { return value; }
+//	    public <E extends Exception> void check() throws E {}
+//	    public static <T extends Comparable<T>> Box5<T> of(T... values) {
+//	        return new Box5<T>(values[0]);
+//	    }
+//	}
+func box5() []byte {
+	b := newClassBuilder()
+	fields := [][]byte{
+		b.member(0x0019, "serialVersionUID", "J",
+			b.attribute("ConstantValue", u2(b.long(1)))),
+		b.member(0x0002, "value", "Ljava/lang/Comparable;",
+			b.attribute("Signature", u2(b.utf8("TT;")))),
+	}
+	methods := [][]byte{
+		b.member(0x0001, "<init>", "(Ljava/lang/Comparable;)V",
+			b.code(2, 2, join(
+				op("aload_0"),
+				op("invokespecial"), u2(b.methodref("java/lang/Object", "<init>", "()V")),
+				op("aload_0"),
+				op("aload_1"),
+				op("putfield"), u2(b.fieldref("Box5", "value", "Ljava/lang/Comparable;")),
+				op("return"),
+			), nil),
+			b.attribute("Signature", u2(b.utf8("(TT;)V")))),
+		b.member(0x0001, "get", "()Ljava/lang/Comparable;",
+			b.code(1, 1, join(
+				op("aload_0"),
+				op("getfield"), u2(b.fieldref("Box5", "value", "Ljava/lang/Comparable;")),
+				op("areturn"),
+			), nil),
+			b.attribute("Signature", u2(b.utf8("()TT;")))),
+		b.member(0x0001, "check", "()V",
+			b.code(0, 1, op("return"), nil),
+			b.attribute("Exceptions", u2(1), u2(b.class("java/lang/Exception"))),
+			b.attribute("Signature", u2(b.utf8("<E:Ljava/lang/Exception;>()V^TE;")))),
+		b.member(0x0089, "of", "([Ljava/lang/Comparable;)LBox5;",
+			b.code(4, 1, join(
+				op("new"), u2(b.class("Box5")),
+				op("dup"),
+				op("aload_0"),
+				op("iconst_0"),
+				op("aaload"),
+				op("invokespecial"), u2(b.methodref("Box5", "<init>", "(Ljava/lang/Comparable;)V")),
+				op("areturn"),
+			), nil),
+			b.attribute("Signature", u2(b.utf8("<T::Ljava/lang/Comparable<TT;>;>([TT;)LBox5<TT;>;")))),
+	}
+	return b.build(classSpec{
+		major: 49,
+		flags: 0x0021,
+		this:  "Box5", super: "java/lang/Object",
+		interfaces: []string{"java/io/Serializable"},
+		fields:     fields,
+		methods:    methods,
+		attributes: [][]byte{
+			b.attribute("Deprecated"),
+			b.attribute("Signature", u2(b.utf8("<T::Ljava/lang/Comparable<TT;>;>Ljava/lang/Object;Ljava/io/Serializable;"))),
+			b.attribute("RuntimeVisibleAnnotations", u2(1), u2(b.utf8("Ljava/lang/Deprecated;")), u2(0)),
+		},
+	})
+}
+
+// Branches6.class, assembled by hand at class file version 50 (Java 6) for
+//
+//	public class Branches6 {
+//	    static int max(int a, int b) { return a > b ? a : b; }
+//	    static int sum(int[] xs) {
+//	        int s = 0;
+//	        for (int i = 0; i < xs.length; i++) s += xs[i];
+//	        return s;
+//	    }
+//	    static String name(int n) {
+//	        switch (n) { case 1: return "one"; case 2: return "two"; default: return "many"; }
+//	    }
+//	    static int kind(int n) {
+//	        switch (n) { case -1: return 0; case 1000: return 1; default: return 2; }
+//	    }
+//	    static int parse(String s) {
+//	        try { return Integer.parseInt(s); } catch (NumberFormatException e) { return -1; }
+//	    }
+//	    static int count(int a) {
+//	        int b = a, c = a, d = a, e = a;
+//	        while (b > 0) b--;
+//	        return b + c + d + e;
+//	    }
+//	}
+func branches6() []byte {
+	b := newClassBuilder()
+	stackMap := func(entries int, frames ...[]byte) []byte {
+		return b.attribute("StackMapTable", u2(entries), join(frames...))
+	}
+	const integer = 1
+	object := func(class string) []byte {
+		return join(u1(7), u2(b.class(class)))
+	}
+	methods := [][]byte{
+		b.constructor("java/lang/Object"),
+		b.member(0x0008, "max", "(II)I",
+			b.code(2, 2, join(
+				op("iload_0"),
+				op("iload_1"),
+				op("if_icmple"), u2(7),
+				op("iload_0"),
+				op("goto"), u2(4),
+				op("iload_1"),
+				op("ireturn"),
+			), nil,
+				stackMap(2, u1(9), u1(64), u1(integer)))),
+		b.member(0x0008, "sum", "([I)I",
+			b.code(3, 3, join(
+				op("iconst_0"),
+				op("istore_1"),
+				op("iconst_0"),
+				op("istore_2"),
+				op("iload_2"),
+				op("aload_0"),
+				op("arraylength"),
+				op("if_icmpge"), u2(15),
+				op("iload_1"),
+				op("aload_0"),
+				op("iload_2"),
+				op("iaload"),
+				op("iadd"),
+				op("istore_1"),
+				op("iinc"), u1(2), u1(1),
+				op("goto"), u2(-15),
+				op("iload_1"),
+				op("ireturn"),
+			), nil,
+				stackMap(2,
+					join(u1(253), u2(4), u1(integer), u1(integer)),
+					join(u1(250), u2(17))))),
+		b.member(0x0008, "name", "(I)Ljava/lang/String;",
+			b.code(1, 1, join(
+				op("iload_0"),
+				op("tableswitch"), u1(0), u1(0),
+				u4(29), u4(1), u4(2), u4(23), u4(26),
+				op("ldc"), u1(b.string("one")),
+				op("areturn"),
+				op("ldc"), u1(b.string("two")),
+				op("areturn"),
+				op("ldc"), u1(b.string("many")),
+				op("areturn"),
+			), nil,
+				stackMap(3, u1(24), u1(2), u1(2)))),
+		b.member(0x0008, "kind", "(I)I",
+			b.code(1, 1, join(
+				op("iload_0"),
+				op("lookupswitch"), u1(0), u1(0),
+				u4(31), u4(2), u4(-1), u4(27), u4(1000), u4(29),
+				op("iconst_0"),
+				op("ireturn"),
+				op("iconst_1"),
+				op("ireturn"),
+				op("iconst_2"),
+				op("ireturn"),
+			), nil,
+				stackMap(3, u1(28), u1(1), u1(1)))),
+		b.member(0x0008, "parse", "(Ljava/lang/String;)I",
+			b.code(1, 2, join(
+				op("aload_0"),
+				op("invokestatic"), u2(b.methodref("java/lang/Integer", "parseInt", "(Ljava/lang/String;)I")),
+				op("ireturn"),
+				op("astore_1"),
+				op("iconst_m1"),
+				op("ireturn"),
+			), []handler{{0, 4, 5, "java/lang/NumberFormatException"}},
+				stackMap(1, join(u1(69), object("java/lang/NumberFormatException"))))),
+		b.member(0x0008, "count", "(I)I",
+			b.code(2, 5, join(
+				op("iload_0"),
+				op("istore_1"),
+				op("iload_0"),
+				op("istore_2"),
+				op("iload_0"),
+				op("istore_3"),
+				op("iload_0"),
+				op("istore"), u1(4),
+				op("iload_1"),
+				op("ifle"), u2(9),
+				op("iinc"), u1(1), u1(-1),
+				op("goto"), u2(-7),
+				op("iload_1"),
+				op("iload_2"),
+				op("iadd"),
+				op("iload_3"),
+				op("iadd"),
+				op("iload"), u1(4),
+				op("iadd"),
+				op("ireturn"),
+			), nil,
+				stackMap(2,
+					join(u1(255), u2(9), u2(5), u1(integer), u1(integer), u1(integer), u1(integer), u1(integer), u2(0)),
+					u1(9)))),
+	}
+	return b.build(classSpec{
+		major: 50,
+		flags: 0x0021,
+		this:  "Branches6", super: "java/lang/Object",
+		methods: methods,
+	})
+}
+
+// Lambda8.class, assembled by hand at class file version 52 (Java 8) for
+//
+//	import java.util.function.Supplier;
+//
+//	public class Lambda8 {
+//	    public static void main(String[] args) {
+//	        Supplier<String> s = () -> "hi";
+//	        System.out.println(s.get());
+//	    }
+//	}
+func lambda8() []byte {
+	b := newClassBuilder()
+	metafactory := b.methodHandle(6, b.methodref("java/lang/invoke/LambdaMetafactory", "metafactory",
+		"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodHandle;Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/CallSite;"))
+	bootstrapMethods := b.attribute("BootstrapMethods", u2(1),
+		u2(metafactory), u2(3),
+		u2(b.methodType("()Ljava/lang/Object;")),
+		u2(b.methodHandle(6, b.methodref("Lambda8", "lambda$main$0", "()Ljava/lang/String;"))),
+		u2(b.methodType("()Ljava/lang/String;")))
+	methods := [][]byte{
+		b.constructor("java/lang/Object"),
+		b.member(0x0009, "main", "([Ljava/lang/String;)V",
+			b.code(2, 2, join(
+				op("invokedynamic"), u2(b.invokeDynamic(0, "get", "()Ljava/util/function/Supplier;")), u2(0),
+				op("astore_1"),
+				op("getstatic"), u2(b.fieldref("java/lang/System", "out", "Ljava/io/PrintStream;")),
+				op("aload_1"),
+				op("invokeinterface"), u2(b.interfaceMethodref("java/util/function/Supplier", "get", "()Ljava/lang/Object;")), u1(1), u1(0),
+				op("checkcast"), u2(b.class("java/lang/String")),
+				op("invokevirtual"), u2(b.methodref("java/io/PrintStream", "println", "(Ljava/lang/String;)V")),
+				op("return"),
+			), nil)),
+		b.member(0x100a, "lambda$main$0", "()Ljava/lang/String;",
+			b.code(1, 0, join(
+				op("ldc"), u1(b.string("hi")),
+				op("areturn"),
+			), nil)),
+	}
+	return b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "Lambda8", super: "java/lang/Object",
+		methods: methods,
+		attributes: [][]byte{
+			b.attribute("InnerClasses", u2(1), b.lookup()),
+			bootstrapMethods,
+		},
+	})
+}
+
+// Nest11.class, assembled by hand at class file version 55 (Java 11) for
+//
+//	public class Nest11 {
+//	    private int count;
+//	    class Inner { int get() { return count; } }
+//	    String describe() { return "count=" + count; }
+//	}
+func nest11() []byte {
+	b := newClassBuilder()
+	concat := b.methodHandle(6, b.methodref("java/lang/invoke/StringConcatFactory", "makeConcatWithConstants",
+		"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"))
+	bootstrapMethods := b.attribute("BootstrapMethods", u2(1),
+		u2(concat), u2(1), u2(b.string("count=\u0001")))
+	inner := join(u2(b.class("Nest11$Inner")), u2(b.class("Nest11")), u2(b.utf8("Inner")), u2(0))
+	return b.build(classSpec{
+		major: 55,
+		flags: 0x0021,
+		this:  "Nest11", super: "java/lang/Object",
+		fields: [][]byte{b.member(0x0002, "count", "I")},
+		methods: [][]byte{
+			b.constructor("java/lang/Object"),
+			b.member(0x0000, "describe", "()Ljava/lang/String;",
+				b.code(1, 1, join(
+					op("aload_0"),
+					op("getfield"), u2(b.fieldref("Nest11", "count", "I")),
+					op("invokedynamic"), u2(b.invokeDynamic(0, "makeConcatWithConstants", "(I)Ljava/lang/String;")), u2(0),
+					op("areturn"),
+				), nil)),
+		},
+		attributes: [][]byte{
+			b.attribute("NestMembers", u2(1), u2(b.class("Nest11$Inner"))),
+			bootstrapMethods,
+			b.attribute("InnerClasses", u2(2), inner, b.lookup()),
+		},
+	})
+}
+
+// Nest11$Inner.class, from the same source as Nest11.class.
+func nest11Inner() []byte {
+	b := newClassBuilder()
+	return b.build(classSpec{
+		major: 55,
+		flags: 0x0020,
+		this:  "Nest11$Inner", super: "java/lang/Object",
+		fields: [][]byte{b.member(0x1010, "this$0", "LNest11;")},
+		methods: [][]byte{
+			b.member(0x0000, "<init>", "(LNest11;)V",
+				b.code(2, 2, join(
+					op("aload_0"),
+					op("aload_1"),
+					op("putfield"), u2(b.fieldref("Nest11$Inner", "this$0", "LNest11;")),
+					op("aload_0"),
+					op("invokespecial"), u2(b.methodref("java/lang/Object", "<init>", "()V")),
+					op("return"),
+				), nil)),
+			b.member(0x0000, "get", "()I",
+				b.code(1, 1, join(
+					op("aload_0"),
+					op("getfield"), u2(b.fieldref("Nest11$Inner", "this$0", "LNest11;")),
+					op("getfield"), u2(b.fieldref("Nest11", "count", "I")),
+					op("ireturn"),
+				), nil)),
+		},
+		attributes: [][]byte{
+			b.attribute("NestHost", u2(b.class("Nest11"))),
+			b.attribute("InnerClasses", u2(1),
+				u2(b.class("Nest11$Inner")), u2(b.class("Nest11")), u2(b.utf8("Inner")), u2(0)),
+		},
+	})
+}
+
+// Tag.class, assembled by hand at class file version 55 (Java 11) for
+//
+//	import java.lang.annotation.*;
+//
+//	@Retention(RetentionPolicy.RUNTIME)
+//	@Target({ElementType.TYPE_USE, ElementType.TYPE})
+//	public @interface Tag {
+//	    String value() default "none";
+//	    int[] ids() default {};
+//	    Class<?> type() default Object.class;
+//	    RetentionPolicy policy() default RetentionPolicy.RUNTIME;
+//	    long weight() default 1L;
+//	}
+func tag() []byte {
+	b := newClassBuilder()
+	policy := "Ljava/lang/annotation/RetentionPolicy;"
+	elementType := "Ljava/lang/annotation/ElementType;"
+	enum := func(typeName, constant string) []byte {
+		return join(u1('e'), u2(b.utf8(typeName)), u2(b.utf8(constant)))
+	}
+	return b.build(classSpec{
+		major: 55,
+		flags: 0x2601,
+		this:  "Tag", super: "java/lang/Object",
+		interfaces: []string{"java/lang/annotation/Annotation"},
+		methods: [][]byte{
+			b.member(0x0401, "value", "()Ljava/lang/String;",
+				b.attribute("AnnotationDefault", u1('s'), u2(b.utf8("none")))),
+			b.member(0x0401, "ids", "()[I",
+				b.attribute("AnnotationDefault", u1('['), u2(0))),
+			b.member(0x0401, "type", "()Ljava/lang/Class;",
+				b.attribute("AnnotationDefault", u1('c'), u2(b.utf8("Ljava/lang/Object;"))),
+				b.attribute("Signature", u2(b.utf8("()Ljava/lang/Class<*>;")))),
+			b.member(0x0401, "policy", "()"+policy,
+				b.attribute("AnnotationDefault", enum(policy, "RUNTIME"))),
+			b.member(0x0401, "weight", "()J",
+				b.attribute("AnnotationDefault", u1('J'), u2(b.long(1)))),
+		},
+		attributes: [][]byte{
+			b.attribute("RuntimeVisibleAnnotations", u2(2),
+				u2(b.utf8("Ljava/lang/annotation/Retention;")), u2(1),
+				u2(b.utf8("value")), enum(policy, "RUNTIME"),
+				u2(b.utf8("Ljava/lang/annotation/Target;")), u2(1),
+				u2(b.utf8("value")), u1('['), u2(2), enum(elementType, "TYPE_USE"), enum(elementType, "TYPE")),
+		},
+	})
+}
+
+// Annotated.class, assembled by hand at class file version 55 (Java 11) for
+//
+//	@Tag("class")
+//	public class Annotated<@Tag T> extends @Tag("super") Object {
+//	    @Deprecated
+//	    java.util.List<@Tag("arg") String> names;
+//
+//	    @Tag("ret") String m(@Deprecated int a, @Tag("elem") String[] b) throws @Tag("ex") Exception {
+//	        @Tag("local") String s = b[0];
+//	        try {
+//	            s = new @Tag("new") String(s);
+//	        } catch (@Tag("catch") RuntimeException e) {
+//	        }
+//	        return s;
+//	    }
+//	}
+func annotated() []byte {
+	b := newClassBuilder()
+	tag := func(value string) []byte {
+		if value == "" {
+			return join(u2(b.utf8("LTag;")), u2(0))
+		}
+		return join(u2(b.utf8("LTag;")), u2(1), u2(b.utf8("value")), u1('s'), u2(b.utf8(value)))
+	}
+	deprecated := join(u2(b.utf8("Ljava/lang/Deprecated;")), u2(0))
+	const (
+		typeParameter      = 0x00
+		classExtends       = 0x10
+		field              = 0x13
+		methodReturn       = 0x14
+		formalParameter    = 0x16
+		throws             = 0x17
+		localVariable      = 0x40
+		exceptionParameter = 0x42
+		newExpression      = 0x44
+	)
+	noPath := u1(0)
+	code := b.code(3, 5, join(
+		op("aload_2"),
+		op("iconst_0"),
+		op("aaload"),
+		op("astore_3"),
+		op("new"), u2(b.class("java/lang/String")),
+		op("dup"),
+		op("aload_3"),
+		op("invokespecial"), u2(b.methodref("java/lang/String", "<init>", "(Ljava/lang/String;)V")),
+		op("astore_3"),
+		op("goto"), u2(5),
+		op("astore"), u1(4),
+		op("aload_3"),
+		op("areturn"),
+	), []handler{{4, 13, 16, "java/lang/RuntimeException"}},
+		b.attribute("StackMapTable", u2(2),
+			u1(255), u2(16), u2(4),
+			u1(7), u2(b.class("Annotated")), u1(1), u1(7), u2(b.class("[Ljava/lang/String;")), u1(7), u2(b.class("java/lang/String")),
+			u2(1), u1(7), u2(b.class("java/lang/RuntimeException")),
+			u1(1)),
+		b.attribute("RuntimeVisibleTypeAnnotations", u2(3),
+			u1(localVariable), u2(1), u2(4), u2(16), u2(3), noPath, tag("local"),
+			u1(newExpression), u2(4), noPath, tag("new"),
+			u1(exceptionParameter), u2(0), noPath, tag("catch")))
+	return b.build(classSpec{
+		major: 55,
+		flags: 0x0021,
+		this:  "Annotated", super: "java/lang/Object",
+		fields: [][]byte{
+			b.member(0x0000, "names", "Ljava/util/List;",
+				b.attribute("Deprecated"),
+				b.attribute("Signature", u2(b.utf8("Ljava/util/List<Ljava/lang/String;>;"))),
+				b.attribute("RuntimeVisibleAnnotations", u2(1), deprecated),
+				b.attribute("RuntimeVisibleTypeAnnotations", u2(1),
+					u1(field), u1(1), u1(3), u1(0), tag("arg"))),
+		},
+		methods: [][]byte{
+			b.constructor("java/lang/Object"),
+			b.member(0x0000, "m", "(I[Ljava/lang/String;)Ljava/lang/String;",
+				code,
+				b.attribute("Exceptions", u2(1), u2(b.class("java/lang/Exception"))),
+				b.attribute("RuntimeVisibleParameterAnnotations", u1(2), u2(1), deprecated, u2(0)),
+				b.attribute("RuntimeVisibleTypeAnnotations", u2(3),
+					u1(methodReturn), noPath, tag("ret"),
+					u1(formalParameter), u1(1), u1(1), u1(0), u1(0), tag("elem"),
+					u1(throws), u2(0), noPath, tag("ex"))),
+		},
+		attributes: [][]byte{
+			b.attribute("Signature", u2(b.utf8("<T:Ljava/lang/Object;>Ljava/lang/Object;"))),
+			b.attribute("RuntimeVisibleAnnotations", u2(1), tag("class")),
+			b.attribute("RuntimeVisibleTypeAnnotations", u2(2),
+				u1(typeParameter), u1(0), noPath, tag(""),
+				u1(classExtends), u2(0xffff), noPath, tag("super")),
+		},
+	})
+}
+
+// Condy.class, assembled by hand at class file version 55 (Java 11). javac
+// never emits CONSTANT_Dynamic, so this stands in for what a bytecode
+// library would write for:
+//
+//	public class Condy {
+//	    public static Object value() { return ldc Dynamic _:Ljava/lang/Object; }
+//	}
+func condy() []byte {
+	b := newClassBuilder()
+	nullConstant := b.methodHandle(6, b.methodref("java/lang/invoke/ConstantBootstraps", "nullConstant",
+		"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/Class;)Ljava/lang/Object;"))
+	primitive := b.methodHandle(6, b.methodref("java/lang/invoke/ConstantBootstraps", "primitiveClass",
+		"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/Class;)Ljava/lang/Class;"))
+	return b.build(classSpec{
+		major: 55,
+		flags: 0x0021,
+		this:  "Condy", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0009, "value", "()Ljava/lang/Object;",
+				b.code(1, 0, join(
+					op("ldc"), u1(b.dynamic(0, "_", "Ljava/lang/Object;")),
+					op("areturn"),
+				), nil)),
+			b.member(0x0009, "type", "()Ljava/lang/Class;",
+				b.code(1, 0, join(
+					op("ldc"), u1(b.dynamic(1, "I", "Ljava/lang/Class;")),
+					op("areturn"),
+				), nil)),
+		},
+		attributes: [][]byte{
+			b.attribute("BootstrapMethods", u2(2),
+				u2(nullConstant), u2(0),
+				u2(primitive), u2(0)),
+			b.attribute("InnerClasses", u2(1), b.lookup()),
+		},
+	})
+}
+
+// module-info.class, assembled by hand at class file version 55 (Java 11)
+// with the ModulePackages and ModuleMainClass attributes that jar
+// --main-class com.example.app.Main adds, for
+//
+//	module com.example.app {
+//	    requires java.logging;
+//	    requires transitive java.sql;
+//	    exports com.example.app.api;
+//	    exports com.example.app.spi to com.example.plugin;
+//	    opens com.example.app.model;
+//	    uses com.example.app.spi.Plugin;
+//	    provides com.example.app.spi.Plugin with com.example.app.internal.DefaultPlugin;
+//	}
+func moduleInfo11() []byte {
+	b := newClassBuilder()
+	version := b.utf8("11")
+	module := b.attribute("Module",
+		u2(b.module("com.example.app")), u2(0), u2(0),
+		u2(3),
+		u2(b.module("java.base")), u2(0x8000), u2(version),
+		u2(b.module("java.logging")), u2(0), u2(version),
+		u2(b.module("java.sql")), u2(0x0020), u2(version),
+		u2(2),
+		u2(b.pkg("com/example/app/api")), u2(0), u2(0),
+		u2(b.pkg("com/example/app/spi")), u2(0), u2(1), u2(b.module("com.example.plugin")),
+		u2(1),
+		u2(b.pkg("com/example/app/model")), u2(0), u2(0),
+		u2(1),
+		u2(b.class("com/example/app/spi/Plugin")),
+		u2(1),
+		u2(b.class("com/example/app/spi/Plugin")), u2(1), u2(b.class("com/example/app/internal/DefaultPlugin")))
+	return b.build(classSpec{
+		major: 55,
+		flags: 0x8000,
+		this:  "module-info",
+		attributes: [][]byte{
+			module,
+			b.attribute("ModulePackages", u2(5),
+				u2(b.pkg("com/example/app")),
+				u2(b.pkg("com/example/app/api")),
+				u2(b.pkg("com/example/app/spi")),
+				u2(b.pkg("com/example/app/model")),
+				u2(b.pkg("com/example/app/internal"))),
+			b.attribute("ModuleMainClass", u2(b.class("com/example/app/Main"))),
+		},
+	})
+}
+
+// Point17.class, assembled by hand at class file version 61 (Java 17) for
+//
+//	public record Point17(int x, int y) {}
+func point17() []byte {
+	b := newClassBuilder()
+	objectMethods := b.methodHandle(6, b.methodref("java/lang/runtime/ObjectMethods", "bootstrap",
+		"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/TypeDescriptor;Ljava/lang/Class;Ljava/lang/String;[Ljava/lang/invoke/MethodHandle;)Ljava/lang/Object;"))
+	bootstrapMethods := b.attribute("BootstrapMethods", u2(1),
+		u2(objectMethods), u2(4),
+		u2(b.class("Point17")),
+		u2(b.string("x;y")),
+		u2(b.methodHandle(1, b.fieldref("Point17", "x", "I"))),
+		u2(b.methodHandle(1, b.fieldref("Point17", "y", "I"))))
+	accessor := func(name string) []byte {
+		return b.member(0x0001, name, "()I",
+			b.code(1, 1, join(
+				op("aload_0"),
+				op("getfield"), u2(b.fieldref("Point17", name, "I")),
+				op("ireturn"),
+			), nil))
+	}
+	return b.build(classSpec{
+		major: 61,
+		flags: 0x0031,
+		this:  "Point17", super: "java/lang/Record",
+		fields: [][]byte{
+			b.member(0x0012, "x", "I"),
+			b.member(0x0012, "y", "I"),
+		},
+		methods: [][]byte{
+			b.member(0x0001, "<init>", "(II)V",
+				b.code(2, 3, join(
+					op("aload_0"),
+					op("invokespecial"), u2(b.methodref("java/lang/Record", "<init>", "()V")),
+					op("aload_0"),
+					op("iload_1"),
+					op("putfield"), u2(b.fieldref("Point17", "x", "I")),
+					op("aload_0"),
+					op("iload_2"),
+					op("putfield"), u2(b.fieldref("Point17", "y", "I")),
+					op("return"),
+				), nil),
+				b.attribute("MethodParameters", u1(2), u2(b.utf8("x")), u2(0), u2(b.utf8("y")), u2(0))),
+			b.member(0x0011, "toString", "()Ljava/lang/String;",
+				b.code(1, 1, join(
+					op("aload_0"),
+					op("invokedynamic"), u2(b.invokeDynamic(0, "toString", "(LPoint17;)Ljava/lang/String;")), u2(0),
+					op("areturn"),
+				), nil)),
+			b.member(0x0011, "hashCode", "()I",
+				b.code(1, 1, join(
+					op("aload_0"),
+					op("invokedynamic"), u2(b.invokeDynamic(0, "hashCode", "(LPoint17;)I")), u2(0),
+					op("ireturn"),
+				), nil)),
+			b.member(0x0011, "equals", "(Ljava/lang/Object;)Z",
+				b.code(2, 2, join(
+					op("aload_0"),
+					op("aload_1"),
+					op("invokedynamic"), u2(b.invokeDynamic(0, "equals", "(LPoint17;Ljava/lang/Object;)Z")), u2(0),
+					op("ireturn"),
+				), nil)),
+			accessor("x"),
+			accessor("y"),
+		},
+		attributes: [][]byte{
+			b.attribute("Record", u2(2),
+				u2(b.utf8("x")), u2(b.utf8("I")), u2(0),
+				u2(b.utf8("y")), u2(b.utf8("I")), u2(0)),
+			bootstrapMethods,
+			b.attribute("InnerClasses", u2(1), b.lookup()),
+		},
+	})
+}
+
+// Shape25.class, assembled by hand at class file version 69 (Java 25) for
+//
+//	public sealed interface Shape25 permits Circle, Square {
+//	    double area();
+//	    default String describe() { return "area " + area(); }
+//	}
+func shape25() []byte {
+	b := newClassBuilder()
+	concat := b.methodHandle(6, b.methodref("java/lang/invoke/StringConcatFactory", "makeConcatWithConstants",
+		"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"))
+	return b.build(classSpec{
+		major: 69,
+		flags: 0x0601,
+		this:  "Shape25", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0401, "area", "()D"),
+			b.member(0x0001, "describe", "()Ljava/lang/String;",
+				b.code(2, 1, join(
+					op("aload_0"),
+					op("invokeinterface"), u2(b.interfaceMethodref("Shape25", "area", "()D")), u1(1), u1(0),
+					op("invokedynamic"), u2(b.invokeDynamic(0, "makeConcatWithConstants", "(D)Ljava/lang/String;")), u2(0),
+					op("areturn"),
+				), nil)),
+		},
+		attributes: [][]byte{
+			b.attribute("PermittedSubclasses", u2(2), u2(b.class("Circle")), u2(b.class("Square"))),
+			b.attribute("BootstrapMethods", u2(1), u2(concat), u2(1), u2(b.string("area \u0001"))),
+			b.attribute("InnerClasses", u2(1), b.lookup()),
+		},
+	})
+}
@@ -0,0 +1,1041 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// javapText renders a class file in the layout of javap -v -p -c, so that
+// our reading of a class can be diffed against the JDK's. name is shown as
+// the path of the class file, and modified as when it was last modified
+// unless it is zero. Attributes we don't lay out the way javap does, such
+// as annotations, are shown as their bytes, the way javap shows attributes
+// it doesn't know.
+func javapText(name string, modified time.Time, classFile []byte) (string, error) {
+	c, err := ParseClass(bytes.NewReader(classFile))
+	if err != nil {
+		return "", err
+	}
+	w := &javapWriter{
+		sectionParser: &sectionParser{constantPool: c.ConstantPoolItems},
+		class:         &c,
+	}
+	w.writeHeader(name, modified, classFile)
+	w.writeConstantPool()
+	w.line("{")
+	w.indent++
+	for i := range c.fields {
+		if i > 0 {
+			w.line("")
+		}
+		w.writeField(&c.fields[i])
+	}
+	for i := range c.methods {
+		if i > 0 || len(c.fields) > 0 {
+			w.line("")
+		}
+		w.writeMethod(&c.methods[i])
+	}
+	w.indent--
+	w.line("}")
+	w.attributes(c.attributes)
+	return w.out.String(), nil
+}
+
+// javapFiles prints each class file as javapText does, reporting whether
+// all of them could be read.
+func javapFiles(w io.Writer, paths []string) bool {
+	ok := true
+	for _, path := range paths {
+		var modified time.Time
+		if info, err := os.Stat(path); err == nil {
+			modified = info.ModTime()
+		}
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			var text string
+			if text, err = javapText(path, modified, data); err == nil {
+				fmt.Fprint(w, text)
+				continue
+			}
+		}
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		ok = false
+	}
+	return ok
+}
+
+// javapWriter lays out a class the way javap does. Lines are indented by
+// two spaces a level, and the // comments that follow constant pool
+// indexes line up 40 columns past the indentation.
+type javapWriter struct {
+	*sectionParser
+	class  *Class
+	out    bytes.Buffer
+	indent int
+
+	// method is the method whose attributes are being written, and
+	// wroteCode whether its Code attribute has been. Any other Code
+	// attribute, including one nested in the first, is shown as bytes.
+	method    *Method
+	wroteCode bool
+}
+
+func (w *javapWriter) line(text string) {
+	if text != "" {
+		w.out.WriteString(strings.Repeat("  ", w.indent))
+		w.out.WriteString(text)
+	}
+	w.out.WriteByte('\n')
+}
+
+func (w *javapWriter) linef(format string, args ...interface{}) {
+	w.line(fmt.Sprintf(format, args...))
+}
+
+func (w *javapWriter) commentLine(text, comment string) {
+	pad := 40 - utf8.RuneCountInString(text)
+	if pad < 1 {
+		pad = 1
+	}
+	w.line(text + strings.Repeat(" ", pad) + "// " + comment)
+}
+
+// These are the modifiers javap shows in declarations, as opposed to the
+// flags it lists in full.
+var (
+	javapClassModifiers = []flagDescription{
+		{Public, "public"},
+		{Final, "final"},
+		{Abstract, "abstract"},
+	}
+	javapFieldModifiers = []flagDescription{
+		{Public, "public"},
+		{Private, "private"},
+		{Protected, "protected"},
+		{Static, "static"},
+		{Final, "final"},
+		{Volatile, "volatile"},
+		{Transient, "transient"},
+	}
+	javapMethodModifiers = []flagDescription{
+		{Public, "public"},
+		{Private, "private"},
+		{Protected, "protected"},
+		{Static, "static"},
+		{Final, "final"},
+		{Synchronized, "synchronized"},
+		{Native, "native"},
+		{Abstract, "abstract"},
+		{Strict, "strictfp"},
+	}
+	javapInnerClassModifiers = []flagDescription{
+		{Public, "public"},
+		{Private, "private"},
+		{Protected, "protected"},
+		{Static, "static"},
+		{Final, "final"},
+		{Abstract, "abstract"},
+	}
+)
+
+// modifiers renders the flags that are set as the start of a declaration,
+// e.g. "public static ".
+func modifiers(flags accessFlags, descriptions []flagDescription) string {
+	var text string
+	for _, d := range descriptions {
+		if flags&d.flag != 0 {
+			text += d.name + " "
+		}
+	}
+	return text
+}
+
+// flagsLine renders flags as javap lists them, e.g.
+// "flags: (0x0021) ACC_PUBLIC, ACC_SUPER".
+func flagsLine(flags accessFlags, descriptions []flagDescription) string {
+	var names []string
+	for _, d := range descriptions {
+		if flags&d.flag != 0 {
+			names = append(names, "ACC_"+strings.ToUpper(d.name))
+		}
+	}
+	return fmt.Sprintf("flags: (0x%04x) %s", uint16(flags), strings.Join(names, ", "))
+}
+
+func (w *javapWriter) writeHeader(name string, modified time.Time, classFile []byte) {
+	c := w.class
+	w.line("Classfile " + name)
+	w.indent++
+	if !modified.IsZero() {
+		w.linef("Last modified %s; size %d bytes", modified.Format("Jan 2, 2006"), len(classFile))
+	}
+	w.linef("SHA-256 checksum %x", sha256.Sum256(classFile))
+	if source := w.attributeIndex(c.attributes, "SourceFile"); source != 0 {
+		w.linef("Compiled from \"%s\"", w.utf8(source))
+	}
+	w.indent--
+	w.line(w.classDeclaration())
+	w.indent++
+	w.linef("minor version: %d", c.MinorVersion)
+	w.linef("major version: %d", c.MajorVersion)
+	w.line(flagsLine(c.AccessFlags, classFlags))
+	w.classIndexLine("this_class", c.thisClass)
+	w.classIndexLine("super_class", c.superClass)
+	w.linef("interfaces: %d, fields: %d, methods: %d, attributes: %d", len(c.interfaces), len(c.fields), len(c.methods), len(c.attributes))
+	w.indent--
+}
+
+func (w *javapWriter) classIndexLine(label string, index uint16) {
+	text := fmt.Sprintf("%s: #%d", label, index)
+	if index == 0 {
+		w.line(text)
+		return
+	}
+	w.commentLine(text, w.value(index))
+}
+
+// attributeIndex returns the constant pool index held by the attribute
+// with the given name, or zero if there isn't one.
+func (w *javapWriter) attributeIndex(attributes []attribute, name string) uint16 {
+	for _, a := range attributes {
+		if a.name == name && len(a.info) == 2 {
+			return newByteParser(a.info, 0).u2()
+		}
+	}
+	return 0
+}
+
+func (w *javapWriter) classDeclaration() string {
+	c := w.class
+	if c.AccessFlags&Module != 0 {
+		for _, a := range c.attributes {
+			if m, err := parseModuleAttributeInfo(a.info); a.name == "Module" && err == nil {
+				return w.moduleHeader(m)
+			}
+		}
+	}
+	isInterface := c.AccessFlags&Interface != 0
+	flags := c.AccessFlags
+	kind := "class "
+	if isInterface {
+		flags &^= Abstract
+		kind = "interface "
+	}
+	declaration := modifiers(flags, javapClassModifiers) + kind + w.javaClassName(c.thisClass)
+	if signature, ok := w.signature(c.attributes); ok {
+		if s, err := parseClassSignature(signature); err == nil {
+			declaration += javapTypeParameters(s.typeParameters)
+			if isInterface {
+				if len(s.interfaces) > 0 {
+					declaration += " extends " + joinTypes(s.interfaces)
+				}
+			} else {
+				declaration += " extends " + s.superclass.String()
+				if len(s.interfaces) > 0 {
+					declaration += " implements " + joinTypes(s.interfaces)
+				}
+			}
+			return declaration
+		}
+	}
+	if !isInterface && c.superClass != 0 {
+		if super := w.javaClassName(c.superClass); super != "java.lang.Object" {
+			declaration += " extends " + super
+		}
+	}
+	for i, index := range c.interfaces {
+		switch {
+		case i > 0:
+			declaration += ","
+		case isInterface:
+			declaration += " extends "
+		default:
+			declaration += " implements "
+		}
+		declaration += w.javaClassName(index)
+	}
+	return declaration
+}
+
+// javapTypeParameters renders type parameters as javap does, which unlike
+// typeParametersString keeps bounds of java.lang.Object.
+func javapTypeParameters(parameters []typeParameter) string {
+	if len(parameters) == 0 {
+		return ""
+	}
+	names := make([]string, len(parameters))
+	for i, p := range parameters {
+		var bounds []typeSignature
+		if p.classBound != nil {
+			bounds = append(bounds, p.classBound)
+		}
+		bounds = append(bounds, p.interfaceBounds...)
+		names[i] = p.name
+		if len(bounds) > 0 {
+			names[i] += " extends " + strings.Replace(joinTypes(bounds), ", ", " & ", -1)
+		}
+	}
+	return "<" + strings.Join(names, ", ") + ">"
+}
+
+// signature returns the signature held by the Signature attribute among
+// attributes, if there is one.
+func (w *javapWriter) signature(attributes []attribute) (string, bool) {
+	index := w.attributeIndex(attributes, "Signature")
+	if index == 0 {
+		return "", false
+	}
+	return w.constantPoolUtf8(index)
+}
+
+func (w *javapWriter) javaClassName(index uint16) string {
+	name, ok := w.constantPoolClassName(index)
+	if !ok {
+		return fmt.Sprintf("#%d", index)
+	}
+	return strings.Replace(name, "/", ".", -1)
+}
+
+func (w *javapWriter) writeConstantPool() {
+	w.line("Constant pool:")
+	w.indent++
+	width := len(strconv.Itoa(len(w.sectionParser.constantPool)+1)) + 1
+	for i, item := range w.sectionParser.constantPool {
+		if _, ok := item.(WideConstantPart2); ok {
+			continue
+		}
+		index := uint16(i + 1)
+		tag, arguments, comment := w.constantEntry(item)
+		text := fmt.Sprintf("%*s = %-18s %s", width, fmt.Sprintf("#%d", index), tag, arguments)
+		if comment == "" {
+			w.line(strings.TrimRight(text, " "))
+			continue
+		}
+		w.commentLine(fmt.Sprintf("%*s = %-18s %-14s", width, fmt.Sprintf("#%d", index), tag, arguments), comment)
+	}
+	w.indent--
+}
+
+// constantEntry returns how javap lists a constant pool item: the name of
+// its tag, the indexes or value it holds, and a comment showing what the
+// indexes refer to.
+func (w *javapWriter) constantEntry(item ConstantPoolItem) (tag, arguments, comment string) {
+	switch c := item.(type) {
+	case utf8String:
+		return "Utf8", javapEscape(c.contents), ""
+	case intConstant:
+		return "Integer", w.itemValue(c), ""
+	case floatConstant:
+		return "Float", w.itemValue(c), ""
+	case longConstant:
+		return "Long", w.itemValue(c), ""
+	case doubleConstant:
+		return "Double", w.itemValue(c), ""
+	case classInfo:
+		return "Class", fmt.Sprintf("#%d", c.nameIndex), w.itemValue(c)
+	case stringConstant:
+		return "String", fmt.Sprintf("#%d", c.utf8Index), w.itemValue(c)
+	case fieldRef:
+		return "Fieldref", fmt.Sprintf("#%d.#%d", c.classIndex, c.nameAndTypeIndex), w.itemValue(c)
+	case methodRef:
+		return "Methodref", fmt.Sprintf("#%d.#%d", c.classIndex, c.nameAndTypeIndex), w.itemValue(c)
+	case interfaceMethodRef:
+		return "InterfaceMethodref", fmt.Sprintf("#%d.#%d", c.classIndex, c.nameAndTypeIndex), w.itemValue(c)
+	case nameAndType:
+		return "NameAndType", fmt.Sprintf("#%d:#%d", c.nameIndex, c.descriptorIndex), w.itemValue(c)
+	case methodHandle:
+		return "MethodHandle", fmt.Sprintf("%d:#%d", c.referenceKind, c.referenceIndex), w.itemValue(c)
+	case methodType:
+		// javap leaves an extra space before method types.
+		return "MethodType", fmt.Sprintf("#%d", c.descriptorIndex), " " + w.itemValue(c)
+	case dynamicConstant:
+		return "Dynamic", fmt.Sprintf("#%d:#%d", c.bootstrapMethodAttrIndex, c.nameAndTypeIndex), w.itemValue(c)
+	case invokeDynamic:
+		return "InvokeDynamic", fmt.Sprintf("#%d:#%d", c.bootstrapMethodAttrIndex, c.nameAndTypeIndex), w.itemValue(c)
+	case moduleInfo:
+		return "Module", fmt.Sprintf("#%d", c.nameIndex), w.itemValue(c)
+	case packageInfo:
+		return "Package", fmt.Sprintf("#%d", c.nameIndex), w.itemValue(c)
+	}
+	return "Unknown", "", ""
+}
+
+// javapReferenceNames are what javap calls each kind of constant when it
+// refers to one, e.g. "Method java/lang/Object."<init>":()V".
+var javapReferenceNames = map[string]string{
+	utf8Kind:               "Utf8",
+	intKind:                "int",
+	floatKind:              "float",
+	longKind:               "long",
+	doubleKind:             "double",
+	classKind:              "class",
+	stringKind:             "String",
+	fieldRefKind:           "Field",
+	methodRefKind:          "Method",
+	interfaceMethodRefKind: "InterfaceMethod",
+	nameAndTypeKind:        "NameAndType",
+	methodHandleKind:       "MethodHandle",
+	methodTypeKind:         "MethodType",
+	dynamicKind:            "Dynamic",
+	invokeDynamicKind:      "InvokeDynamic",
+	moduleKind:             "Module",
+	packageKind:            "Package",
+}
+
+// reference renders the constant at index as javap does in comments on
+// instructions and attributes. Members of the class itself are shown
+// without the class's name.
+func (w *javapWriter) reference(index uint16) string {
+	item, ok := w.constantPoolItem(index)
+	if !ok {
+		return fmt.Sprintf("#%d", index)
+	}
+	value := w.itemValue(item)
+	var classIndex, natIndex uint16
+	switch c := item.(type) {
+	case fieldRef:
+		classIndex, natIndex = c.classIndex, c.nameAndTypeIndex
+	case methodRef:
+		classIndex, natIndex = c.classIndex, c.nameAndTypeIndex
+	case interfaceMethodRef:
+		classIndex, natIndex = c.classIndex, c.nameAndTypeIndex
+	}
+	if natIndex != 0 && classIndex == w.class.thisClass {
+		value = w.value(natIndex)
+	}
+	return javapReferenceNames[constantKind(item)] + " " + value
+}
+
+// value renders the constant at index as javap does, e.g.
+// "java/lang/Object."<init>":()V" for a method ref.
+func (w *javapWriter) value(index uint16) string {
+	item, ok := w.constantPoolItem(index)
+	if !ok {
+		return fmt.Sprintf("#%d", index)
+	}
+	return w.itemValue(item)
+}
+
+func (w *javapWriter) itemValue(item ConstantPoolItem) string {
+	// Indexes are only followed to the kinds of constant they should refer
+	// to, so that a malformed pool can't lead round in circles.
+	class := func(index uint16) string {
+		if name, ok := w.constantPoolClassName(index); ok {
+			return javapName(name)
+		}
+		return fmt.Sprintf("#%d", index)
+	}
+	nat := func(index uint16) string {
+		item, _ := w.constantPoolItem(index)
+		if n, ok := item.(nameAndType); ok {
+			return w.itemValue(n)
+		}
+		return fmt.Sprintf("#%d", index)
+	}
+	switch c := item.(type) {
+	case utf8String:
+		return javapEscape(c.contents)
+	case intConstant:
+		return strconv.Itoa(int(c.value))
+	case floatConstant:
+		return javaFloatString(float64(c.value), 32) + "f"
+	case longConstant:
+		return strconv.FormatInt(c.value, 10) + "l"
+	case doubleConstant:
+		return javaFloatString(c.value, 64) + "d"
+	case classInfo:
+		if name, ok := w.constantPoolUtf8(c.nameIndex); ok {
+			return javapName(name)
+		}
+		return fmt.Sprintf("#%d", c.nameIndex)
+	case stringConstant:
+		return w.utf8(c.utf8Index)
+	case fieldRef:
+		return class(c.classIndex) + "." + nat(c.nameAndTypeIndex)
+	case methodRef:
+		return class(c.classIndex) + "." + nat(c.nameAndTypeIndex)
+	case interfaceMethodRef:
+		return class(c.classIndex) + "." + nat(c.nameAndTypeIndex)
+	case nameAndType:
+		name, ok := w.constantPoolUtf8(c.nameIndex)
+		if !ok {
+			return fmt.Sprintf("#%d:%s", c.nameIndex, w.utf8(c.descriptorIndex))
+		}
+		return javapName(name) + ":" + w.utf8(c.descriptorIndex)
+	case methodHandle:
+		return referenceKindNames[c.referenceKind] + " " + w.memberValue(c.referenceIndex)
+	case methodType:
+		return w.utf8(c.descriptorIndex)
+	case dynamicConstant:
+		return fmt.Sprintf("#%d:%s", c.bootstrapMethodAttrIndex, nat(c.nameAndTypeIndex))
+	case invokeDynamic:
+		return fmt.Sprintf("#%d:%s", c.bootstrapMethodAttrIndex, nat(c.nameAndTypeIndex))
+	case moduleInfo:
+		return w.utf8(c.nameIndex)
+	case packageInfo:
+		return w.utf8(c.nameIndex)
+	}
+	return "?"
+}
+
+// memberValue renders the field or method ref at index, which method
+// handles point at.
+func (w *javapWriter) memberValue(index uint16) string {
+	item, _ := w.constantPoolItem(index)
+	switch item.(type) {
+	case fieldRef, methodRef, interfaceMethodRef:
+		return w.itemValue(item)
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+func (w *javapWriter) utf8(index uint16) string {
+	if s, ok := w.constantPoolUtf8(index); ok {
+		return javapEscape(s)
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// javapName quotes names that aren't made up of Java identifiers separated
+// by slashes, such as "<init>" and "[Ljava/lang/String;".
+func javapName(name string) string {
+	previous := '/'
+	for _, r := range name {
+		if (previous == '/' && !isJavaIdentifierStart(r)) || (r != '/' && !isJavaIdentifierPart(r)) {
+			replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+			return `"` + replacer.Replace(name) + `"`
+		}
+		previous = r
+	}
+	return name
+}
+
+func isJavaIdentifierStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '$'
+}
+
+func isJavaIdentifierPart(r rune) bool {
+	return isJavaIdentifierStart(r) || unicode.IsDigit(r)
+}
+
+// javapEscape escapes a UTF-8 constant as javap shows it, which is without
+// quotes.
+func javapEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '"', '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// javaFloatString renders a float or double as Java's toString does, e.g.
+// "100.0" or "1.0E-5".
+func javaFloatString(v float64, bitSize int) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	if abs := math.Abs(v); v == 0 || (abs >= 1e-3 && abs < 1e7) {
+		s := strconv.FormatFloat(v, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(v, 'e', -1, bitSize)
+	e := strings.IndexByte(s, 'e')
+	mantissa, exponent := s[:e], s[e+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	n, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(n)
+}
+
+func (w *javapWriter) writeField(f *field) {
+	name := w.utf8(f.nameIndex)
+	descriptor, _ := w.constantPoolUtf8(f.descriptorIndex)
+	typeName := descriptor
+	if t, err := parseFieldDescriptor(descriptor); err == nil {
+		typeName = t.String()
+	}
+	if signature, ok := w.signature(f.attributes); ok {
+		if t, err := parseFieldSignature(signature); err == nil {
+			typeName = t.String()
+		}
+	}
+	w.linef("%s%s %s;", modifiers(f.accessFlags, javapFieldModifiers), typeName, name)
+	w.indent++
+	w.line("descriptor: " + w.utf8(f.descriptorIndex))
+	w.line(flagsLine(f.accessFlags, fieldFlags))
+	w.attributes(f.attributes)
+	w.indent--
+}
+
+func (w *javapWriter) writeMethod(m *Method) {
+	w.method, w.wroteCode = m, false
+	defer func() { w.method = nil }()
+	w.line(w.methodDeclaration(m))
+	w.indent++
+	w.line("descriptor: " + w.utf8(m.descriptorIndex))
+	w.line(flagsLine(m.accessFlags, methodFlags))
+	w.attributes(m.attributes)
+	w.indent--
+}
+
+func (w *javapWriter) methodDeclaration(m *Method) string {
+	name, _ := w.constantPoolUtf8(m.nameIndex)
+	flags := modifiers(m.accessFlags, javapMethodModifiers)
+	// Interface methods with bodies are default methods, unless they
+	// are static or private.
+	if w.class.AccessFlags&Interface != 0 && m.accessFlags&(Abstract|Static|Private) == 0 && name != "<clinit>" && w.class.MajorVersion >= 52 {
+		flags += "default "
+	}
+
+	var typeParameters string
+	var parameters, throws []string
+	var result string
+	if m.RawSigniture != "" {
+		for _, p := range m.Signiture.parameters {
+			parameters = append(parameters, p.String())
+		}
+		result = "void"
+		if m.Signiture.result != nil {
+			result = m.Signiture.result.String()
+		}
+	}
+	if signature, ok := w.signature(m.attributes); ok {
+		if s, err := parseMethodSignature(signature); err == nil {
+			typeParameters = javapTypeParameters(s.typeParameters)
+			if typeParameters != "" {
+				typeParameters += " "
+			}
+			parameters = nil
+			for _, p := range s.parameters {
+				parameters = append(parameters, p.String())
+			}
+			result = s.result.String()
+			for _, t := range s.throws {
+				throws = append(throws, t.String())
+			}
+		}
+	}
+	if m.accessFlags&Varargs != 0 && len(parameters) > 0 {
+		last := parameters[len(parameters)-1]
+		if strings.HasSuffix(last, "[]") {
+			parameters[len(parameters)-1] = strings.TrimSuffix(last, "[]") + "..."
+		}
+	}
+	if len(throws) == 0 {
+		for _, a := range m.attributes {
+			if a.name == "Exceptions" {
+				for _, index := range w.indexes(newByteParser(a.info, 0)) {
+					throws = append(throws, w.javaClassName(index))
+				}
+			}
+		}
+	}
+
+	declaration := flags + typeParameters
+	switch name {
+	case "<init>":
+		declaration += w.javaClassName(w.class.thisClass) + "(" + strings.Join(parameters, ", ") + ")"
+	case "<clinit>":
+		declaration += "{}"
+	default:
+		declaration += result + " " + javapEscape(name) + "(" + strings.Join(parameters, ", ") + ")"
+	}
+	if len(throws) > 0 {
+		declaration += " throws " + strings.Join(throws, ", ")
+	}
+	return declaration + ";"
+}
+
+func (w *javapWriter) attributes(attributes []attribute) {
+	for _, a := range attributes {
+		w.attribute(a)
+	}
+}
+
+// attribute writes a single attribute. Attributes that turn out to be
+// malformed are shown as their bytes instead.
+func (w *javapWriter) attribute(a attribute) {
+	mark := w.out.Len()
+	r := newByteParser(a.info, 0)
+	known := true
+	switch a.name {
+	case "Code":
+		if w.method == nil || w.wroteCode {
+			known = false
+			break
+		}
+		w.wroteCode = true
+		w.writeCode(parseCode(r, w.class))
+	case "ConstantValue":
+		w.line("ConstantValue: " + w.reference(r.u2()))
+	case "Signature":
+		index := r.u2()
+		w.commentLine(fmt.Sprintf("Signature: #%d", index), w.value(index))
+	case "SourceFile":
+		w.linef("SourceFile: \"%s\"", w.value(r.u2()))
+	case "Deprecated", "Synthetic":
+		w.line(a.name + ": true")
+	case "Exceptions":
+		w.line("Exceptions:")
+		w.indent++
+		var names []string
+		for _, index := range w.indexes(r) {
+			names = append(names, w.javaClassName(index))
+		}
+		w.line("throws " + strings.Join(names, ", "))
+		w.indent--
+	case "NestHost":
+		w.line("NestHost: " + w.reference(r.u2()))
+	case "NestMembers", "PermittedSubclasses":
+		w.line(a.name + ":")
+		w.indent++
+		for _, index := range w.indexes(r) {
+			w.line(w.value(index))
+		}
+		w.indent--
+	case "EnclosingMethod":
+		class, method := r.u2(), r.u2()
+		comment := w.javaClassName(class)
+		if method != 0 {
+			item, _ := w.constantPoolItem(method)
+			if nat, ok := item.(nameAndType); ok {
+				comment += "." + w.utf8(nat.nameIndex)
+			}
+		}
+		w.commentLine(fmt.Sprintf("EnclosingMethod: #%d.#%d", class, method), comment)
+	case "InnerClasses":
+		w.writeInnerClasses(r)
+	case "BootstrapMethods":
+		known = w.writeBootstrapMethods(a.info)
+		r.pos = len(a.info)
+	case "LineNumberTable":
+		w.line("LineNumberTable:")
+		w.indent++
+		count := int(r.u2())
+		for i := 0; i < count && r.err == nil; i++ {
+			pc, line := r.u2(), r.u2()
+			w.linef("line %d: %d", line, pc)
+		}
+		w.indent--
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		w.line(a.name + ":")
+		w.indent++
+		w.line("Start  Length  Slot  Name   Signature")
+		count := int(r.u2())
+		for i := 0; i < count && r.err == nil; i++ {
+			start, length, name, descriptor, slot := r.u2(), r.u2(), r.u2(), r.u2(), r.u2()
+			w.linef("%5d %7d %5d %5s   %s", start, length, slot, w.utf8(name), w.utf8(descriptor))
+		}
+		w.indent--
+	case "StackMapTable":
+		w.writeStackMapTable(r)
+	default:
+		known = false
+	}
+	if !known || r.err != nil || r.pos != len(a.info) {
+		w.out.Truncate(mark)
+		w.rawAttribute(a)
+	}
+}
+
+// indexes reads a count followed by that many constant pool indexes, as
+// in Exceptions and NestMembers.
+func (w *javapWriter) indexes(r *byteParser) []uint16 {
+	count := int(r.u2())
+	var indexes []uint16
+	for i := 0; i < count && r.err == nil; i++ {
+		indexes = append(indexes, r.u2())
+	}
+	return indexes
+}
+
+// rawAttribute shows an attribute's length and bytes, sixteen to a line.
+func (w *javapWriter) rawAttribute(a attribute) {
+	w.linef("%s: length = 0x%X", a.name, len(a.info))
+	for start := 0; start < len(a.info); start += 16 {
+		end := start + 16
+		if end > len(a.info) {
+			end = len(a.info)
+		}
+		bytes := make([]string, end-start)
+		for i, b := range a.info[start:end] {
+			bytes[i] = fmt.Sprintf("%02X", b)
+		}
+		w.line("   " + strings.Join(bytes, " "))
+	}
+}
+
+func (w *javapWriter) writeInnerClasses(r *byteParser) {
+	w.line("InnerClasses:")
+	w.indent++
+	count := int(r.u2())
+	for i := 0; i < count && r.err == nil; i++ {
+		inner, outer, name, flags := r.u2(), r.u2(), r.u2(), accessFlags(r.u2())
+		if flags&Interface != 0 {
+			flags &^= Abstract
+		}
+		text := modifiers(flags, javapInnerClassModifiers)
+		comment := ""
+		if name != 0 {
+			text += fmt.Sprintf("#%d= ", name)
+			comment = w.utf8(name) + "="
+		}
+		text += fmt.Sprintf("#%d", inner)
+		comment += w.reference(inner)
+		if outer != 0 {
+			text += fmt.Sprintf(" of #%d", outer)
+			comment += " of " + w.reference(outer)
+		}
+		w.commentLine(text+";", comment)
+	}
+	w.indent--
+}
+
+func (w *javapWriter) writeBootstrapMethods(info []byte) bool {
+	methods, err := parseBootstrapMethodsInfo(info)
+	if err != nil {
+		return false
+	}
+	w.line("BootstrapMethods:")
+	w.indent++
+	for i, m := range methods {
+		w.linef("%d: #%d %s", i, m.methodRef, w.value(m.methodRef))
+		w.indent++
+		w.line("Method arguments:")
+		w.indent++
+		for _, a := range m.arguments {
+			w.linef("#%d %s", a, w.value(a))
+		}
+		w.indent -= 2
+	}
+	w.indent--
+	return true
+}
+
+func (w *javapWriter) writeCode(code Code) {
+	// javap counts parameters rather than the slots they take.
+	arguments := len(w.method.Signiture.parameters)
+	if !w.method.Static() {
+		arguments++
+	}
+	w.line("Code:")
+	w.indent++
+	w.linef("stack=%d, locals=%d, args_size=%d", code.maxStack, code.maxLocals, arguments)
+	for pc := 0; pc < len(code.Instructions); {
+		inst, err := decodeInstruction(code.Instructions, pc)
+		if err != nil {
+			w.linef("%4d: %s", pc, err)
+			break
+		}
+		w.writeInstruction(inst)
+		pc += inst.length
+	}
+	if len(code.ExceptionHandlers) > 0 {
+		w.line("Exception table:")
+		w.indent++
+		w.line(" from    to  target type")
+		for _, h := range code.ExceptionHandlers {
+			catches := "any"
+			if h.CatchType != 0 {
+				catches = "Class " + w.value(h.CatchType)
+			}
+			w.linef(" %5d %5d %5d   %s", h.Start, h.End, h.Handler, catches)
+		}
+		w.indent--
+	}
+	w.attributes(code.attributes)
+	w.indent--
+}
+
+// writeInstruction writes an instruction as javap does, with its operands
+// after the mnemonic and a comment saying what any constant it uses is.
+// Switches are written over several lines, one for each case.
+func (w *javapWriter) writeInstruction(inst instruction) {
+	name := inst.name()
+	if inst.wide {
+		name += "_w"
+	}
+	switch opcodes[inst.opcode].operands {
+	case tableSwitchOperands, lookupSwitchOperands:
+		w.writeSwitch(inst, name)
+		return
+	}
+	var arguments []string
+	var constant uint16
+	for _, o := range inst.operands {
+		switch o.kind {
+		case reserved:
+			// invokedynamic's two zero bytes are shown as its count.
+			if opcodes[inst.opcode].operands == invokeDynamicOperands {
+				arguments[0] += ",  0"
+			}
+		case constantIndex:
+			constant = uint16(o.value)
+			arguments = append(arguments, fmt.Sprintf("#%d", o.value))
+		case argumentCount, dimensions:
+			arguments[0] += fmt.Sprintf(",  %d", o.value)
+		case branchOffset:
+			arguments = append(arguments, strconv.Itoa(inst.pc+o.value))
+		case arrayType:
+			arguments = append(arguments, arrayTypes[o.value])
+		default:
+			arguments = append(arguments, strconv.Itoa(o.value))
+		}
+	}
+	if len(arguments) == 0 {
+		w.linef("%4d: %s", inst.pc, name)
+		return
+	}
+	text := fmt.Sprintf("%4d: %-13s %s", inst.pc, name, strings.Join(arguments, ", "))
+	if constant == 0 {
+		w.line(text)
+		return
+	}
+	w.commentLine(text, w.reference(constant))
+}
+
+func (w *javapWriter) writeSwitch(inst instruction, name string) {
+	def, _ := inst.operand(defaultOffset)
+	var cases []int
+	var header string
+	if low, ok := inst.operand(lowValue); ok {
+		high, _ := inst.operand(highValue)
+		header = fmt.Sprintf("%d to %d", low.value, high.value)
+		for k := low.value; k <= high.value; k++ {
+			cases = append(cases, k)
+		}
+	} else {
+		pairs, _ := inst.operand(pairCount)
+		header = strconv.Itoa(pairs.value)
+		for _, o := range inst.operands {
+			if o.kind == matchValue {
+				cases = append(cases, o.value)
+			}
+		}
+	}
+	w.linef("%4d: %-13s { // %s", inst.pc, name, header)
+	i := 0
+	for _, o := range inst.operands {
+		if o.kind == branchOffset && i < len(cases) {
+			w.linef("%18d: %d", cases[i], inst.pc+o.value)
+			i++
+		}
+	}
+	w.linef("%18s: %d", "default", inst.pc+def.value)
+	w.line("      }")
+}
+
+// javapVerificationTypes are javap's names for the verification types of
+// stack map frames, other than objects and uninitialized values.
+var javapVerificationTypes = map[uint8]string{
+	0: "top",
+	1: "int",
+	2: "float",
+	3: "double",
+	4: "long",
+	5: "null",
+	6: "this",
+}
+
+func (w *javapWriter) writeStackMapTable(r *byteParser) {
+	count := int(r.u2())
+	w.linef("StackMapTable: number_of_entries = %d", count)
+	w.indent++
+	for i := 0; i < count && r.err == nil; i++ {
+		tag := r.u1()
+		kind := stackMapFrameType(tag)
+		if tag == 247 {
+			kind = "same_locals_1_stack_item_frame_extended"
+		}
+		w.linef("frame_type = %d /* %s */", tag, kind)
+		w.indent++
+		switch {
+		case tag < 64:
+		case tag < 128:
+			w.writeVerificationTypes(r, "stack", 1)
+		case tag < 247:
+			// Reserved frame types leave the rest of the table unreadable.
+			r.fail(fmt.Errorf("reserved frame type %d", tag))
+		case tag == 247:
+			w.linef("offset_delta = %d", r.u2())
+			w.writeVerificationTypes(r, "stack", 1)
+		case tag < 255:
+			w.linef("offset_delta = %d", r.u2())
+			if tag > 251 {
+				w.writeVerificationTypes(r, "locals", int(tag)-251)
+			}
+		default:
+			w.linef("offset_delta = %d", r.u2())
+			w.writeVerificationTypes(r, "locals", int(r.u2()))
+			w.writeVerificationTypes(r, "stack", int(r.u2()))
+		}
+		w.indent--
+	}
+	w.indent--
+}
+
+// writeVerificationTypes writes a list of verification types, e.g.
+// "locals = [ class java/lang/String, int ]".
+func (w *javapWriter) writeVerificationTypes(r *byteParser, label string, count int) {
+	text := label + " = ["
+	for i := 0; i < count && r.err == nil; i++ {
+		switch tag := r.u1(); tag {
+		case 7:
+			text += " " + w.reference(r.u2())
+		case 8:
+			text += fmt.Sprintf(" uninitialized %d", r.u2())
+		default:
+			name, ok := javapVerificationTypes[tag]
+			if !ok {
+				r.fail(fmt.Errorf("unknown verification type %d", tag))
+			}
+			text += " " + name
+		}
+		if i == count-1 {
+			text += " "
+		} else {
+			text += ","
+		}
+	}
+	w.line(text + "]")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestJavapSnapshots compares the javap output for each fixture with a
+// snapshot in testdata/javap-snapshots. The snapshots are our own output,
+// written by go test -update, not the JDK's javap -v -p -c: they catch
+// changes to what we print, not differences from javap.
+func TestJavapSnapshots(t *testing.T) {
+	var names []string
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append(names, "HelloWorld.class")
+	for _, name := range names {
+		got, err := javapText(name, time.Time{}, readFixture(t, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		path := filepath.Join("testdata", "javap-snapshots", strings.TrimSuffix(name, ".class")+".javap")
+		if *update {
+			if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: javap output differs from the snapshot %s, run go test -update if that is expected\n%s", name, path, got)
+		}
+	}
+}
+
+// TestJavapCodeInCode checks that a Code attribute inside another, or a
+// method's second Code attribute, is shown as bytes rather than as the
+// method's code again.
+func TestJavapCodeInCode(t *testing.T) {
+	b := newClassBuilder()
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "C", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0008, "nested", "()V",
+				b.code(0, 0, op("return"), nil,
+					b.code(0, 0, op("nop"), nil))),
+			b.member(0x0008, "twice", "()V",
+				b.code(0, 0, op("return"), nil),
+				b.code(0, 0, op("nop"), nil)),
+		},
+	})
+	want := `
+  static void nested();
+    descriptor: ()V
+    flags: (0x0008) ACC_STATIC
+    Code:
+      stack=0, locals=0, args_size=0
+         0: return
+      Code: length = 0xD
+         00 00 00 00 00 00 00 01 00 00 00 00 00
+
+  static void twice();
+    descriptor: ()V
+    flags: (0x0008) ACC_STATIC
+    Code:
+      stack=0, locals=0, args_size=0
+         0: return
+    Code: length = 0xD
+       00 00 00 00 00 00 00 01 00 00 00 00 00
+}
+`
+	text, err := javapText("C.class", time.Time{}, class)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(text, want) {
+		t.Errorf("javap output is\n%s\nwant it to end\n%s", text, want)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := newRouter(nil, nil, newArchiveStore(), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/class?format=javap&name=C.class", bytes.NewReader(class)))
+	if w.Body.String() != text {
+		t.Errorf("POST /class?format=javap: status %d\n%s", w.Code, w.Body.String())
+	}
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +22,8 @@ type Section struct {
 	Name       string    `json:"text,omitempty"`
 	Children   []Section `json:"children,omitempty"`
 	Id         int       `json:"id"`
+	Target     int       `json:"target,omitempty"`
+	Line       int       `json:"line,omitempty"`
 }
 
 type Page struct {
@@ -26,22 +34,161 @@ type Page struct {
 }
 
 func main() {
+	archivePath := flag.String("archive", "", "a jar, war or zip file to browse")
+	classDir := flag.String("dir", "", "a directory of class files to serve, reloading them as they change")
+	check := flag.Bool("check", false, "check the class files named on the command line and exit, failing if any are malformed")
+	javap := flag.Bool("javap", false, "print the class files named on the command line as javap -v -p -c would and exit")
+	flag.Parse()
+
+	if *check {
+		if !checkFiles(os.Stdout, flag.Args()) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *javap {
+		if !javapFiles(os.Stdout, flag.Args()) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	classFile, _ := ioutil.ReadFile("static/HelloWorld.class")
+	javaSource, _ := ioutil.ReadFile("static/HelloWorld.java")
+
+	archives := newArchiveStore()
+	if *archivePath != "" {
+		data, err := ioutil.ReadFile(*archivePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := archives.add(filepath.Base(*archivePath), data); err != nil {
+			log.Fatalf("%s: %v", *archivePath, err)
+		}
+	}
+
+	var dir *classDirectory
+	if *classDir != "" {
+		var err error
+		dir, err = newClassDirectory(*classDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go dir.watch(time.Second)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		log.Fatal("$PORT must be set")
 	}
 
+	r := newRouter(classFile, javaSource, archives, dir)
+	r.Run(":" + port)
+}
+
+// newRouter registers the handlers for the example class, the archives and,
+// when dir isn't nil, the watched directory of class files.
+func newRouter(classFile, javaSource []byte, archives *archiveStore, dir *classDirectory) *gin.Engine {
 	r := gin.Default()
 	r.LoadHTMLGlob("templates/*.tmpl*")
 	r.GET("/", func(c *gin.Context) {
 		c.HTML(http.StatusOK, "index.tmpl.html", nil)
 	})
 	r.GET("/class", func(c *gin.Context) {
-		c.JSON(http.StatusOK, classJSON(classFile))
+		if c.Query("format") == "javap" {
+			writeJavap(c, "HelloWorld.class", classFile)
+			return
+		}
+		result := classJSON(classFile)
+		if len(javaSource) > 0 {
+			result["source"] = string(javaSource)
+		}
+		c.JSON(http.StatusOK, result)
 	})
-	r.Run(":" + port)
+	r.POST("/class", func(c *gin.Context) {
+		uploaded, name, err := readUpload(c.Writer, c.Request, "class")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if c.Query("format") == "javap" {
+			writeJavap(c, name, uploaded)
+			return
+		}
+		c.JSON(http.StatusOK, classJSON(uploaded))
+	})
+	r.GET("/archive", func(c *gin.Context) {
+		c.JSON(http.StatusOK, archives.list())
+	})
+	r.POST("/archive", func(c *gin.Context) {
+		uploaded, name, err := readUpload(c.Writer, c.Request, "archive")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		a, err := archives.add(name, uploaded)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, a)
+	})
+	r.GET("/archive/:id/*entry", func(c *gin.Context) {
+		a, ok := archives.get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no such archive"})
+			return
+		}
+		name := strings.TrimPrefix(c.Param("entry"), "/")
+		data, err := a.read(name)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		result := entryJSON(name, data)
+		if isClassEntry(name) {
+			if source, err := a.source(name, data); err == nil {
+				result["source"] = string(source)
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	})
+	if dir != nil {
+		r.GET("/directory", func(c *gin.Context) {
+			c.JSON(http.StatusOK, dir.list())
+		})
+		r.GET("/directory/*path", func(c *gin.Context) {
+			data, err := dir.read(c.Param("path"))
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			result := classJSON(data)
+			if source, err := dir.source(c.Param("path"), data); err == nil {
+				result["source"] = string(source)
+			}
+			c.JSON(http.StatusOK, result)
+		})
+		r.GET("/events", func(c *gin.Context) {
+			changes := dir.subscribe()
+			defer dir.unsubscribe(changes)
+			c.Stream(func(w io.Writer) bool {
+				select {
+				case change := <-changes:
+					c.SSEvent("change", change)
+				case <-time.After(30 * time.Second):
+					// Keep idle connections from being closed by proxies.
+					c.SSEvent("ping", "")
+				case <-c.Request.Context().Done():
+					// Stop as soon as the tab goes away rather than at
+					// the next ping.
+					return false
+				}
+				return true
+			})
+		})
+	}
+	return r
 }
 
 func classJSON(classFile []byte) gin.H {
@@ -53,6 +200,86 @@ func classJSON(classFile []byte) gin.H {
 		classString = append(classString, hexString[i:i+2])
 	}
 	result["raw"] = classString
-	result["parsed"] = parseClass(classFile)
+	result["parsed"], result["diagnostics"] = checkClassFile(classFile)
+	if source, ok := moduleSource(classFile); ok {
+		result["source"] = source
+	}
 	return result
 }
+
+// writeJavap responds with a class rendered as javap -v -p -c would, or
+// with an error if it can't be read.
+func writeJavap(c *gin.Context, name string, classFile []byte) {
+	if name == "" {
+		name = "uploaded.class"
+	}
+	text, err := javapText(name, time.Time{}, classFile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.String(http.StatusOK, text)
+}
+
+// checkFiles prints the diagnostics for each class file and reports
+// whether all of them are free of errors.
+func checkFiles(w io.Writer, paths []string) bool {
+	ok := true
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			ok = false
+			continue
+		}
+		_, diagnostics := checkClassFile(data)
+		for _, d := range diagnostics {
+			fmt.Fprintf(w, "%s:%d-%d: %s: %s\n", path, d.StartIndex, d.EndIndex, d.Severity, d.Message)
+			if d.Detail != "" {
+				fmt.Fprintf(w, "\t%s\n", strings.Replace(d.Detail, "\n", "\n\t", -1))
+			}
+		}
+		if hasErrors(diagnostics) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// maxUploadSize bounds how much of a request body we are willing to read.
+const maxUploadSize = 32 << 20
+
+// readUpload returns the file sent with a request, either as the given
+// field of a multipart form or as the raw request body, along with its
+// name. Raw bodies are named by the "name" query parameter.
+func readUpload(w http.ResponseWriter, req *http.Request, field string) ([]byte, string, error) {
+	req.Body = http.MaxBytesReader(w, req.Body, maxUploadSize)
+	var r io.Reader = req.Body
+	name := req.URL.Query().Get("name")
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := req.FormFile(field)
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+		r = file
+		name = header.Filename
+	}
+	data, err := ioutil.ReadAll(r)
+	return data, name, err
+}
+
+// entryJSON describes an archive entry: class files are parsed as for
+// /class, text files such as MANIFEST.MF are returned as is, and anything
+// else is only described.
+func entryJSON(name string, data []byte) gin.H {
+	if isClassEntry(name) {
+		result := classJSON(data)
+		result["name"] = name
+		return result
+	}
+	if isText(data) {
+		return gin.H{"name": name, "text": string(data)}
+	}
+	return gin.H{"name": name, "binary": len(data)}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type classResponse struct {
+	Parsed      []Section
+	Diagnostics []Diagnostic
+}
+
+func request(t *testing.T, r http.Handler, method, url string, body []byte) (classResponse, []byte) {
+	req := httptest.NewRequest(method, url, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s %s: status %d: %s", method, url, w.Code, w.Body.String())
+		return classResponse{}, nil
+	}
+	var response classResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Errorf("%s %s: %v", method, url, err)
+	}
+	parsed, _ := json.Marshal(response.Parsed)
+	return response, parsed
+}
+
+// checkIds fails unless the sections are numbered 1 to n with no gaps or
+// repeats and every target names one of them.
+func checkIds(t *testing.T, name string, sections []Section) {
+	seen := map[int]bool{}
+	var targets []int
+	var walk func([]Section)
+	walk = func(sections []Section) {
+		for _, s := range sections {
+			if seen[s.Id] {
+				t.Errorf("%s: id %d is used twice", name, s.Id)
+			}
+			seen[s.Id] = true
+			if s.Target != 0 {
+				targets = append(targets, s.Target)
+			}
+			walk(s.Children)
+		}
+	}
+	walk(sections)
+	for id := 1; id <= len(seen); id++ {
+		if !seen[id] {
+			t.Errorf("%s: ids skip %d", name, id)
+		}
+	}
+	for _, target := range targets {
+		if !seen[target] {
+			t.Errorf("%s: target %d isn't a section", name, target)
+		}
+	}
+}
+
+func TestConcurrentClassRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hello, err := ioutil.ReadFile("static/HelloWorld.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := hello[:len(hello)/2]
+	r := newRouter(hello, nil, newArchiveStore(), nil)
+
+	type call struct {
+		method, url string
+		body        []byte
+	}
+	calls := []call{
+		{"GET", "/class", nil},
+		{"POST", "/class?name=HelloWorld.class", hello},
+		{"POST", "/class?name=Truncated.class", truncated},
+	}
+	want := make([][]byte, len(calls))
+	for i, c := range calls {
+		response, parsed := request(t, r, c.method, c.url, c.body)
+		checkIds(t, c.url, response.Parsed)
+		want[i] = parsed
+	}
+	if !bytes.Equal(want[0], want[1]) {
+		t.Error("GET /class and POST /class of the same class differ")
+	}
+
+	// Cutting a class short throws away the sections of whatever it
+	// ends in, which mustn't leave gaps in the ids or targets to nothing.
+	var names []string
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append(names, "HelloWorld.class")
+	for _, name := range names {
+		data := readFixture(t, name)
+		for n := 0; n <= len(data); n++ {
+			sections, _ := checkClassFile(data[:n])
+			checkIds(t, fmt.Sprintf("%s cut to %d bytes", name, n), sections)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 60; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			c := calls[n%len(calls)]
+			response, parsed := request(t, r, c.method, c.url, c.body)
+			checkIds(t, c.url, response.Parsed)
+			if !bytes.Equal(parsed, want[n%len(calls)]) {
+				t.Errorf("%s %s: sections differ from those of a lone request", c.method, c.url)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func TestPostClass(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := newRouter(nil, nil, newArchiveStore(), nil)
+	data := readFixture(t, "Branches6.class")
+	sections, diagnostics := parseClass(data)
+	want, _ := json.Marshal(sections)
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("class", "Branches6.class")
+	part.Write(data)
+	writer.Close()
+
+	for _, test := range []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"raw body", "application/octet-stream", data},
+		{"multipart form", writer.FormDataContentType(), form.Bytes()},
+	} {
+		req := httptest.NewRequest("POST", "/class", bytes.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", test.name, w.Code, w.Body.String())
+			continue
+		}
+		var response struct {
+			Raw []string
+			classResponse
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if raw := strings.Join(response.Raw, ""); raw != hex.EncodeToString(data) {
+			t.Errorf("%s: raw bytes %s", test.name, raw)
+		}
+		if parsed, _ := json.Marshal(response.Parsed); !bytes.Equal(parsed, want) {
+			t.Errorf("%s: sections differ from those of parseClass", test.name)
+		}
+		if len(response.Diagnostics) != len(diagnostics) {
+			t.Errorf("%s: diagnostics %v", test.name, response.Diagnostics)
+		}
+		checkCoverage(t, test.name, response.Parsed, len(data))
+	}
+
+	// A form without the class field is a bad request.
+	var empty bytes.Buffer
+	writer = multipart.NewWriter(&empty)
+	writer.WriteField("other", "x")
+	writer.Close()
+	req := httptest.NewRequest("POST", "/class", &empty)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("form without a class: status %d", w.Code)
+	}
+}
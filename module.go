@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var moduleFlags = []flagDescription{
+	{Open, "open"},
+	{Synthetic, "synthetic"},
+	{Mandated, "mandated"},
+}
+
+var requiresFlags = []flagDescription{
+	{Transitive, "transitive"},
+	{StaticPhase, "static phase"},
+	{Synthetic, "synthetic"},
+	{Mandated, "mandated"},
+}
+
+// exportsFlags are the flags of both exports and opens directives.
+var exportsFlags = []flagDescription{
+	{Synthetic, "synthetic"},
+	{Mandated, "mandated"},
+}
+
+// moduleAttribute is the contents of a Module attribute, which describes
+// the module declared by a module-info class (JVMS §4.7.25).
+type moduleAttribute struct {
+	name     uint16
+	flags    accessFlags
+	version  uint16
+	requires []moduleRequires
+	exports  []modulePackage
+	opens    []modulePackage
+	uses     []uint16
+	provides []moduleProvides
+}
+
+type moduleRequires struct {
+	module  uint16
+	flags   accessFlags
+	version uint16
+}
+
+// modulePackage is an exports or opens directive: a package, and the
+// modules it is limited to if any.
+type modulePackage struct {
+	pkg   uint16
+	flags accessFlags
+	to    []uint16
+}
+
+type moduleProvides struct {
+	service uint16
+	with    []uint16
+}
+
+func parseModuleAttributeInfo(info []byte) (moduleAttribute, error) {
+	r := newByteParser(info, 0)
+	indexes := func() []uint16 {
+		count := int(r.u2())
+		var indexes []uint16
+		for i := 0; i < count && r.err == nil; i++ {
+			indexes = append(indexes, r.u2())
+		}
+		return indexes
+	}
+	m := moduleAttribute{name: r.u2(), flags: accessFlags(r.u2()), version: r.u2()}
+	count := int(r.u2())
+	for i := 0; i < count && r.err == nil; i++ {
+		m.requires = append(m.requires, moduleRequires{r.u2(), accessFlags(r.u2()), r.u2()})
+	}
+	for _, packages := range []*[]modulePackage{&m.exports, &m.opens} {
+		count = int(r.u2())
+		for i := 0; i < count && r.err == nil; i++ {
+			pkg, flags := r.u2(), accessFlags(r.u2())
+			*packages = append(*packages, modulePackage{pkg, flags, indexes()})
+		}
+	}
+	m.uses = indexes()
+	count = int(r.u2())
+	for i := 0; i < count && r.err == nil; i++ {
+		service := r.u2()
+		m.provides = append(m.provides, moduleProvides{service, indexes()})
+	}
+	if r.err != nil || r.pos != len(info) {
+		return moduleAttribute{}, errors.New("the Module attribute is malformed")
+	}
+	return m, nil
+}
+
+// javaName renders the module, package or class at index as it would be
+// written in module-info.java, or a marker if it isn't of the given kind.
+func (p *sectionParser) javaName(index uint16, kind string) string {
+	if marker := p.checkConstant(index, kind); marker != "" {
+		return marker
+	}
+	return strings.Replace(p.constantValue(index), "/", ".", -1)
+}
+
+func (p *sectionParser) javaNames(indexes []uint16, kind string) string {
+	names := make([]string, len(indexes))
+	for i, index := range indexes {
+		names[i] = p.javaName(index, kind)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (p *sectionParser) moduleHeader(m moduleAttribute) string {
+	header := "module " + p.javaName(m.name, moduleKind)
+	if m.flags&Open != 0 {
+		header = "open " + header
+	}
+	if version, ok := p.constantPoolUtf8(m.version); ok {
+		header += "@" + version
+	}
+	return header
+}
+
+func (p *sectionParser) requiresDirective(r moduleRequires) string {
+	directive := "requires "
+	if r.flags&Transitive != 0 {
+		directive += "transitive "
+	}
+	if r.flags&StaticPhase != 0 {
+		directive += "static "
+	}
+	return directive + p.javaName(r.module, moduleKind) + ";"
+}
+
+// packageDirective renders an exports or opens directive.
+func (p *sectionParser) packageDirective(keyword string, e modulePackage) string {
+	directive := keyword + " " + p.javaName(e.pkg, packageKind)
+	if len(e.to) > 0 {
+		directive += " to " + p.javaNames(e.to, moduleKind)
+	}
+	return directive + ";"
+}
+
+func (p *sectionParser) usesDirective(service uint16) string {
+	return "uses " + p.javaName(service, classKind) + ";"
+}
+
+func (p *sectionParser) providesDirective(provides moduleProvides) string {
+	return fmt.Sprintf("provides %s with %s;", p.javaName(provides.service, classKind), p.javaNames(provides.with, classKind))
+}
+
+// moduleDeclaration renders m as the module-info.java it was compiled
+// from. Directives that javac added itself, such as requires java.base,
+// are left out as they weren't in the source.
+func (p *sectionParser) moduleDeclaration(m moduleAttribute) string {
+	written := func(flags accessFlags) bool {
+		return flags&(Synthetic|Mandated) == 0
+	}
+	groups := make([][]string, 5)
+	for _, r := range m.requires {
+		if written(r.flags) {
+			groups[0] = append(groups[0], p.requiresDirective(r))
+		}
+	}
+	for _, e := range m.exports {
+		if written(e.flags) {
+			groups[1] = append(groups[1], p.packageDirective("exports", e))
+		}
+	}
+	for _, o := range m.opens {
+		if written(o.flags) {
+			groups[2] = append(groups[2], p.packageDirective("opens", o))
+		}
+	}
+	for _, u := range m.uses {
+		groups[3] = append(groups[3], p.usesDirective(u))
+	}
+	for _, provides := range m.provides {
+		groups[4] = append(groups[4], p.providesDirective(provides))
+	}
+
+	var b strings.Builder
+	b.WriteString(p.moduleHeader(m) + " {\n")
+	blank := false
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		if blank {
+			b.WriteString("\n")
+		}
+		for _, directive := range group {
+			b.WriteString("    " + directive + "\n")
+		}
+		blank = true
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// moduleSource renders the Module attribute of a module-info class as Java
+// source, to stand in for the source file it was compiled from.
+func moduleSource(classFile []byte) (string, bool) {
+	c, err := ParseClass(bytes.NewReader(classFile))
+	if err != nil || c.AccessFlags&Module == 0 {
+		return "", false
+	}
+	for _, a := range c.attributes {
+		if a.name != "Module" {
+			continue
+		}
+		m, err := parseModuleAttributeInfo(a.info)
+		if err != nil {
+			return "", false
+		}
+		p := &sectionParser{constantPool: c.ConstantPoolItems}
+		return p.moduleDeclaration(m), true
+	}
+	return "", false
+}
+
+// parseModule reads a Module attribute. Each directive's section reads as
+// it would in module-info.java, e.g. "exports com.example.api to
+// com.example.app;".
+func (p *sectionParser) parseModule(bytes []byte, start, end int) []Section {
+	m, err := parseModuleAttributeInfo(bytes[start:end])
+	if err != nil {
+		return p.malformedInfo("Module", start, end)
+	}
+	_, flags := p.parseFlags(bytes, start+2, moduleFlags)
+	flags.Name = "module flags"
+	sections := []Section{
+		p.indexSection(bytes, start, "module name index", moduleKind),
+		*flags,
+		p.optionalIndexSection(bytes, start+4, "module version index", utf8Kind),
+	}
+	next := start + 6
+	count := func(label string, n int) {
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 2,
+			Name:       fmt.Sprintf("%s: %d", label, n),
+		})
+		next += 2
+	}
+
+	count("requires count", len(m.requires))
+	for _, r := range m.requires {
+		_, flags := p.parseFlags(bytes, next+2, requiresFlags)
+		flags.Name = "requires flags"
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 6,
+			Name:       p.requiresDirective(r),
+			Children: []Section{
+				p.indexSection(bytes, next, "requires index", moduleKind),
+				*flags,
+				p.optionalIndexSection(bytes, next+4, "requires version index", utf8Kind),
+			},
+		})
+		next += 6
+	}
+	for _, keyword := range []string{"exports", "opens"} {
+		packages := m.exports
+		if keyword == "opens" {
+			packages = m.opens
+		}
+		count(keyword+" count", len(packages))
+		for _, e := range packages {
+			_, flags := p.parseFlags(bytes, next+2, exportsFlags)
+			flags.Name = keyword + " flags"
+			children := append([]Section{
+				p.indexSection(bytes, next, keyword+" index", packageKind),
+				*flags,
+			}, p.moduleIndexList(bytes, next+4, keyword+" to count", keyword+" to index", moduleKind, len(e.to))...)
+			end := next + 6 + 2*len(e.to)
+			sections = append(sections, Section{
+				Id:         p.nextId(),
+				StartIndex: next,
+				EndIndex:   end,
+				Name:       p.packageDirective(keyword, e),
+				Children:   children,
+			})
+			next = end
+		}
+	}
+	count("uses count", len(m.uses))
+	for _, u := range m.uses {
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 2,
+			Name:       p.usesDirective(u),
+			Children:   []Section{p.indexSection(bytes, next, "uses index", classKind)},
+		})
+		next += 2
+	}
+	count("provides count", len(m.provides))
+	for _, provides := range m.provides {
+		end := next + 4 + 2*len(provides.with)
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   end,
+			Name:       p.providesDirective(provides),
+			Children: append([]Section{
+				p.indexSection(bytes, next, "provides index", classKind),
+			}, p.moduleIndexList(bytes, next+2, "provides with count", "provides with index", classKind, len(provides.with))...),
+		})
+		next = end
+	}
+	return sections
+}
+
+// moduleIndexList is the sections for a u2 count followed by that many
+// indexes of the given kind, such as the modules a package is exported to.
+func (p *sectionParser) moduleIndexList(bytes []byte, index int, countLabel, label, kind string, count int) []Section {
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 2,
+		Name:       fmt.Sprintf("%s: %d", countLabel, count),
+	}}
+	for i := 0; i < count; i++ {
+		sections = append(sections, p.indexSection(bytes, index+2+2*i, label, kind))
+	}
+	return sections
+}
@@ -0,0 +1,116 @@
+package main
+
+import "testing"
+
+func TestParseModule(t *testing.T) {
+	sections := parsedFixture(t, "module-info.class")
+	checkOutline(t, findSection(t, sections, "attribute Module"), 3, `
+412-480 attribute Module
+  412-414 name index: #22 -> Module
+  414-418 length: 62
+  418-420 module name index: #3 -> com.example.app
+  420-422 module flags
+    421-422 0x0020 open: false
+    420-421 0x1000 synthetic: false
+    420-421 0x8000 mandated: false
+  422-424 module version index: #0 -> none
+  424-426 requires count: 3
+  426-432 requires java.base;
+    426-428 requires index: #5 -> java.base
+    428-430 requires flags
+      429-430 0x0020 transitive: false
+      429-430 0x0040 static phase: false
+      428-429 0x1000 synthetic: false
+      428-429 0x8000 mandated: true
+    430-432 requires version index: #1 -> 11
+  432-438 requires java.logging;
+    432-434 requires index: #7 -> java.logging
+    434-436 requires flags
+      435-436 0x0020 transitive: false
+      435-436 0x0040 static phase: false
+      434-435 0x1000 synthetic: false
+      434-435 0x8000 mandated: false
+    436-438 requires version index: #1 -> 11
+  438-444 requires transitive java.sql;
+    438-440 requires index: #9 -> java.sql
+    440-442 requires flags
+      441-442 0x0020 transitive: true
+      441-442 0x0040 static phase: false
+      440-441 0x1000 synthetic: false
+      440-441 0x8000 mandated: false
+    442-444 requires version index: #1 -> 11
+  444-446 exports count: 2
+  446-452 exports com.example.app.api;
+    446-448 exports index: #11 -> com/example/app/api
+    448-450 exports flags
+      448-449 0x1000 synthetic: false
+      448-449 0x8000 mandated: false
+      449-450 reserved: 0x00
+    450-452 exports to count: 0
+  452-460 exports com.example.app.spi to com.example.plugin;
+    452-454 exports index: #13 -> com/example/app/spi
+    454-456 exports flags
+      454-455 0x1000 synthetic: false
+      454-455 0x8000 mandated: false
+      455-456 reserved: 0x00
+    456-458 exports to count: 1
+    458-460 exports to index: #15 -> com.example.plugin
+  460-462 opens count: 1
+  462-468 opens com.example.app.model;
+    462-464 opens index: #17 -> com/example/app/model
+    464-466 opens flags
+      464-465 0x1000 synthetic: false
+      464-465 0x8000 mandated: false
+      465-466 reserved: 0x00
+    466-468 opens to count: 0
+  468-470 uses count: 1
+  470-472 uses com.example.app.spi.Plugin;
+    470-472 uses index: #19 -> com/example/app/spi/Plugin
+  472-474 provides count: 1
+  474-480 provides com.example.app.spi.Plugin with com.example.app.internal.DefaultPlugin;
+    474-476 provides index: #19 -> com/example/app/spi/Plugin
+    476-478 provides with count: 1
+    478-480 provides with index: #21 -> com/example/app/internal/DefaultPlugin
+`)
+	checkOutline(t, findSection(t, sections, "attribute ModulePackages"), 1, `
+480-498 attribute ModulePackages
+  480-482 name index: #27 -> ModulePackages
+  482-486 length: 12
+  486-488 package count: 5
+  488-490 package index: #24 -> com/example/app
+  490-492 package index: #11 -> com/example/app/api
+  492-494 package index: #13 -> com/example/app/spi
+  494-496 package index: #17 -> com/example/app/model
+  496-498 package index: #26 -> com/example/app/internal
+`)
+	checkOutline(t, findSection(t, sections, "attribute ModuleMainClass"), 1, `
+498-506 attribute ModuleMainClass
+  498-500 name index: #30 -> ModuleMainClass
+  500-504 length: 2
+  504-506 main class index: #29 -> com/example/app/Main
+`)
+}
+
+func TestModuleSource(t *testing.T) {
+	source, ok := moduleSource(readFixture(t, "module-info.class"))
+	want := `module com.example.app {
+    requires java.logging;
+    requires transitive java.sql;
+
+    exports com.example.app.api;
+    exports com.example.app.spi to com.example.plugin;
+
+    opens com.example.app.model;
+
+    uses com.example.app.spi.Plugin;
+
+    provides com.example.app.spi.Plugin with com.example.app.internal.DefaultPlugin;
+}
+`
+	if !ok || source != want {
+		t.Errorf("module-info.java is\n%s\nwant\n%s", source, want)
+	}
+	if source, ok := moduleSource(readFixture(t, "Hello11.class")); ok {
+		t.Errorf("Hello11 has module source\n%s", source)
+	}
+}
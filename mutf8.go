@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// decodeModifiedUTF8 decodes the modified UTF-8 of a CONSTANT_Utf8 (JVMS
+// §4.4.7) into the UTF-16 code units of the Java string it holds. Unlike
+// standard UTF-8, NUL is written as 0xC0 0x80 and characters outside the
+// Basic Multilingual Plane as a surrogate pair of three bytes each. Bytes
+// that aren't valid decode as U+FFFD and, unless report is nil, are
+// described to it by their offset from the start of b.
+func decodeModifiedUTF8(b []byte, report func(offset, length int, format string, args ...interface{})) []uint16 {
+	units := make([]uint16, 0, len(b))
+	invalid := func(offset, length int, format string, args ...interface{}) {
+		units = append(units, unicode.ReplacementChar)
+		if report != nil {
+			report(offset, length, format, args...)
+		}
+	}
+	continuation := func(i int) bool {
+		return i < len(b) && b[i]&0xC0 == 0x80
+	}
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0:
+			invalid(i, 1, "a zero byte, which modified UTF-8 writes as 0xC0 0x80")
+			i++
+		case c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c >= 0xF0:
+			invalid(i, 1, "byte 0x%02x, which can't appear in modified UTF-8", c)
+			i++
+		case c < 0xC0:
+			// Treat a run of stray continuation bytes as one problem.
+			n := 1
+			for continuation(i + n) {
+				n++
+			}
+			if n == 1 {
+				invalid(i, n, "a continuation byte without a leading byte")
+			} else {
+				invalid(i, n, "%d continuation bytes without a leading byte", n)
+			}
+			i += n
+		default:
+			size := 2
+			if c >= 0xE0 {
+				size = 3
+			}
+			n := 1
+			for n < size && continuation(i+n) {
+				n++
+			}
+			if n < size {
+				invalid(i, n, "a %d-byte sequence that is cut short after %d bytes", size, n)
+				i += n
+				break
+			}
+			var value uint16
+			if size == 2 {
+				value = uint16(c&0x1F)<<6 | uint16(b[i+1]&0x3F)
+			} else {
+				value = uint16(c&0x0F)<<12 | uint16(b[i+1]&0x3F)<<6 | uint16(b[i+2]&0x3F)
+			}
+			// NUL is the one character written with more bytes than
+			// it needs.
+			if (size == 2 && value < 0x80 && value != 0) || (size == 3 && value < 0x800) {
+				invalid(i, size, "an overlong encoding of U+%04X", value)
+			} else {
+				units = append(units, value)
+			}
+			i += size
+		}
+	}
+	return units
+}
+
+// javaString converts the UTF-16 code units of a Java string to a Go
+// string. Unpaired surrogates, which Java strings may hold but Go strings
+// can't, become U+FFFD.
+func javaString(units []uint16) string {
+	var b strings.Builder
+	b.Grow(len(units))
+	for i := 0; i < len(units); i++ {
+		r := rune(units[i])
+		if utf16.IsSurrogate(r) && i+1 < len(units) {
+			if pair := utf16.DecodeRune(r, rune(units[i+1])); pair != unicode.ReplacementChar {
+				r = pair
+				i++
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// quoteJavaString quotes the UTF-16 code units of a Java string as a Java
+// string literal. Control characters, unpaired surrogates and anything
+// else unprintable, such as the \u0001 placeholders of string
+// concatenation recipes, are written as \u escapes.
+func quoteJavaString(units []uint16) string {
+	return quoteJava(units, '"')
+}
+
+// quoteJavaChar quotes a char as a Java character literal, escaping it as
+// quoteJavaString would.
+func quoteJavaChar(unit uint16) string {
+	return quoteJava([]uint16{unit}, '\'')
+}
+
+// quoteJava quotes units between a pair of quote characters.
+func quoteJava(units []uint16, quote byte) string {
+	var b strings.Builder
+	b.WriteByte(quote)
+	for i := 0; i < len(units); i++ {
+		r, n := rune(units[i]), 1
+		if utf16.IsSurrogate(r) && i+1 < len(units) {
+			if pair := utf16.DecodeRune(r, rune(units[i+1])); pair != unicode.ReplacementChar {
+				r, n = pair, 2
+			}
+		}
+		switch {
+		case r == rune(quote) || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case utf16.IsSurrogate(r) || !unicode.IsPrint(r):
+			for _, u := range units[i : i+n] {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+		i += n - 1
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
+
+// javaQuote quotes s as a Java string literal, as quoteJavaString does.
+func javaQuote(s string) string {
+	return quoteJavaString(utf16.Encode([]rune(s)))
+}
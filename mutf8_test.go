@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeModifiedUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytes    []byte
+		units    []uint16
+		problems []string
+		quoted   string
+	}{
+		{
+			name:   "ASCII",
+			bytes:  []byte("a\"b"),
+			units:  []uint16{'a', '"', 'b'},
+			quoted: `"a\"b"`,
+		},
+		{
+			name:   "NUL",
+			bytes:  []byte{'a', 0xC0, 0x80, 'b'},
+			units:  []uint16{'a', 0, 'b'},
+			quoted: `"a\u0000b"`,
+		},
+		{
+			name:     "zero byte",
+			bytes:    []byte{'a', 0x00},
+			units:    []uint16{'a', 0xFFFD},
+			problems: []string{"1-2: a zero byte, which modified UTF-8 writes as 0xC0 0x80"},
+			quoted:   `"a�"`,
+		},
+		{
+			name:   "two and three bytes",
+			bytes:  []byte{0xC3, 0xA9, 0xE2, 0x82, 0xAC},
+			units:  []uint16{0xE9, 0x20AC},
+			quoted: `"é€"`,
+		},
+		{
+			name:   "surrogate pair",
+			bytes:  []byte{0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80},
+			units:  []uint16{0xD83D, 0xDE00},
+			quoted: `"😀"`,
+		},
+		{
+			name:   "unpaired surrogate",
+			bytes:  []byte{0xED, 0xA0, 0xBD, 'a'},
+			units:  []uint16{0xD83D, 'a'},
+			quoted: `"\ud83da"`,
+		},
+		{
+			name:     "four-byte UTF-8",
+			bytes:    []byte{0xF0, 0x9F, 0x98, 0x80},
+			units:    []uint16{0xFFFD, 0xFFFD},
+			problems: []string{"0-1: byte 0xf0, which can't appear in modified UTF-8", "1-4: 3 continuation bytes without a leading byte"},
+			quoted:   `"��"`,
+		},
+		{
+			name:  "overlong encodings",
+			bytes: []byte{0xC1, 0x81, 0xE0, 0x81, 0x81, 0xE0, 0x80, 0x80, 0xE0, 0x9F, 0xBF},
+			units: []uint16{0xFFFD, 0xFFFD, 0xFFFD, 0xFFFD},
+			problems: []string{
+				"0-2: an overlong encoding of U+0041",
+				"2-5: an overlong encoding of U+0041",
+				"5-8: an overlong encoding of U+0000",
+				"8-11: an overlong encoding of U+07FF",
+			},
+			quoted: `"����"`,
+		},
+		{
+			name:     "stray continuation byte",
+			bytes:    []byte{'a', 0x80, 'b'},
+			units:    []uint16{'a', 0xFFFD, 'b'},
+			problems: []string{"1-2: a continuation byte without a leading byte"},
+			quoted:   `"a�b"`,
+		},
+		{
+			name:     "cut short",
+			bytes:    []byte{0xE2, 0x82, 'a', 0xC3},
+			units:    []uint16{0xFFFD, 'a', 0xFFFD},
+			problems: []string{"0-2: a 3-byte sequence that is cut short after 2 bytes", "3-4: a 2-byte sequence that is cut short after 1 bytes"},
+			quoted:   `"�a�"`,
+		},
+	}
+	for _, test := range tests {
+		var problems []string
+		units := decodeModifiedUTF8(test.bytes, func(offset, length int, format string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf("%d-%d: ", offset, offset+length)+fmt.Sprintf(format, args...))
+		})
+		if !reflect.DeepEqual(units, test.units) {
+			t.Errorf("%s: decoded as %#04x, want %#04x", test.name, units, test.units)
+		}
+		if !reflect.DeepEqual(problems, test.problems) {
+			t.Errorf("%s: problems\n%q\nwant\n%q", test.name, problems, test.problems)
+		}
+		if quoted := quoteJavaString(units); quoted != test.quoted {
+			t.Errorf("%s: quoted as %s, want %s", test.name, quoted, test.quoted)
+		}
+	}
+}
+
+func TestJavaString(t *testing.T) {
+	tests := []struct {
+		units []uint16
+		want  string
+	}{
+		{[]uint16{'a', 0xD83D, 0xDE00}, "a😀"},
+		{[]uint16{0xD83D, 'a'}, "�a"},
+		{[]uint16{0xDE00, 0xD83D}, "��"},
+		{[]uint16{0xD83D}, "�"},
+	}
+	for _, test := range tests {
+		if s := javaString(test.units); s != test.want {
+			t.Errorf("%#04x: converted to %q, want %q", test.units, s, test.want)
+		}
+	}
+}
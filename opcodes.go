@@ -0,0 +1,465 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// operandLayout describes the bytes that follow an opcode.
+type operandLayout int
+
+const (
+	noOperands operandLayout = iota
+	localOperand
+	byteOperand
+	shortOperand
+	constantOperand1
+	constantOperand2
+	branchOperand2
+	branchOperand4
+	iincOperands
+	invokeInterfaceOperands
+	invokeDynamicOperands
+	newArrayOperand
+	multiANewArrayOperands
+	tableSwitchOperands
+	lookupSwitchOperands
+	wideOperands
+)
+
+type opcode struct {
+	name     string
+	operands operandLayout
+}
+
+var opcodes = [256]opcode{
+	0x00: {"nop", noOperands},
+	0x01: {"aconst_null", noOperands},
+	0x02: {"iconst_m1", noOperands},
+	0x03: {"iconst_0", noOperands},
+	0x04: {"iconst_1", noOperands},
+	0x05: {"iconst_2", noOperands},
+	0x06: {"iconst_3", noOperands},
+	0x07: {"iconst_4", noOperands},
+	0x08: {"iconst_5", noOperands},
+	0x09: {"lconst_0", noOperands},
+	0x0a: {"lconst_1", noOperands},
+	0x0b: {"fconst_0", noOperands},
+	0x0c: {"fconst_1", noOperands},
+	0x0d: {"fconst_2", noOperands},
+	0x0e: {"dconst_0", noOperands},
+	0x0f: {"dconst_1", noOperands},
+	0x10: {"bipush", byteOperand},
+	0x11: {"sipush", shortOperand},
+	0x12: {"ldc", constantOperand1},
+	0x13: {"ldc_w", constantOperand2},
+	0x14: {"ldc2_w", constantOperand2},
+	0x15: {"iload", localOperand},
+	0x16: {"lload", localOperand},
+	0x17: {"fload", localOperand},
+	0x18: {"dload", localOperand},
+	0x19: {"aload", localOperand},
+	0x1a: {"iload_0", noOperands},
+	0x1b: {"iload_1", noOperands},
+	0x1c: {"iload_2", noOperands},
+	0x1d: {"iload_3", noOperands},
+	0x1e: {"lload_0", noOperands},
+	0x1f: {"lload_1", noOperands},
+	0x20: {"lload_2", noOperands},
+	0x21: {"lload_3", noOperands},
+	0x22: {"fload_0", noOperands},
+	0x23: {"fload_1", noOperands},
+	0x24: {"fload_2", noOperands},
+	0x25: {"fload_3", noOperands},
+	0x26: {"dload_0", noOperands},
+	0x27: {"dload_1", noOperands},
+	0x28: {"dload_2", noOperands},
+	0x29: {"dload_3", noOperands},
+	0x2a: {"aload_0", noOperands},
+	0x2b: {"aload_1", noOperands},
+	0x2c: {"aload_2", noOperands},
+	0x2d: {"aload_3", noOperands},
+	0x2e: {"iaload", noOperands},
+	0x2f: {"laload", noOperands},
+	0x30: {"faload", noOperands},
+	0x31: {"daload", noOperands},
+	0x32: {"aaload", noOperands},
+	0x33: {"baload", noOperands},
+	0x34: {"caload", noOperands},
+	0x35: {"saload", noOperands},
+	0x36: {"istore", localOperand},
+	0x37: {"lstore", localOperand},
+	0x38: {"fstore", localOperand},
+	0x39: {"dstore", localOperand},
+	0x3a: {"astore", localOperand},
+	0x3b: {"istore_0", noOperands},
+	0x3c: {"istore_1", noOperands},
+	0x3d: {"istore_2", noOperands},
+	0x3e: {"istore_3", noOperands},
+	0x3f: {"lstore_0", noOperands},
+	0x40: {"lstore_1", noOperands},
+	0x41: {"lstore_2", noOperands},
+	0x42: {"lstore_3", noOperands},
+	0x43: {"fstore_0", noOperands},
+	0x44: {"fstore_1", noOperands},
+	0x45: {"fstore_2", noOperands},
+	0x46: {"fstore_3", noOperands},
+	0x47: {"dstore_0", noOperands},
+	0x48: {"dstore_1", noOperands},
+	0x49: {"dstore_2", noOperands},
+	0x4a: {"dstore_3", noOperands},
+	0x4b: {"astore_0", noOperands},
+	0x4c: {"astore_1", noOperands},
+	0x4d: {"astore_2", noOperands},
+	0x4e: {"astore_3", noOperands},
+	0x4f: {"iastore", noOperands},
+	0x50: {"lastore", noOperands},
+	0x51: {"fastore", noOperands},
+	0x52: {"dastore", noOperands},
+	0x53: {"aastore", noOperands},
+	0x54: {"bastore", noOperands},
+	0x55: {"castore", noOperands},
+	0x56: {"sastore", noOperands},
+	0x57: {"pop", noOperands},
+	0x58: {"pop2", noOperands},
+	0x59: {"dup", noOperands},
+	0x5a: {"dup_x1", noOperands},
+	0x5b: {"dup_x2", noOperands},
+	0x5c: {"dup2", noOperands},
+	0x5d: {"dup2_x1", noOperands},
+	0x5e: {"dup2_x2", noOperands},
+	0x5f: {"swap", noOperands},
+	0x60: {"iadd", noOperands},
+	0x61: {"ladd", noOperands},
+	0x62: {"fadd", noOperands},
+	0x63: {"dadd", noOperands},
+	0x64: {"isub", noOperands},
+	0x65: {"lsub", noOperands},
+	0x66: {"fsub", noOperands},
+	0x67: {"dsub", noOperands},
+	0x68: {"imul", noOperands},
+	0x69: {"lmul", noOperands},
+	0x6a: {"fmul", noOperands},
+	0x6b: {"dmul", noOperands},
+	0x6c: {"idiv", noOperands},
+	0x6d: {"ldiv", noOperands},
+	0x6e: {"fdiv", noOperands},
+	0x6f: {"ddiv", noOperands},
+	0x70: {"irem", noOperands},
+	0x71: {"lrem", noOperands},
+	0x72: {"frem", noOperands},
+	0x73: {"drem", noOperands},
+	0x74: {"ineg", noOperands},
+	0x75: {"lneg", noOperands},
+	0x76: {"fneg", noOperands},
+	0x77: {"dneg", noOperands},
+	0x78: {"ishl", noOperands},
+	0x79: {"lshl", noOperands},
+	0x7a: {"ishr", noOperands},
+	0x7b: {"lshr", noOperands},
+	0x7c: {"iushr", noOperands},
+	0x7d: {"lushr", noOperands},
+	0x7e: {"iand", noOperands},
+	0x7f: {"land", noOperands},
+	0x80: {"ior", noOperands},
+	0x81: {"lor", noOperands},
+	0x82: {"ixor", noOperands},
+	0x83: {"lxor", noOperands},
+	0x84: {"iinc", iincOperands},
+	0x85: {"i2l", noOperands},
+	0x86: {"i2f", noOperands},
+	0x87: {"i2d", noOperands},
+	0x88: {"l2i", noOperands},
+	0x89: {"l2f", noOperands},
+	0x8a: {"l2d", noOperands},
+	0x8b: {"f2i", noOperands},
+	0x8c: {"f2l", noOperands},
+	0x8d: {"f2d", noOperands},
+	0x8e: {"d2i", noOperands},
+	0x8f: {"d2l", noOperands},
+	0x90: {"d2f", noOperands},
+	0x91: {"i2b", noOperands},
+	0x92: {"i2c", noOperands},
+	0x93: {"i2s", noOperands},
+	0x94: {"lcmp", noOperands},
+	0x95: {"fcmpl", noOperands},
+	0x96: {"fcmpg", noOperands},
+	0x97: {"dcmpl", noOperands},
+	0x98: {"dcmpg", noOperands},
+	0x99: {"ifeq", branchOperand2},
+	0x9a: {"ifne", branchOperand2},
+	0x9b: {"iflt", branchOperand2},
+	0x9c: {"ifge", branchOperand2},
+	0x9d: {"ifgt", branchOperand2},
+	0x9e: {"ifle", branchOperand2},
+	0x9f: {"if_icmpeq", branchOperand2},
+	0xa0: {"if_icmpne", branchOperand2},
+	0xa1: {"if_icmplt", branchOperand2},
+	0xa2: {"if_icmpge", branchOperand2},
+	0xa3: {"if_icmpgt", branchOperand2},
+	0xa4: {"if_icmple", branchOperand2},
+	0xa5: {"if_acmpeq", branchOperand2},
+	0xa6: {"if_acmpne", branchOperand2},
+	0xa7: {"goto", branchOperand2},
+	0xa8: {"jsr", branchOperand2},
+	0xa9: {"ret", localOperand},
+	0xaa: {"tableswitch", tableSwitchOperands},
+	0xab: {"lookupswitch", lookupSwitchOperands},
+	0xac: {"ireturn", noOperands},
+	0xad: {"lreturn", noOperands},
+	0xae: {"freturn", noOperands},
+	0xaf: {"dreturn", noOperands},
+	0xb0: {"areturn", noOperands},
+	0xb1: {"return", noOperands},
+	0xb2: {"getstatic", constantOperand2},
+	0xb3: {"putstatic", constantOperand2},
+	0xb4: {"getfield", constantOperand2},
+	0xb5: {"putfield", constantOperand2},
+	0xb6: {"invokevirtual", constantOperand2},
+	0xb7: {"invokespecial", constantOperand2},
+	0xb8: {"invokestatic", constantOperand2},
+	0xb9: {"invokeinterface", invokeInterfaceOperands},
+	0xba: {"invokedynamic", invokeDynamicOperands},
+	0xbb: {"new", constantOperand2},
+	0xbc: {"newarray", newArrayOperand},
+	0xbd: {"anewarray", constantOperand2},
+	0xbe: {"arraylength", noOperands},
+	0xbf: {"athrow", noOperands},
+	0xc0: {"checkcast", constantOperand2},
+	0xc1: {"instanceof", constantOperand2},
+	0xc2: {"monitorenter", noOperands},
+	0xc3: {"monitorexit", noOperands},
+	0xc4: {"wide", wideOperands},
+	0xc5: {"multianewarray", multiANewArrayOperands},
+	0xc6: {"ifnull", branchOperand2},
+	0xc7: {"ifnonnull", branchOperand2},
+	0xc8: {"goto_w", branchOperand4},
+	0xc9: {"jsr_w", branchOperand4},
+	0xca: {"breakpoint", noOperands},
+	0xfe: {"impdep1", noOperands},
+	0xff: {"impdep2", noOperands},
+}
+
+const (
+	opIinc = 0x84
+	opWide = 0xc4
+)
+
+// constantOperandKinds lists the kinds of constant an instruction's
+// constant pool operand may refer to.
+func constantOperandKinds(op uint8) []string {
+	switch opcodes[op].name {
+	case "ldc", "ldc_w":
+		return []string{intKind, floatKind, stringKind, classKind, methodTypeKind, methodHandleKind, dynamicKind}
+	case "ldc2_w":
+		return []string{longKind, doubleKind, dynamicKind}
+	case "getstatic", "putstatic", "getfield", "putfield":
+		return []string{fieldRefKind}
+	case "invokevirtual":
+		return []string{methodRefKind}
+	case "invokespecial", "invokestatic":
+		return []string{methodRefKind, interfaceMethodRefKind}
+	case "invokeinterface":
+		return []string{interfaceMethodRefKind}
+	case "invokedynamic":
+		return []string{invokeDynamicKind}
+	case "new", "anewarray", "checkcast", "instanceof", "multianewarray":
+		return []string{classKind}
+	}
+	return nil
+}
+
+var arrayTypes = map[int]string{
+	4:  "boolean",
+	5:  "char",
+	6:  "float",
+	7:  "double",
+	8:  "byte",
+	9:  "short",
+	10: "int",
+	11: "long",
+}
+
+// operandKind says how a decoded operand should be read.
+type operandKind int
+
+const (
+	localIndex operandKind = iota
+	immediate
+	constantIndex
+	branchOffset
+	argumentCount
+	reserved
+	arrayType
+	dimensions
+	padding
+	defaultOffset
+	lowValue
+	highValue
+	pairCount
+	matchValue
+)
+
+var operandKindNames = map[operandKind]string{
+	localIndex:    "local variable index",
+	immediate:     "value",
+	constantIndex: "constant pool index",
+	branchOffset:  "branch offset",
+	argumentCount: "argument count",
+	reserved:      "reserved",
+	arrayType:     "array type",
+	dimensions:    "dimensions",
+	padding:       "padding",
+	defaultOffset: "default offset",
+	lowValue:      "low",
+	highValue:     "high",
+	pairCount:     "pair count",
+	matchValue:    "match",
+}
+
+// operand is a single value following an opcode. Start is relative to the
+// beginning of the code array.
+type operand struct {
+	kind  operandKind
+	start int
+	size  int
+	value int
+}
+
+type instruction struct {
+	pc       int
+	opcode   uint8
+	wide     bool
+	length   int
+	operands []operand
+}
+
+func (i instruction) name() string {
+	return opcodes[i.opcode].name
+}
+
+// operand returns the first operand of the given kind.
+func (i instruction) operand(kind operandKind) (operand, bool) {
+	for _, o := range i.operands {
+		if o.kind == kind {
+			return o, true
+		}
+	}
+	return operand{}, false
+}
+
+var errTruncatedInstruction = errors.New("instruction runs past the end of the code")
+
+// codeReader reads signed and unsigned big endian values out of a code array.
+type codeReader struct {
+	code []byte
+	pos  int
+	err  error
+	ops  []operand
+}
+
+func (r *codeReader) read(kind operandKind, size int, signed bool) int {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+size > len(r.code) {
+		r.err = errTruncatedInstruction
+		return 0
+	}
+	var v int64
+	for k := 0; k < size; k++ {
+		v = v<<8 | int64(r.code[r.pos+k])
+	}
+	if signed {
+		shift := uint(64 - 8*size)
+		v = v << shift >> shift
+	}
+	r.ops = append(r.ops, operand{kind, r.pos, size, int(v)})
+	r.pos += size
+	return int(v)
+}
+
+// decodeInstruction decodes the instruction that starts at pc.
+func decodeInstruction(code []byte, pc int) (instruction, error) {
+	op := code[pc]
+	inst := instruction{pc: pc, opcode: op}
+	if opcodes[op].name == "" {
+		inst.length = 1
+		return inst, fmt.Errorf("unknown opcode 0x%02x", op)
+	}
+	r := &codeReader{code: code, pos: pc + 1}
+	switch opcodes[op].operands {
+	case localOperand:
+		r.read(localIndex, 1, false)
+	case byteOperand:
+		r.read(immediate, 1, true)
+	case shortOperand:
+		r.read(immediate, 2, true)
+	case constantOperand1:
+		r.read(constantIndex, 1, false)
+	case constantOperand2:
+		r.read(constantIndex, 2, false)
+	case branchOperand2:
+		r.read(branchOffset, 2, true)
+	case branchOperand4:
+		r.read(branchOffset, 4, true)
+	case iincOperands:
+		r.read(localIndex, 1, false)
+		r.read(immediate, 1, true)
+	case invokeInterfaceOperands:
+		r.read(constantIndex, 2, false)
+		r.read(argumentCount, 1, false)
+		r.read(reserved, 1, false)
+	case invokeDynamicOperands:
+		r.read(constantIndex, 2, false)
+		r.read(reserved, 2, false)
+	case newArrayOperand:
+		r.read(arrayType, 1, false)
+	case multiANewArrayOperands:
+		r.read(constantIndex, 2, false)
+		r.read(dimensions, 1, false)
+	case tableSwitchOperands, lookupSwitchOperands:
+		// The operands start at the next multiple of four from the
+		// beginning of the code array.
+		if pad := (4 - r.pos%4) % 4; pad > 0 {
+			r.read(padding, pad, false)
+		}
+		r.read(defaultOffset, 4, true)
+		if opcodes[op].operands == tableSwitchOperands {
+			low := r.read(lowValue, 4, true)
+			high := r.read(highValue, 4, true)
+			for k := int64(low); k <= int64(high) && r.err == nil; k++ {
+				r.read(branchOffset, 4, true)
+			}
+		} else {
+			pairs := r.read(pairCount, 4, true)
+			for k := 0; k < pairs && r.err == nil; k++ {
+				r.read(matchValue, 4, true)
+				r.read(branchOffset, 4, true)
+			}
+		}
+	case wideOperands:
+		inst.wide = true
+		if r.pos >= len(code) {
+			r.err = errTruncatedInstruction
+			break
+		}
+		inst.opcode = code[r.pos]
+		r.pos++
+		switch inst.opcode {
+		case 0x15, 0x16, 0x17, 0x18, 0x19, 0x36, 0x37, 0x38, 0x39, 0x3a, 0xa9:
+			r.read(localIndex, 2, false)
+		case opIinc:
+			r.read(localIndex, 2, false)
+			r.read(immediate, 2, true)
+		default:
+			r.err = fmt.Errorf("%s cannot be modified by wide", opcodes[inst.opcode].name)
+		}
+	}
+	inst.operands = r.ops
+	inst.length = r.pos - pc
+	if r.err != nil {
+		inst.length = len(code) - pc
+	}
+	return inst, r.err
+}
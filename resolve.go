@@ -0,0 +1,282 @@
+package main
+
+import "fmt"
+
+// constantSectionId returns the id of the section for the constant pool
+// item at index, or zero if there is no such item.
+func (p *sectionParser) constantSectionId(index uint16) int {
+	return p.constantPoolSections[index]
+}
+
+func (p *sectionParser) constantPoolItem(index uint16) (ConstantPoolItem, bool) {
+	if index == 0 || int(index) > len(p.constantPool) {
+		return nil, false
+	}
+	return p.constantPool[index-1], true
+}
+
+func (p *sectionParser) constantPoolUtf8(index uint16) (string, bool) {
+	item, _ := p.constantPoolItem(index)
+	u, ok := item.(utf8String)
+	return u.contents, ok
+}
+
+func (p *sectionParser) constantPoolClassName(index uint16) (string, bool) {
+	item, _ := p.constantPoolItem(index)
+	c, ok := item.(classInfo)
+	if !ok {
+		return "", false
+	}
+	return p.constantPoolUtf8(c.nameIndex)
+}
+
+// Names for each kind of constant, matching the labels used in the
+// constant pool section.
+const (
+	utf8Kind               = "UTF-8 string"
+	intKind                = "int"
+	floatKind              = "float"
+	longKind               = "long"
+	doubleKind             = "double"
+	classKind              = "class info"
+	stringKind             = "string constant"
+	fieldRefKind           = "field ref"
+	methodRefKind          = "method ref"
+	interfaceMethodRefKind = "interface method ref"
+	nameAndTypeKind        = "name and type"
+	methodHandleKind       = "method handle"
+	methodTypeKind         = "method type"
+	dynamicKind            = "dynamic"
+	invokeDynamicKind      = "invoke dynamic"
+	moduleKind             = "module"
+	packageKind            = "package"
+)
+
+func constantKind(item ConstantPoolItem) string {
+	switch item.(type) {
+	case utf8String:
+		return utf8Kind
+	case intConstant:
+		return intKind
+	case floatConstant:
+		return floatKind
+	case longConstant:
+		return longKind
+	case doubleConstant:
+		return doubleKind
+	case classInfo:
+		return classKind
+	case stringConstant:
+		return stringKind
+	case fieldRef:
+		return fieldRefKind
+	case methodRef:
+		return methodRefKind
+	case interfaceMethodRef:
+		return interfaceMethodRefKind
+	case nameAndType:
+		return nameAndTypeKind
+	case methodHandle:
+		return methodHandleKind
+	case methodType:
+		return methodTypeKind
+	case dynamicConstant:
+		return dynamicKind
+	case invokeDynamic:
+		return invokeDynamicKind
+	case moduleInfo:
+		return moduleKind
+	case packageInfo:
+		return packageKind
+	case WideConstantPart2:
+		return "second half of a long or double"
+	}
+	return "unknown constant"
+}
+
+var referenceKindNames = map[uint8]string{
+	1: "REF_getField",
+	2: "REF_getStatic",
+	3: "REF_putField",
+	4: "REF_putStatic",
+	5: "REF_invokeVirtual",
+	6: "REF_invokeStatic",
+	7: "REF_invokeSpecial",
+	8: "REF_newInvokeSpecial",
+	9: "REF_invokeInterface",
+}
+
+// referenceKindTargets lists the kinds of constant a method handle of the
+// given reference kind may point at.
+func referenceKindTargets(kind uint8) []string {
+	switch {
+	case kind >= 1 && kind <= 4:
+		return []string{fieldRefKind}
+	case kind == 5 || kind == 8:
+		return []string{methodRefKind}
+	case kind == 6 || kind == 7:
+		return []string{methodRefKind, interfaceMethodRefKind}
+	case kind == 9:
+		return []string{interfaceMethodRefKind}
+	}
+	return nil
+}
+
+// checkConstant returns a marker explaining why index is not a valid
+// reference to one of the expected kinds, or "" if it is. Passing no
+// kinds accepts anything in range.
+func (p *sectionParser) checkConstant(index uint16, expected ...string) string {
+	item, ok := p.constantPoolItem(index)
+	if !ok {
+		return fmt.Sprintf("<#%d is out of range>", index)
+	}
+	if len(expected) == 0 {
+		return ""
+	}
+	kind := constantKind(item)
+	for _, e := range expected {
+		if kind == e {
+			return ""
+		}
+	}
+	return fmt.Sprintf("<expected %s, found %s>", joinKinds(expected), kind)
+}
+
+func joinKinds(kinds []string) string {
+	s := kinds[0]
+	for i := 1; i < len(kinds); i++ {
+		if i == len(kinds)-1 {
+			s += " or "
+		} else {
+			s += ", "
+		}
+		s += kinds[i]
+	}
+	return s
+}
+
+// resolve renders a constant pool index along with what it refers to, e.g.
+// "#3 -> java/lang/Object".
+func (p *sectionParser) resolve(index uint16, expected ...string) string {
+	if marker := p.checkConstant(index, expected...); marker != "" {
+		return fmt.Sprintf("#%d -> %s", index, marker)
+	}
+	return fmt.Sprintf("#%d -> %s", index, p.constantValue(index))
+}
+
+// resolveOptional is resolve for indexes where zero means "none", such as
+// the super class of java/lang/Object or a catch-all exception handler.
+func (p *sectionParser) resolveOptional(index uint16, expected ...string) string {
+	if index == 0 {
+		return "#0 -> none"
+	}
+	return p.resolve(index, expected...)
+}
+
+// constantValue renders the item at index, following references through
+// class info and name and type entries down to their UTF-8 strings.
+func (p *sectionParser) constantValue(index uint16) string {
+	item, ok := p.constantPoolItem(index)
+	if !ok {
+		return fmt.Sprintf("<#%d is out of range>", index)
+	}
+	utf8 := func(i uint16) string {
+		if marker := p.checkConstant(i, utf8Kind); marker != "" {
+			return marker
+		}
+		s, _ := p.constantPoolUtf8(i)
+		return s
+	}
+	class := func(i uint16) string {
+		if marker := p.checkConstant(i, classKind); marker != "" {
+			return marker
+		}
+		return p.constantValue(i)
+	}
+	nat := func(i uint16) string {
+		if marker := p.checkConstant(i, nameAndTypeKind); marker != "" {
+			return marker
+		}
+		return p.constantValue(i)
+	}
+	switch c := item.(type) {
+	case utf8String:
+		return c.contents
+	case intConstant:
+		return fmt.Sprintf("%d", c.value)
+	case floatConstant:
+		return fmt.Sprintf("%v", c.value)
+	case longConstant:
+		return fmt.Sprintf("%d", c.value)
+	case doubleConstant:
+		return fmt.Sprintf("%v", c.value)
+	case classInfo:
+		return utf8(c.nameIndex)
+	case stringConstant:
+		return javaQuote(utf8(c.utf8Index))
+	case fieldRef:
+		return class(c.classIndex) + "." + nat(c.nameAndTypeIndex)
+	case methodRef:
+		return class(c.classIndex) + "." + nat(c.nameAndTypeIndex)
+	case interfaceMethodRef:
+		return class(c.classIndex) + "." + nat(c.nameAndTypeIndex)
+	case nameAndType:
+		return utf8(c.nameIndex) + ":" + utf8(c.descriptorIndex)
+	case methodHandle:
+		target := p.checkConstant(c.referenceIndex, referenceKindTargets(c.referenceKind)...)
+		if target == "" {
+			target = p.constantValue(c.referenceIndex)
+		}
+		return fmt.Sprintf("%s %s", referenceKindNames[c.referenceKind], target)
+	case methodType:
+		return utf8(c.descriptorIndex)
+	case dynamicConstant:
+		return p.withBootstrapMethod(fmt.Sprintf("#%d:%s", c.bootstrapMethodAttrIndex, nat(c.nameAndTypeIndex)), c.bootstrapMethodAttrIndex)
+	case invokeDynamic:
+		return p.withBootstrapMethod(fmt.Sprintf("#%d:%s", c.bootstrapMethodAttrIndex, nat(c.nameAndTypeIndex)), c.bootstrapMethodAttrIndex)
+	case moduleInfo:
+		return utf8(c.nameIndex)
+	case packageInfo:
+		return utf8(c.nameIndex)
+	}
+	return constantKind(item)
+}
+
+// describeConstant renders the constant pool item at index in the form
+// javap uses for its comments, e.g. "Method java/lang/Object.<init>:()V".
+func (p *sectionParser) describeConstant(index uint16) string {
+	item, ok := p.constantPoolItem(index)
+	if !ok {
+		return fmt.Sprintf("<#%d is out of range>", index)
+	}
+	value := p.constantValue(index)
+	switch item.(type) {
+	case intConstant, floatConstant, longConstant, doubleConstant:
+		return constantKind(item) + " " + value
+	case classInfo:
+		return "class " + value
+	case stringConstant:
+		return "String " + value
+	case fieldRef:
+		return "Field " + value
+	case methodRef:
+		return "Method " + value
+	case interfaceMethodRef:
+		return "InterfaceMethod " + value
+	case nameAndType:
+		return "NameAndType " + value
+	case methodHandle:
+		return "MethodHandle " + value
+	case methodType:
+		return "MethodType " + value
+	case dynamicConstant:
+		return "Dynamic " + value
+	case invokeDynamic:
+		return "InvokeDynamic " + value
+	case moduleInfo:
+		return "Module " + value
+	case packageInfo:
+		return "Package " + value
+	}
+	return value
+}
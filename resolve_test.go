@@ -0,0 +1,95 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	sections := parsedFixture(t, "Box5.class")
+	checkOutline(t, findSection(t, sections, "[15] method ref"), 1, `
+151-156 [15] method ref: java/lang/Object.<init>:()V
+  151-152 tag: 10
+  152-154 class info index: #11 -> java/lang/Object
+  154-156 name and type index: #14 -> <init>:()V
+`)
+	checkOutline(t, findSection(t, sections, "[19] field ref"), 1, `
+171-176 [19] field ref: Box5.value:Ljava/lang/Comparable;
+  171-172 tag: 9
+  172-174 class info index: #17 -> Box5
+  174-176 name and type index: #18 -> value:Ljava/lang/Comparable;
+`)
+	checkOutline(t, findSection(t, sections, "this class"), 0, `
+612-614 this class: #17 -> Box5
+`)
+	checkOutline(t, findSection(t, sections, "super class"), 0, `
+614-616 super class: #11 -> java/lang/Object
+`)
+
+	class := checkedClass(func(b *classBuilder, s *classSpec) {
+		b.constant("bad field ref", false, u1(9), u2(b.utf8("C")), u2(99))
+	})
+	sections, _ = parseClass(class)
+	checkCoverage(t, "bad field ref", sections, len(class))
+	checkOutline(t, findSection(t, sections, "constant pool", "[11] field ref"), 1, `
+76-81 [11] field ref: <expected class info, found UTF-8 string>.<#99 is out of range>
+  76-77 tag: 9
+  77-79 class info index: #10 -> <expected class info, found UTF-8 string>
+  79-81 name and type index: #99 -> <#99 is out of range>
+`)
+}
+
+// TestTargets checks that every link goes where its label says: indexes
+// to their constant, bootstrap method indexes to their entry in the
+// BootstrapMethods attribute, and code offsets to their instruction.
+func TestTargets(t *testing.T) {
+	constant := regexp.MustCompile(`#(\d+) ->`)
+	bootstrap := regexp.MustCompile(`^bootstrap method attribute index: (\d+)`)
+	number := regexp.MustCompile(`\d+`)
+	for name := range fixtures {
+		sections := parsedFixture(t, name)
+		byId := map[int]Section{}
+		var collect func(sections []Section)
+		collect = func(sections []Section) {
+			for _, s := range sections {
+				byId[s.Id] = s
+				collect(s.Children)
+			}
+		}
+		collect(sections)
+		targets := 0
+		for _, s := range byId {
+			if s.Target == 0 {
+				continue
+			}
+			targets++
+			target, ok := byId[s.Target]
+			var prefixes []string
+			if m := constant.FindStringSubmatch(s.Name); m != nil {
+				prefixes = []string{"[" + m[1] + "] "}
+			} else if m := bootstrap.FindStringSubmatch(s.Name); m != nil {
+				prefixes = []string{"bootstrap method " + m[1] + ": "}
+			} else {
+				for _, n := range number.FindAllString(s.Name, -1) {
+					prefixes = append(prefixes, n+": ")
+				}
+			}
+			linked := false
+			for _, prefix := range prefixes {
+				linked = linked || ok && strings.HasPrefix(target.Name, prefix)
+			}
+			if !linked {
+				t.Errorf("%s: %q at %d-%d links to %q", name, s.Name, s.StartIndex, s.EndIndex, target.Name)
+			}
+		}
+		if targets == 0 {
+			t.Errorf("%s: no links", name)
+		}
+	}
+
+	sections := parsedFixture(t, "Box5.class")
+	if this, box := findSection(t, sections, "this class"), findSection(t, sections, "[17] class info"); this.Target != box.Id {
+		t.Errorf("this class links to %d, want %d", this.Target, box.Id)
+	}
+}
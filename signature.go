@@ -0,0 +1,440 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// typeSignature is a JavaTypeSignature from the grammar of JVMS §4.7.9.1:
+// a base type, a class type, a type variable or an array of one of them.
+// Its String method renders it as it would be written in Java.
+type typeSignature interface {
+	String() string
+}
+
+// baseTypeSignature is a primitive type, or V for the void result of a
+// method.
+type baseTypeSignature byte
+
+func (t baseTypeSignature) String() string {
+	return primitiveTypeNames[string(rune(t))]
+}
+
+type typeVariableSignature string
+
+func (t typeVariableSignature) String() string {
+	return string(t)
+}
+
+type arrayTypeSignature struct {
+	element typeSignature
+}
+
+func (t arrayTypeSignature) String() string {
+	return t.element.String() + "[]"
+}
+
+// classTypeSignature is a class type such as java.util.Map<K, V>.Entry,
+// which is made up of the class and any it is nested in, outermost first.
+type classTypeSignature struct {
+	pkg     string
+	classes []simpleClassTypeSignature
+}
+
+type simpleClassTypeSignature struct {
+	name      string
+	arguments []typeArgument
+}
+
+func (t classTypeSignature) String() string {
+	names := make([]string, len(t.classes))
+	for i, c := range t.classes {
+		names[i] = c.name
+		if len(c.arguments) > 0 {
+			arguments := make([]string, len(c.arguments))
+			for j, a := range c.arguments {
+				arguments[j] = a.String()
+			}
+			names[i] += "<" + strings.Join(arguments, ", ") + ">"
+		}
+	}
+	name := strings.Join(names, ".")
+	if t.pkg != "" {
+		name = strings.Replace(t.pkg, "/", ".", -1) + "." + name
+	}
+	return name
+}
+
+// isObject reports whether t is plain java.lang.Object, the bound type
+// variables have when none is written.
+func (t classTypeSignature) isObject() bool {
+	return t.pkg == "java/lang" && len(t.classes) == 1 && t.classes[0].name == "Object" && len(t.classes[0].arguments) == 0
+}
+
+// typeArgument is an argument of a generic class type. wildcard is '+'
+// for "? extends", '-' for "? super", '*' for a lone "?" and 0 for an
+// exact type.
+type typeArgument struct {
+	wildcard byte
+	bound    typeSignature
+}
+
+func (a typeArgument) String() string {
+	switch a.wildcard {
+	case '*':
+		return "?"
+	case '+':
+		return "? extends " + a.bound.String()
+	case '-':
+		return "? super " + a.bound.String()
+	}
+	return a.bound.String()
+}
+
+// typeParameter declares a type variable. classBound is nil if the
+// variable only has interface bounds.
+type typeParameter struct {
+	name            string
+	classBound      typeSignature
+	interfaceBounds []typeSignature
+}
+
+func (t typeParameter) String() string {
+	var bounds []string
+	if t.classBound != nil {
+		if c, ok := t.classBound.(classTypeSignature); !ok || !c.isObject() || len(t.interfaceBounds) > 0 {
+			bounds = append(bounds, t.classBound.String())
+		}
+	}
+	for _, b := range t.interfaceBounds {
+		bounds = append(bounds, b.String())
+	}
+	if len(bounds) == 0 {
+		return t.name
+	}
+	return t.name + " extends " + strings.Join(bounds, " & ")
+}
+
+func typeParametersString(parameters []typeParameter) string {
+	if len(parameters) == 0 {
+		return ""
+	}
+	names := make([]string, len(parameters))
+	for i, p := range parameters {
+		names[i] = p.String()
+	}
+	return "<" + strings.Join(names, ", ") + ">"
+}
+
+func joinTypes(types []typeSignature) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// classSignature is the generic signature of a class: its type parameters
+// and what it extends and implements.
+type classSignature struct {
+	typeParameters []typeParameter
+	superclass     classTypeSignature
+	interfaces     []typeSignature
+}
+
+// String renders the class signature as the part of a class declaration
+// that follows its name, e.g. "<T> extends java.util.AbstractList<T>".
+func (s classSignature) String() string {
+	text := typeParametersString(s.typeParameters)
+	if text != "" {
+		text += " "
+	}
+	text += "extends " + s.superclass.String()
+	if len(s.interfaces) > 0 {
+		text += " implements " + joinTypes(s.interfaces)
+	}
+	return text
+}
+
+// methodSignature is the generic signature of a method. The result of a
+// void method is baseTypeSignature('V').
+type methodSignature struct {
+	typeParameters []typeParameter
+	parameters     []typeSignature
+	result         typeSignature
+	throws         []typeSignature
+}
+
+// declaration renders the signature as the declaration of a method called
+// name, e.g. "<T> void sort(java.util.List<T>)".
+func (s methodSignature) declaration(name string) string {
+	text := typeParametersString(s.typeParameters)
+	if text != "" {
+		text += " "
+	}
+	text += fmt.Sprintf("%s %s(%s)", s.result, name, joinTypes(s.parameters))
+	if len(s.throws) > 0 {
+		text += " throws " + joinTypes(s.throws)
+	}
+	return text
+}
+
+// signatureParser reads the signatures of JVMS §4.7.9.1. The first problem
+// met is kept in err, after which everything reads as nothing.
+type signatureParser struct {
+	s   string
+	pos int
+	err error
+}
+
+func parseClassSignature(s string) (classSignature, error) {
+	r := &signatureParser{s: s}
+	signature := classSignature{typeParameters: r.typeParameters()}
+	signature.superclass = r.classType()
+	for r.err == nil && r.pos < len(r.s) {
+		signature.interfaces = append(signature.interfaces, r.classType())
+	}
+	return signature, r.end()
+}
+
+func parseMethodSignature(s string) (methodSignature, error) {
+	r := &signatureParser{s: s}
+	signature := methodSignature{typeParameters: r.typeParameters()}
+	r.expect('(')
+	for r.err == nil && r.peek() != ')' {
+		signature.parameters = append(signature.parameters, r.javaType())
+	}
+	r.expect(')')
+	if r.peek() == 'V' {
+		r.pos++
+		signature.result = baseTypeSignature('V')
+	} else {
+		signature.result = r.javaType()
+	}
+	for r.err == nil && r.peek() == '^' {
+		r.pos++
+		if r.peek() == 'T' {
+			signature.throws = append(signature.throws, r.typeVariable())
+		} else {
+			signature.throws = append(signature.throws, r.classType())
+		}
+	}
+	return signature, r.end()
+}
+
+// parseFieldSignature reads the signature of a field, record component or
+// local variable, which is a reference type.
+func parseFieldSignature(s string) (typeSignature, error) {
+	r := &signatureParser{s: s}
+	t := r.referenceType()
+	return t, r.end()
+}
+
+func (r *signatureParser) failf(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), r.pos)
+	}
+}
+
+// end returns the first problem met, or reports anything left unread.
+func (r *signatureParser) end() error {
+	if r.err == nil && r.pos < len(r.s) {
+		r.failf("unexpected %q", r.s[r.pos])
+	}
+	return r.err
+}
+
+// peek returns the next character, or 0 if there are none left or a
+// problem has been met.
+func (r *signatureParser) peek() byte {
+	if r.err != nil || r.pos >= len(r.s) {
+		return 0
+	}
+	return r.s[r.pos]
+}
+
+func (r *signatureParser) expect(c byte) {
+	if r.peek() != c {
+		r.unexpected(fmt.Sprintf("%q", c))
+		return
+	}
+	r.pos++
+}
+
+// unexpected records that what was expected instead of the next
+// character.
+func (r *signatureParser) unexpected(what string) {
+	if r.pos >= len(r.s) {
+		r.failf("expected %s but the signature ended", what)
+	} else {
+		r.failf("expected %s but found %q", what, r.s[r.pos])
+	}
+}
+
+// identifier reads a name, which runs up to the next character that the
+// grammar gives a meaning to.
+func (r *signatureParser) identifier() string {
+	start := r.pos
+	for r.err == nil && r.pos < len(r.s) && !strings.ContainsRune(".;[/<>:", rune(r.s[r.pos])) {
+		r.pos++
+	}
+	if r.pos == start {
+		r.unexpected("an identifier")
+	}
+	return r.s[start:r.pos]
+}
+
+func (r *signatureParser) typeParameters() []typeParameter {
+	if r.peek() != '<' {
+		return nil
+	}
+	r.pos++
+	var parameters []typeParameter
+	for r.err == nil && r.peek() != '>' {
+		parameter := typeParameter{name: r.identifier()}
+		r.expect(':')
+		if c := r.peek(); c == 'L' || c == 'T' || c == '[' {
+			parameter.classBound = r.referenceType()
+		}
+		for r.peek() == ':' {
+			r.pos++
+			parameter.interfaceBounds = append(parameter.interfaceBounds, r.referenceType())
+		}
+		parameters = append(parameters, parameter)
+	}
+	r.expect('>')
+	if r.err == nil && len(parameters) == 0 {
+		r.failf("empty type parameters")
+	}
+	return parameters
+}
+
+// javaType reads a JavaTypeSignature.
+func (r *signatureParser) javaType() typeSignature {
+	c := r.peek()
+	if _, ok := primitiveTypeNames[string(c)]; ok && c != 'V' {
+		r.pos++
+		return baseTypeSignature(c)
+	}
+	return r.referenceType()
+}
+
+func (r *signatureParser) referenceType() typeSignature {
+	switch r.peek() {
+	case 'L':
+		return r.classType()
+	case 'T':
+		return r.typeVariable()
+	case '[':
+		r.pos++
+		return arrayTypeSignature{r.javaType()}
+	}
+	r.unexpected("a reference type")
+	return nil
+}
+
+func (r *signatureParser) typeVariable() typeSignature {
+	r.expect('T')
+	name := r.identifier()
+	r.expect(';')
+	return typeVariableSignature(name)
+}
+
+func (r *signatureParser) classType() classTypeSignature {
+	var t classTypeSignature
+	r.expect('L')
+	name := r.identifier()
+	// Leading identifiers followed by slashes make up the package.
+	for r.err == nil && r.peek() == '/' {
+		r.pos++
+		if t.pkg != "" {
+			t.pkg += "/"
+		}
+		t.pkg += name
+		name = r.identifier()
+	}
+	for r.err == nil {
+		t.classes = append(t.classes, simpleClassTypeSignature{name, r.typeArguments()})
+		if r.peek() != '.' {
+			break
+		}
+		r.pos++
+		name = r.identifier()
+	}
+	r.expect(';')
+	return t
+}
+
+func (r *signatureParser) typeArguments() []typeArgument {
+	if r.peek() != '<' {
+		return nil
+	}
+	r.pos++
+	var arguments []typeArgument
+	for r.err == nil && r.peek() != '>' {
+		switch c := r.peek(); c {
+		case '*':
+			r.pos++
+			arguments = append(arguments, typeArgument{wildcard: '*'})
+		case '+', '-':
+			r.pos++
+			arguments = append(arguments, typeArgument{c, r.referenceType()})
+		default:
+			arguments = append(arguments, typeArgument{0, r.referenceType()})
+		}
+	}
+	r.expect('>')
+	if r.err == nil && len(arguments) == 0 {
+		r.failf("empty type arguments")
+	}
+	return arguments
+}
+
+// parseSignature reads a Signature attribute, showing the signature as
+// Java source. Whether it is a class, method or field signature depends
+// on what the attribute belongs to.
+func (p *sectionParser) parseSignature(bytes []byte, start, end int) []Section {
+	if end-start != 2 {
+		return p.malformedInfo("Signature", start, end)
+	}
+	index := p.indexSection(bytes, start, "signature index", utf8Kind)
+	signature, ok := p.constantPoolUtf8(newByteParser(bytes, start).u2())
+	if !ok {
+		return []Section{index}
+	}
+	var text, kind string
+	var err error
+	switch p.memberKind {
+	case "":
+		kind = "class"
+		var s classSignature
+		if s, err = parseClassSignature(signature); err == nil {
+			text = s.String()
+		}
+	case "method":
+		kind = "method"
+		var s methodSignature
+		if s, err = parseMethodSignature(signature); err == nil {
+			text = s.declaration(p.memberName)
+		}
+	default:
+		kind = "field"
+		var t typeSignature
+		if t, err = parseFieldSignature(signature); err == nil {
+			text = t.String() + " " + p.memberName
+		}
+	}
+	if err != nil {
+		// The JVM itself ignores signatures, so a bad one only matters
+		// to reflection and compilers.
+		p.warnf(start, end, "invalid %s signature %q: %v", kind, signature, err)
+		return []Section{index}
+	}
+	return []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   end,
+		Name:       "signature: " + text,
+		Children:   []Section{index},
+	}}
+}
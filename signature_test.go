@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestParseClassSignature(t *testing.T) {
+	tests := []struct {
+		signature, want, err string
+	}{
+		{"Ljava/lang/Object;", "extends java.lang.Object", ""},
+		{"<T:Ljava/lang/Object;>Ljava/util/AbstractList<TT;>;Ljava/util/RandomAccess;",
+			"<T> extends java.util.AbstractList<T> implements java.util.RandomAccess", ""},
+		{"<K::Ljava/lang/Comparable<-TK;>;V:Ljava/lang/Number;:Ljava/io/Serializable;>Ljava/lang/Object;",
+			"<K extends java.lang.Comparable<? super K>, V extends java.lang.Number & java.io.Serializable> extends java.lang.Object", ""},
+		{"<T:Ljava/lang/Object;:Ljava/lang/Runnable;>Ljava/lang/Object;",
+			"<T extends java.lang.Object & java.lang.Runnable> extends java.lang.Object", ""},
+		{"<E:Ljava/lang/Enum<TE;>;>Ljava/lang/Object;Ljava/lang/Iterable<TE;>;",
+			"<E extends java.lang.Enum<E>> extends java.lang.Object implements java.lang.Iterable<E>", ""},
+		{"<>Ljava/lang/Object;", "", "empty type parameters at offset 2"},
+		{"<T>Ljava/lang/Object;", "", "expected ':' but found '>' at offset 2"},
+		{"<T:Ljava/lang/Object;", "", "expected an identifier but the signature ended at offset 21"},
+		{"TT;", "", "expected 'L' but found 'T' at offset 0"},
+		{"Ljava/lang/Object;X", "", "expected 'L' but found 'X' at offset 18"},
+	}
+	for _, test := range tests {
+		s, err := parseClassSignature(test.signature)
+		if checkSignatureError(t, test.signature, err, test.err) && s.String() != test.want {
+			t.Errorf("%q: parsed as %q, want %q", test.signature, s, test.want)
+		}
+	}
+}
+
+func TestParseMethodSignature(t *testing.T) {
+	tests := []struct {
+		signature, want, err string
+	}{
+		{"()V", "void m()", ""},
+		{"<T:Ljava/lang/Object;>(Ljava/util/List<TT;>;)V", "<T> void m(java.util.List<T>)", ""},
+		{"(I[J[[Ljava/lang/String;)[TT;", "T[] m(int, long[], java.lang.String[][])", ""},
+		{"(Ljava/util/List<*>;Ljava/util/List<+Ljava/lang/Number;>;Ljava/util/List<-Ljava/lang/Integer;>;)V",
+			"void m(java.util.List<?>, java.util.List<? extends java.lang.Number>, java.util.List<? super java.lang.Integer>)", ""},
+		{"()Ljava/util/Map<TK;TV;>.Entry<TK;TV;>;", "java.util.Map<K, V>.Entry<K, V> m()", ""},
+		{"()Lp/Outer.Middle.Inner;", "p.Outer.Middle.Inner m()", ""},
+		{"<X:Ljava/lang/Exception;>()V^TX;^Ljava/io/IOException;", "<X extends java.lang.Exception> void m() throws X, java.io.IOException", ""},
+		{"(V)V", "", "expected a reference type but found 'V' at offset 1"},
+		{"()", "", "expected a reference type but the signature ended at offset 2"},
+		{"(I", "", "expected a reference type but the signature ended at offset 2"},
+		{"I)V", "", "expected '(' but found 'I' at offset 0"},
+		{"()V^I", "", "expected 'L' but found 'I' at offset 4"},
+		{"(Ljava/util/List<>;)V", "", "empty type arguments at offset 18"},
+		{"()Ljava/lang/String", "", "expected ';' but the signature ended at offset 19"},
+		{"()VV", "", "unexpected 'V' at offset 3"},
+	}
+	for _, test := range tests {
+		s, err := parseMethodSignature(test.signature)
+		if checkSignatureError(t, test.signature, err, test.err) && s.declaration("m") != test.want {
+			t.Errorf("%q: parsed as %q, want %q", test.signature, s.declaration("m"), test.want)
+		}
+	}
+}
+
+func TestParseFieldSignature(t *testing.T) {
+	tests := []struct {
+		signature, want, err string
+	}{
+		{"TT;", "T", ""},
+		{"[TT;", "T[]", ""},
+		{"Ljava/util/Map<Ljava/lang/String;[I>;", "java.util.Map<java.lang.String, int[]>", ""},
+		{"Ljava/util/List<Ljava/util/List<*>;>;", "java.util.List<java.util.List<?>>", ""},
+		{"LOuter<TT;>.Inner;", "Outer<T>.Inner", ""},
+		{"I", "", "expected a reference type but found 'I' at offset 0"},
+		{"", "", "expected a reference type but the signature ended at offset 0"},
+		{"Ljava/util/List<", "", "expected a reference type but the signature ended at offset 16"},
+		{"Ljava//List;", "", "expected an identifier but found '/' at offset 6"},
+		{"TT", "", "expected ';' but the signature ended at offset 2"},
+		{"TT;;", "", "unexpected ';' at offset 3"},
+		{"Ljava/util/List<Z>;", "", "expected a reference type but found 'Z' at offset 16"},
+	}
+	for _, test := range tests {
+		s, err := parseFieldSignature(test.signature)
+		if checkSignatureError(t, test.signature, err, test.err) && s.String() != test.want {
+			t.Errorf("%q: parsed as %q, want %q", test.signature, s, test.want)
+		}
+	}
+}
+
+// checkSignatureError fails unless a signature failed to parse with
+// wantErr, or parsed if wantErr is empty, and reports whether it parsed.
+func checkSignatureError(t *testing.T, signature string, err error, wantErr string) bool {
+	t.Helper()
+	if err == nil && wantErr != "" {
+		t.Errorf("%q: parsed, want error %q", signature, wantErr)
+	} else if err != nil && err.Error() != wantErr {
+		t.Errorf("%q: error %q, want %q", signature, err, wantErr)
+	}
+	return err == nil && wantErr == ""
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// lineNumber is an entry of a LineNumberTable: the instructions from pc
+// onwards were compiled from line.
+type lineNumber struct {
+	pc   int
+	line int
+}
+
+func (p *sectionParser) parseLineNumberTable(bytes []byte, start, end int) []Section {
+	parser := newByteParser(bytes, start)
+	count := int(parser.u2())
+	if end-start < 2 || end-start != 2+4*count {
+		return p.malformedInfo("LineNumberTable", start, end)
+	}
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("line number table length: %d", count),
+	}}
+	for i := 0; i < count; i++ {
+		index := start + 2 + 4*i
+		pc := int(parser.u2())
+		line := int(parser.u2())
+		p.lineNumbers = append(p.lineNumbers, lineNumber{pc: pc, line: line})
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   index + 4,
+			Name:       fmt.Sprintf("line %d starts at %d", line, pc),
+			Target:     p.instructionSections[pc],
+			Line:       line,
+			Children: []Section{
+				p.pcSection(index, "start pc", pc),
+				{
+					Id:         p.nextId(),
+					StartIndex: index + 2,
+					EndIndex:   index + 4,
+					Name:       fmt.Sprintf("line number: %d", line),
+				},
+			},
+		})
+	}
+	return sections
+}
+
+// parseLocalVariableTable reads a LocalVariableTable or a
+// LocalVariableTypeTable. The two only differ in whether each variable has
+// a descriptor or a generic signature, which typeLabel names.
+func (p *sectionParser) parseLocalVariableTable(name string, bytes []byte, start, end int, typeLabel string) []Section {
+	parser := newByteParser(bytes, start)
+	count := int(parser.u2())
+	if end-start < 2 || end-start != 2+10*count {
+		return p.malformedInfo(name, start, end)
+	}
+	countLabel := "local variable table length"
+	if name == "LocalVariableTypeTable" {
+		countLabel = "local variable type table length"
+	}
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("%s: %d", countLabel, count),
+	}}
+	for i := 0; i < count; i++ {
+		index := start + 2 + 10*i
+		startPc := int(parser.u2())
+		length := int(parser.u2())
+		variable, _ := p.constantPoolUtf8(parser.u2())
+		signature, _ := p.constantPoolUtf8(parser.u2())
+		slot := parser.u2()
+		if typeLabel == "signature" {
+			if t, err := parseFieldSignature(signature); err == nil {
+				signature = t.String()
+			}
+		} else if t, err := parseFieldDescriptor(signature); err == nil {
+			signature = t.String()
+		}
+		sections = append(sections, Section{
+			Id:         p.nextId(),
+			StartIndex: index,
+			EndIndex:   index + 10,
+			Name:       fmt.Sprintf("local %d: %s %s from %d to %d", slot, variable, signature, startPc, startPc+length),
+			Children: []Section{
+				p.pcSection(index, "start pc", startPc),
+				{
+					Id:         p.nextId(),
+					StartIndex: index + 2,
+					EndIndex:   index + 4,
+					Name:       fmt.Sprintf("length: %d", length),
+				},
+				p.indexSection(bytes, index+4, "name index", utf8Kind),
+				p.indexSection(bytes, index+6, typeLabel+" index", utf8Kind),
+				{
+					Id:         p.nextId(),
+					StartIndex: index + 8,
+					EndIndex:   index + 10,
+					Name:       fmt.Sprintf("index: %d", slot),
+				},
+			},
+		})
+	}
+	return sections
+}
+
+// pcSection is a section for a u2 bytecode offset, linking to the
+// instruction there.
+func (p *sectionParser) pcSection(index int, label string, pc int) Section {
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 2,
+		Name:       fmt.Sprintf("%s: %d", label, pc),
+		Target:     p.instructionSections[pc],
+	}
+}
+
+// setLines marks each instruction with the source line it was compiled
+// from, according to the LineNumberTables read for its Code attribute.
+// codeStart is where the code array starts in the class file.
+func setLines(instructions []Section, codeStart int, lines []lineNumber) {
+	if len(lines) == 0 {
+		return
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].pc < lines[j].pc
+	})
+	for i := range instructions {
+		pc := instructions[i].StartIndex - codeStart
+		// The entry that applies is the last one starting at or before pc.
+		n := sort.Search(len(lines), func(j int) bool {
+			return lines[j].pc > pc
+		})
+		if n > 0 {
+			instructions[i].Line = lines[n-1].line
+		}
+	}
+}
+
+// sourceFileName returns the name recorded in a class's SourceFile
+// attribute, or "" if it doesn't have one.
+func sourceFileName(classFile []byte) string {
+	c, err := ParseClass(bytes.NewReader(classFile))
+	if err != nil {
+		return ""
+	}
+	for _, a := range c.attributes {
+		if a.name != "SourceFile" || len(a.info) != 2 {
+			continue
+		}
+		name, err := c.utf8At(newByteParser(a.info, 0).u2())
+		if err != nil {
+			return ""
+		}
+		return name
+	}
+	return ""
+}
+
+// sourcePath returns where the source of the class at classPath would be
+// found alongside it, such as in a source jar or a directory where javac
+// was run without -d. Source file names that would lead anywhere other
+// than the class's own directory are ignored.
+func sourcePath(classPath string, classFile []byte) (string, bool) {
+	name := sourceFileName(classFile)
+	if !strings.HasSuffix(name, ".java") || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	return path.Join(path.Dir(classPath), name), true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineAndLocalVariableTables(t *testing.T) {
+	sections := parsedFixture(t, "Hello11.class")
+	main := findSection(t, sections, "method void main")
+	checkOutline(t, findSection(t, []Section{main}, "attribute LineNumberTable"), 2, `
+418-434 attribute LineNumberTable
+  418-420 name index: #15 -> LineNumberTable
+  420-424 length: 10
+  424-426 line number table length: 2
+  426-430 line 3 starts at 0
+    426-428 start pc: 0
+    428-430 line number: 3
+  430-434 line 4 starts at 8
+    430-432 start pc: 8
+    432-434 line number: 4
+`)
+	checkOutline(t, findSection(t, []Section{main}, "attribute LocalVariableTable"), 2, `
+434-452 attribute LocalVariableTable
+  434-436 name index: #18 -> LocalVariableTable
+  436-440 length: 12
+  440-442 local variable table length: 1
+  442-452 local 0: args java.lang.String[] from 0 to 9
+    442-444 start pc: 0
+    444-446 length: 9
+    446-448 name index: #16 -> args
+    448-450 descriptor index: #17 -> [Ljava/lang/String;
+    450-452 index: 0
+`)
+
+	// Each instruction knows the line it was compiled from.
+	var lines []int
+	for _, instruction := range findSection(t, []Section{main}, "code: ").Children {
+		lines = append(lines, instruction.Line)
+	}
+	if want := []int{3, 3, 3, 4}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("instruction lines %v, want %v", lines, want)
+	}
+
+	b := newClassBuilder()
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "L", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0008, "m", "(Ljava/util/List;)V",
+				b.code(0, 1, op("return"), nil,
+					b.attribute("LocalVariableTypeTable", u2(1),
+						u2(0), u2(1), u2(b.utf8("names")), u2(b.utf8("Ljava/util/List<Ljava/lang/String;>;")), u2(0)))),
+		},
+	})
+	sections, diagnostics := parseClass(class)
+	if len(diagnostics) != 0 {
+		t.Errorf("built class: %v", diagnostics)
+	}
+	checkCoverage(t, "built class", sections, len(class))
+	checkOutline(t, findSection(t, sections, "attribute LocalVariableTypeTable"), 2, `
+183-201 attribute LocalVariableTypeTable
+  183-185 name index: #3 -> LocalVariableTypeTable
+  185-189 length: 12
+  189-191 local variable type table length: 1
+  191-201 local 0: names java.util.List<java.lang.String> from 0 to 1
+    191-193 start pc: 0
+    193-195 length: 1
+    195-197 name index: #1 -> names
+    197-199 signature index: #2 -> Ljava/util/List<Ljava/lang/String;>;
+    199-201 index: 0
+`)
+}
+
+func TestSourcePath(t *testing.T) {
+	hello := readFixture(t, "Hello11.class")
+	if path, ok := sourcePath("com/example/Hello11.class", hello); path != "com/example/Hello11.java" || !ok {
+		t.Errorf("source of Hello11 is %q, %v", path, ok)
+	}
+	for _, name := range []string{"../Evil.java", "Hello.kt", ""} {
+		b := newClassBuilder()
+		var attributes [][]byte
+		if name != "" {
+			attributes = append(attributes, b.attribute("SourceFile", u2(b.utf8(name))))
+		}
+		class := b.build(classSpec{major: 52, this: "C", super: "java/lang/Object", attributes: attributes})
+		if path, ok := sourcePath("C.class", class); ok {
+			t.Errorf("source file %q gives %q", name, path)
+		}
+	}
+}
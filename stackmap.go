@@ -0,0 +1,229 @@
+package main
+
+import "fmt"
+
+// verificationTypeNames are the names JVMS §4.7.4 gives each
+// verification_type_info tag.
+var verificationTypeNames = map[uint8]string{
+	0: "Top",
+	1: "Integer",
+	2: "Float",
+	3: "Double",
+	4: "Long",
+	5: "Null",
+	6: "UninitializedThis",
+	7: "Object",
+	8: "Uninitialized",
+}
+
+// stackMapFrameType names the kind of stack map frame a frame_type byte
+// introduces.
+func stackMapFrameType(tag uint8) string {
+	switch {
+	case tag < 64:
+		return "same"
+	case tag < 128:
+		return "same_locals_1_stack_item"
+	case tag < 247:
+		return "reserved"
+	case tag == 247:
+		return "same_locals_1_stack_item_extended"
+	case tag < 251:
+		return "chop"
+	case tag == 251:
+		return "same_frame_extended"
+	case tag < 255:
+		return "append"
+	}
+	return "full_frame"
+}
+
+// parseStackMapTable shows each frame of a StackMapTable along with the
+// bytecode offset it applies to, which is only stored as a delta from the
+// previous frame.
+func (p *sectionParser) parseStackMapTable(bytes []byte, start, end int) []Section {
+	if end-start < 2 {
+		return p.malformedInfo("StackMapTable", start, end)
+	}
+	count := int(newByteParser(bytes, start).u2())
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("number of entries: %d", count),
+	}}
+	next := start + 2
+	pc := -1
+	for i := 0; i < count; i++ {
+		if next >= end {
+			p.errorf(start, start+2, "number of entries is %d but only %d frames fit", count, i)
+			break
+		}
+		frame, ok := p.parseStackMapFrame(bytes[:end], next, i, &pc)
+		if !ok {
+			return append(sections, p.opaqueInfo(next, end)...)
+		}
+		sections = append(sections, frame)
+		next = frame.EndIndex
+	}
+	return append(sections, p.leftoverInfo("StackMapTable", next, end)...)
+}
+
+// parseStackMapFrame reads the i'th frame, advancing pc to the offset it
+// applies to. It reports false if the frame can't be read, in which case
+// the rest of the table can't be located either.
+func (p *sectionParser) parseStackMapFrame(bytes []byte, index, i int, pc *int) (Section, bool) {
+	tag := bytes[index]
+	kind := stackMapFrameType(tag)
+	if kind == "reserved" {
+		p.errorf(index, index+1, "frame %d has reserved frame type %d", i, tag)
+		return Section{}, false
+	}
+	children := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 1,
+		Name:       fmt.Sprintf("frame type: %d (%s)", tag, kind),
+	}}
+	next := index + 1
+
+	var delta int
+	switch {
+	case tag < 64:
+		delta = int(tag)
+	case tag < 128:
+		delta = int(tag) - 64
+	default:
+		if next+2 > len(bytes) {
+			p.frameTruncated(bytes, index, i)
+			return Section{}, false
+		}
+		delta = int(newByteParser(bytes, next).u2())
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 2,
+			Name:       fmt.Sprintf("offset delta: %d", delta),
+		})
+		next += 2
+	}
+	*pc += delta + 1
+
+	// types reads n verification types, or a u2 count and then that many
+	// types if n is negative.
+	types := func(label string, n int) bool {
+		if n < 0 {
+			if next+2 > len(bytes) {
+				p.frameTruncated(bytes, index, i)
+				return false
+			}
+			n = int(newByteParser(bytes, next).u2())
+			children = append(children, Section{
+				Id:         p.nextId(),
+				StartIndex: next,
+				EndIndex:   next + 2,
+				Name:       fmt.Sprintf("number of %ss: %d", label, n),
+			})
+			next += 2
+		}
+		for j := 0; j < n; j++ {
+			if next >= len(bytes) || next+verificationTypeSize(bytes[next]) > len(bytes) {
+				p.frameTruncated(bytes, index, i)
+				return false
+			}
+			t, ok := p.parseVerificationType(bytes, next, label)
+			if !ok {
+				return false
+			}
+			children = append(children, t)
+			next = t.EndIndex
+		}
+		return true
+	}
+	ok := true
+	switch kind {
+	case "same_locals_1_stack_item", "same_locals_1_stack_item_extended":
+		ok = types("stack item", 1)
+	case "append":
+		ok = types("local", int(tag)-251)
+	case "chop":
+		children[0].Name = fmt.Sprintf("frame type: %d (chop %d locals)", tag, 251-int(tag))
+	case "full_frame":
+		ok = types("local", -1) && types("stack item", -1)
+	}
+	if !ok {
+		return Section{}, false
+	}
+
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   next,
+		Name:       fmt.Sprintf("frame at %d: %s", *pc, kind),
+		Children:   children,
+		Target:     p.instructionSections[*pc],
+	}, true
+}
+
+// frameTruncated records that the i'th frame, starting at index, runs past
+// the end of the attribute.
+func (p *sectionParser) frameTruncated(bytes []byte, index, i int) {
+	p.errorf(index, len(bytes), "StackMapTable attribute ends in the middle of frame %d", i)
+}
+
+// verificationTypeSize is the length of the verification_type_info with
+// the given tag: Object and Uninitialized are followed by a u2.
+func verificationTypeSize(tag uint8) int {
+	if tag == 7 || tag == 8 {
+		return 3
+	}
+	return 1
+}
+
+// parseVerificationType reads a single verification_type_info. Object
+// types link to their class in the constant pool and uninitialized types
+// to the new instruction that created them. The caller makes sure the
+// whole type is within bytes.
+func (p *sectionParser) parseVerificationType(bytes []byte, index int, label string) (Section, bool) {
+	tag := bytes[index]
+	name, ok := verificationTypeNames[tag]
+	if !ok {
+		p.errorf(index, index+1, "unknown verification type %d", tag)
+		return Section{}, false
+	}
+	section := Section{
+		StartIndex: index,
+		EndIndex:   index + 1,
+		Name:       fmt.Sprintf("%s: %s", label, name),
+	}
+	if verificationTypeSize(tag) == 3 {
+		section.EndIndex = index + 3
+		value := newByteParser(bytes, index+1).u2()
+		var operand Section
+		if tag == 7 {
+			operand = p.indexSection(bytes, index+1, "class index", classKind)
+			section.Name = fmt.Sprintf("%s: Object %s", label, p.resolve(value, classKind))
+		} else {
+			operand = Section{
+				Id:         p.nextId(),
+				StartIndex: index + 1,
+				EndIndex:   index + 3,
+				Name:       fmt.Sprintf("offset: %d", value),
+				Target:     p.instructionSections[int(value)],
+			}
+			section.Name = fmt.Sprintf("%s: Uninitialized(%d)", label, value)
+		}
+		section.Children = []Section{
+			{
+				Id:         p.nextId(),
+				StartIndex: index,
+				EndIndex:   index + 1,
+				Name:       fmt.Sprintf("tag: %d (%s)", tag, name),
+			},
+			operand,
+		}
+		section.Target = operand.Target
+	}
+	section.Id = p.nextId()
+	return section, true
+}
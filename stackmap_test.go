@@ -0,0 +1,108 @@
+package main
+
+import "testing"
+
+func TestParseStackMapTable(t *testing.T) {
+	sections := parsedFixture(t, "Branches6.class")
+	checkOutline(t, findSection(t, sections, "method int sum", "attribute StackMapTable"), 2, `
+458-474 attribute StackMapTable
+  458-460 name index: #8 -> StackMapTable
+  460-464 length: 10
+  464-466 number of entries: 2
+  466-471 frame at 4: append
+    466-467 frame type: 253 (append)
+    467-469 offset delta: 4
+    469-470 local: Integer
+    470-471 local: Integer
+  471-474 frame at 22: chop
+    471-472 frame type: 250 (chop 1 locals)
+    472-474 offset delta: 17
+`)
+	checkOutline(t, findSection(t, sections, "frame at 9: full_frame"), 1, `
+731-743 frame at 9: full_frame
+  731-732 frame type: 255 (full_frame)
+  732-734 offset delta: 9
+  734-736 number of locals: 5
+  736-737 local: Integer
+  737-738 local: Integer
+  738-739 local: Integer
+  739-740 local: Integer
+  740-741 local: Integer
+  741-743 number of stack items: 0
+`)
+
+	const (
+		top = iota
+		integer
+		float
+		double
+		long
+		null
+		uninitializedThis
+		object
+		uninitialized
+	)
+	b := newClassBuilder()
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "S", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0008, "m", "()V",
+				b.code(2, 8, join(
+					op("new"), u2(b.class("java/lang/Object")),
+					make([]byte, 26),
+					op("return"),
+				), nil,
+					b.attribute("StackMapTable", u2(5),
+						u1(247), u2(3), u1(uninitialized), u2(0),
+						u1(251), u2(10),
+						u1(253), u2(0), u1(object), u2(b.class("java/lang/String")), u1(long),
+						u1(250), u2(1),
+						u1(255), u2(2), u2(6), u1(top), u1(float), u1(double), u1(null), u1(uninitializedThis), u1(integer), u2(0)))),
+		},
+	})
+	sections, diagnostics := parseClass(class)
+	if len(diagnostics) != 0 {
+		t.Errorf("built class: %v", diagnostics)
+	}
+	checkCoverage(t, "built class", sections, len(class))
+	checkOutline(t, findSection(t, sections, "attribute StackMapTable"), 2, `
+162-202 attribute StackMapTable
+  162-164 name index: #5 -> StackMapTable
+  164-168 length: 34
+  168-170 number of entries: 5
+  170-176 frame at 3: same_locals_1_stack_item_extended
+    170-171 frame type: 247 (same_locals_1_stack_item_extended)
+    171-173 offset delta: 3
+    173-176 stack item: Uninitialized(0)
+  176-179 frame at 14: same_frame_extended
+    176-177 frame type: 251 (same_frame_extended)
+    177-179 offset delta: 10
+  179-186 frame at 15: append
+    179-180 frame type: 253 (append)
+    180-182 offset delta: 0
+    182-185 local: Object #4 -> java/lang/String
+    185-186 local: Long
+  186-189 frame at 17: chop
+    186-187 frame type: 250 (chop 1 locals)
+    187-189 offset delta: 1
+  189-202 frame at 20: full_frame
+    189-190 frame type: 255 (full_frame)
+    190-192 offset delta: 2
+    192-194 number of locals: 6
+    194-195 local: Top
+    195-196 local: Float
+    196-197 local: Double
+    197-198 local: Null
+    198-199 local: UninitializedThis
+    199-200 local: Integer
+    200-202 number of stack items: 0
+`)
+
+	// Each frame links to the instruction it applies to.
+	frame := findSection(t, sections, "frame at 14")
+	if instruction := findSection(t, sections, "14: nop"); frame.Target != instruction.Id {
+		t.Errorf("frame at 14 links to %d, want %d", frame.Target, instruction.Id)
+	}
+}
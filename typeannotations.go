@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// typeAnnotationTargets are the descriptions JVMS §4.7.20 gives each
+// target_type.
+var typeAnnotationTargets = map[byte]string{
+	0x00: "type parameter declaration of generic class or interface",
+	0x01: "type parameter declaration of generic method or constructor",
+	0x10: "type in extends or implements clause",
+	0x11: "type in bound of type parameter declaration of generic class or interface",
+	0x12: "type in bound of type parameter declaration of generic method or constructor",
+	0x13: "type in field or record component declaration",
+	0x14: "return type of method, or type of newly constructed object",
+	0x15: "receiver type of method or constructor",
+	0x16: "type in formal parameter declaration of method, constructor, or lambda expression",
+	0x17: "type in throws clause of method or constructor",
+	0x40: "type in local variable declaration",
+	0x41: "type in resource variable declaration",
+	0x42: "type in exception parameter declaration",
+	0x43: "type in instanceof expression",
+	0x44: "type in new expression",
+	0x45: "type in method reference expression using ::new",
+	0x46: "type in method reference expression using ::Identifier",
+	0x47: "type in cast expression",
+	0x48: "type argument for generic constructor in new expression or explicit constructor invocation statement",
+	0x49: "type argument for generic method in method invocation expression",
+	0x4A: "type argument for generic constructor in method reference expression using ::new",
+	0x4B: "type argument for generic method in method reference expression using ::Identifier",
+}
+
+// typePathSteps describe each type_path_kind, as the part of a type that
+// a step leads to.
+var typePathSteps = map[byte]string{
+	0: "the element type",
+	1: "the nested type",
+	2: "the bound of the wildcard",
+	3: "type argument",
+}
+
+// parseTypeAnnotations reads the table of a RuntimeVisibleTypeAnnotations
+// or RuntimeInvisibleTypeAnnotations attribute.
+func (p *sectionParser) parseTypeAnnotations(name string, bytes []byte, start, end int) []Section {
+	if end-start < 2 {
+		return p.malformedInfo(name, start, end)
+	}
+	bytes = bytes[:end]
+	count := int(newByteParser(bytes, start).u2())
+	sections := []Section{{
+		Id:         p.nextId(),
+		StartIndex: start,
+		EndIndex:   start + 2,
+		Name:       fmt.Sprintf("number of annotations: %d", count),
+	}}
+	next := start + 2
+	for i := 0; i < count; i++ {
+		annotation, ok := p.parseTypeAnnotation(name, bytes, next)
+		if !ok {
+			return append(sections, p.opaqueInfo(next, end)...)
+		}
+		sections = append(sections, annotation)
+		next = annotation.EndIndex
+	}
+	return append(sections, p.leftoverInfo(name, next, end)...)
+}
+
+// parseTypeAnnotation reads a type_annotation: where in a type it applies,
+// followed by the annotation itself. Its section says in plain words what
+// is annotated, e.g. "@NonNull on type argument 0 of the return type".
+func (p *sectionParser) parseTypeAnnotation(name string, bytes []byte, index int) (Section, bool) {
+	if index >= len(bytes) {
+		p.annotationTruncated(name, bytes, index, "a type annotation")
+		return Section{}, false
+	}
+	targetType := bytes[index]
+	target, known := typeAnnotationTargets[targetType]
+	if !known {
+		p.errorf(index, index+1, "%s attribute has a type annotation with unknown target type 0x%02x", name, targetType)
+		return Section{}, false
+	}
+	children := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 1,
+		Name:       fmt.Sprintf("target type: 0x%02x (%s)", targetType, target),
+	}}
+	next := index + 1
+	// need reports whether n more bytes are available, recording an error
+	// if not.
+	need := func(n int) bool {
+		if next+n > len(bytes) {
+			p.annotationTruncated(name, bytes, index, "a type annotation")
+			return false
+		}
+		return true
+	}
+	u1 := func(label string) int {
+		value := int(bytes[next])
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 1,
+			Name:       fmt.Sprintf("%s: %d", label, value),
+		})
+		next++
+		return value
+	}
+	u2 := func(label string) int {
+		value := int(newByteParser(bytes, next).u2())
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: next,
+			EndIndex:   next + 2,
+			Name:       fmt.Sprintf("%s: %d", label, value),
+		})
+		next += 2
+		return value
+	}
+	pc := func() int {
+		value := int(newByteParser(bytes, next).u2())
+		children = append(children, p.pcSection(next, "offset", value))
+		next += 2
+		return value
+	}
+
+	declaration := "class"
+	if targetType == 0x01 || targetType == 0x12 {
+		declaration = "method"
+	}
+	var location string
+	switch targetType {
+	case 0x00, 0x01:
+		if !need(1) {
+			return Section{}, false
+		}
+		location = fmt.Sprintf("type parameter %d of the %s", u1("type parameter index"), declaration)
+	case 0x10:
+		if !need(2) {
+			return Section{}, false
+		}
+		if supertype := u2("supertype index"); supertype == 65535 {
+			location = "the superclass"
+		} else {
+			location = fmt.Sprintf("superinterface %d", supertype)
+		}
+	case 0x11, 0x12:
+		if !need(2) {
+			return Section{}, false
+		}
+		parameter := u1("type parameter index")
+		location = fmt.Sprintf("bound %d of type parameter %d of the %s", u1("bound index"), parameter, declaration)
+	case 0x13:
+		location = "the field type"
+	case 0x14:
+		location = "the return type"
+	case 0x15:
+		location = "the receiver type"
+	case 0x16:
+		if !need(1) {
+			return Section{}, false
+		}
+		location = fmt.Sprintf("the type of parameter %d", u1("formal parameter index"))
+	case 0x17:
+		if !need(2) {
+			return Section{}, false
+		}
+		location = fmt.Sprintf("exception %d of the throws clause", u2("throws type index"))
+	case 0x40, 0x41:
+		if !need(2) {
+			return Section{}, false
+		}
+		count := u2("table length")
+		if !need(6 * count) {
+			return Section{}, false
+		}
+		var ranges []string
+		for i := 0; i < count; i++ {
+			parser := newByteParser(bytes, next)
+			startPc := int(parser.u2())
+			length := int(parser.u2())
+			slot := int(parser.u2())
+			r := fmt.Sprintf("in slot %d from %d to %d", slot, startPc, startPc+length)
+			ranges = append(ranges, r)
+			children = append(children, Section{
+				Id:         p.nextId(),
+				StartIndex: next,
+				EndIndex:   next + 6,
+				Name:       "local " + r,
+				Children: []Section{
+					p.pcSection(next, "start pc", startPc),
+					{
+						Id:         p.nextId(),
+						StartIndex: next + 2,
+						EndIndex:   next + 4,
+						Name:       fmt.Sprintf("length: %d", length),
+					},
+					{
+						Id:         p.nextId(),
+						StartIndex: next + 4,
+						EndIndex:   next + 6,
+						Name:       fmt.Sprintf("index: %d", slot),
+					},
+				},
+			})
+			next += 6
+		}
+		variable := "local"
+		if targetType == 0x41 {
+			variable = "resource"
+		}
+		location = fmt.Sprintf("the type of the %s variable %s", variable, strings.Join(ranges, ", "))
+	case 0x42:
+		if !need(2) {
+			return Section{}, false
+		}
+		location = fmt.Sprintf("the type caught by exception handler %d", u2("exception table index"))
+	case 0x43, 0x44, 0x45, 0x46:
+		if !need(2) {
+			return Section{}, false
+		}
+		expression := map[byte]string{
+			0x43: "instanceof",
+			0x44: "new",
+			0x45: "::new method reference",
+			0x46: "method reference",
+		}[targetType]
+		location = fmt.Sprintf("the type in the %s at %d", expression, pc())
+	default:
+		if !need(3) {
+			return Section{}, false
+		}
+		offset := pc()
+		argument := u1("type argument index")
+		if targetType == 0x47 {
+			location = fmt.Sprintf("type %d of the cast at %d", argument, offset)
+			break
+		}
+		expression := map[byte]string{
+			0x48: "the generic constructor call",
+			0x49: "the generic method call",
+			0x4A: "the generic constructor in the method reference",
+			0x4B: "the generic method in the method reference",
+		}[targetType]
+		location = fmt.Sprintf("type argument %d of %s at %d", argument, expression, offset)
+	}
+
+	path, location, ok := p.parseTypePath(name, bytes, next, location)
+	if !ok {
+		return Section{}, false
+	}
+	children = append(children, path)
+	annotation, text, ok := p.parseAnnotation(name, bytes, path.EndIndex, 0)
+	if !ok {
+		return Section{}, false
+	}
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   annotation.EndIndex,
+		Name:       fmt.Sprintf("%s on %s", text, location),
+		Children:   append(children, annotation.Children...),
+	}, true
+}
+
+// parseTypePath reads a type_path, which leads from the type named by
+// location to the part of it that is annotated, and returns the location
+// of that part.
+func (p *sectionParser) parseTypePath(name string, bytes []byte, index int, location string) (Section, string, bool) {
+	if index+1 > len(bytes) || index+1+2*int(bytes[index]) > len(bytes) {
+		p.annotationTruncated(name, bytes, index, "a type path")
+		return Section{}, "", false
+	}
+	length := int(bytes[index])
+	children := []Section{{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 1,
+		Name:       fmt.Sprintf("path length: %d", length),
+	}}
+	steps := make([]string, length)
+	for i := 0; i < length; i++ {
+		at := index + 1 + 2*i
+		kind, argument := bytes[at], bytes[at+1]
+		step, known := typePathSteps[kind]
+		if !known {
+			p.errorf(at, at+1, "%s attribute has a type path with unknown kind %d", name, kind)
+			return Section{}, "", false
+		}
+		if kind == 3 {
+			step = fmt.Sprintf("%s %d", step, argument)
+		}
+		steps[i] = step
+		children = append(children, Section{
+			Id:         p.nextId(),
+			StartIndex: at,
+			EndIndex:   at + 2,
+			Name:       fmt.Sprintf("step: %s", step),
+			Children: []Section{
+				{
+					Id:         p.nextId(),
+					StartIndex: at,
+					EndIndex:   at + 1,
+					Name:       fmt.Sprintf("type path kind: %d", kind),
+				},
+				{
+					Id:         p.nextId(),
+					StartIndex: at + 1,
+					EndIndex:   at + 2,
+					Name:       fmt.Sprintf("type argument index: %d", argument),
+				},
+			},
+		})
+	}
+	// Each step goes further into the type, so the last is the outermost
+	// part of the description.
+	for _, step := range steps {
+		location = step + " of " + location
+	}
+	label := "type path: none"
+	if length == 1 {
+		label = "type path: 1 step"
+	} else if length > 1 {
+		label = fmt.Sprintf("type path: %d steps", length)
+	}
+	return Section{
+		Id:         p.nextId(),
+		StartIndex: index,
+		EndIndex:   index + 1 + 2*length,
+		Name:       label,
+		Children:   children,
+	}, location, true
+}
@@ -0,0 +1,203 @@
+package main
+
+import "testing"
+
+func TestParseTypeAnnotations(t *testing.T) {
+	sections := parsedFixture(t, "Annotated.class")
+	checkOutline(t, findSection(t, sections, "class has 3 attributes", "attribute RuntimeVisibleTypeAnnotations"), 4, `
+959-987 attribute RuntimeVisibleTypeAnnotations
+  959-961 name index: #20 -> RuntimeVisibleTypeAnnotations
+  961-965 length: 22
+  965-967 number of annotations: 2
+  967-974 @Tag on type parameter 0 of the class
+    967-968 target type: 0x00 (type parameter declaration of generic class or interface)
+    968-969 type parameter index: 0
+    969-970 type path: none
+      969-970 path length: 0
+    970-972 type index: #15 -> LTag;
+    972-974 number of element value pairs: 0
+  974-987 @Tag(value="super") on the superclass
+    974-975 target type: 0x10 (type in extends or implements clause)
+    975-977 supertype index: 65535
+    977-978 type path: none
+      977-978 path length: 0
+    978-980 type index: #15 -> LTag;
+    980-982 number of element value pairs: 1
+    982-987 value="super"
+      982-984 element name index: #16 -> value
+      984-987 String "super"
+        984-985 tag: 's' (String)
+        985-987 const value index: #45 -> super
+`)
+	// The method's own attribute comes last, after the one in its Code.
+	m := findSection(t, sections, "method java.lang.String m")
+	checkOutline(t, m.Children[len(m.Children)-1], 4, `
+886-932 attribute RuntimeVisibleTypeAnnotations
+  886-888 name index: #20 -> RuntimeVisibleTypeAnnotations
+  888-892 length: 40
+  892-894 number of annotations: 3
+  894-905 @Tag(value="ret") on the return type
+    894-895 target type: 0x14 (return type of method, or type of newly constructed object)
+    895-896 type path: none
+      895-896 path length: 0
+    896-898 type index: #15 -> LTag;
+    898-900 number of element value pairs: 1
+    900-905 value="ret"
+      900-902 element name index: #16 -> value
+      902-905 String "ret"
+        902-903 tag: 's' (String)
+        903-905 const value index: #38 -> ret
+  905-919 @Tag(value="elem") on the element type of the type of parameter 1
+    905-906 target type: 0x16 (type in formal parameter declaration of method, constructor, or lambda expression)
+    906-907 formal parameter index: 1
+    907-910 type path: 1 step
+      907-908 path length: 1
+      908-910 step: the element type
+        908-909 type path kind: 0
+        909-910 type argument index: 0
+    910-912 type index: #15 -> LTag;
+    912-914 number of element value pairs: 1
+    914-919 value="elem"
+      914-916 element name index: #16 -> value
+      916-919 String "elem"
+        916-917 tag: 's' (String)
+        917-919 const value index: #39 -> elem
+  919-932 @Tag(value="ex") on exception 0 of the throws clause
+    919-920 target type: 0x17 (type in throws clause of method or constructor)
+    920-922 throws type index: 0
+    922-923 type path: none
+      922-923 path length: 0
+    923-925 type index: #15 -> LTag;
+    925-927 number of element value pairs: 1
+    927-932 value="ex"
+      927-929 element name index: #16 -> value
+      929-932 String "ex"
+        929-930 tag: 's' (String)
+        930-932 const value index: #40 -> ex
+`)
+	checkOutline(t, findSection(t, []Section{m}, "attribute Code", "attribute RuntimeVisibleTypeAnnotations"), 3, `
+808-861 attribute RuntimeVisibleTypeAnnotations
+  808-810 name index: #20 -> RuntimeVisibleTypeAnnotations
+  810-814 length: 47
+  814-816 number of annotations: 3
+  816-835 @Tag(value="local") on the type of the local variable in slot 3 from 4 to 20
+    816-817 target type: 0x40 (type in local variable declaration)
+    817-819 table length: 1
+    819-825 local in slot 3 from 4 to 20
+      819-821 start pc: 4
+      821-823 length: 16
+      823-825 index: 3
+    825-826 type path: none
+      825-826 path length: 0
+    826-828 type index: #15 -> LTag;
+    828-830 number of element value pairs: 1
+    830-835 value="local"
+      830-832 element name index: #16 -> value
+      832-835 String "local"
+  835-848 @Tag(value="new") on the type in the new at 4
+    835-836 target type: 0x44 (type in new expression)
+    836-838 offset: 4
+    838-839 type path: none
+      838-839 path length: 0
+    839-841 type index: #15 -> LTag;
+    841-843 number of element value pairs: 1
+    843-848 value="new"
+      843-845 element name index: #16 -> value
+      845-848 String "new"
+  848-861 @Tag(value="catch") on the type caught by exception handler 0
+    848-849 target type: 0x42 (type in exception parameter declaration)
+    849-851 exception table index: 0
+    851-852 type path: none
+      851-852 path length: 0
+    852-854 type index: #15 -> LTag;
+    854-856 number of element value pairs: 1
+    856-861 value="catch"
+      856-858 element name index: #16 -> value
+      858-861 String "catch"
+`)
+
+	// The targets and path steps the fixture doesn't use.
+	b := newClassBuilder()
+	tag := u2(b.utf8("LTag;"))
+	class := b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "T", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0001, "m", "(Ljava/lang/Object;)V",
+				b.code(1, 2, join(
+					op("aload_1"),
+					op("instanceof"), u2(b.class("java/util/List")),
+					op("pop"),
+					op("return"),
+				), nil,
+					b.attribute("RuntimeInvisibleTypeAnnotations", u2(2),
+						u1(0x43), u2(1), u1(2), u1(1), u1(0), u1(2), u1(0), tag, u2(0),
+						u1(0x47), u2(1), u1(0), u1(0), tag, u2(0))),
+				b.attribute("RuntimeInvisibleTypeAnnotations", u2(3),
+					u1(0x01), u1(0), u1(0), tag, u2(0),
+					u1(0x12), u1(0), u1(1), u1(0), tag, u2(0),
+					u1(0x15), u1(0), tag, u2(0))),
+		},
+	})
+	sections, diagnostics := parseClass(class)
+	if len(diagnostics) != 0 {
+		t.Errorf("built class: %v", diagnostics)
+	}
+	checkCoverage(t, "built class", sections, len(class))
+	checkOutline(t, findSection(t, sections, "attribute Code", "attribute RuntimeInvisibleTypeAnnotations"), 4, `
+180-209 attribute RuntimeInvisibleTypeAnnotations
+  180-182 name index: #4 -> RuntimeInvisibleTypeAnnotations
+  182-186 length: 23
+  186-188 number of annotations: 2
+  188-200 @Tag on the bound of the wildcard of the nested type of the type in the instanceof at 1
+    188-189 target type: 0x43 (type in instanceof expression)
+    189-191 offset: 1
+    191-196 type path: 2 steps
+      191-192 path length: 2
+      192-194 step: the nested type
+        192-193 type path kind: 1
+        193-194 type argument index: 0
+      194-196 step: the bound of the wildcard
+        194-195 type path kind: 2
+        195-196 type argument index: 0
+    196-198 type index: #1 -> LTag;
+    198-200 number of element value pairs: 0
+  200-209 @Tag on type 0 of the cast at 1
+    200-201 target type: 0x47 (type in cast expression)
+    201-203 offset: 1
+    203-204 type argument index: 0
+    204-205 type path: none
+      204-205 path length: 0
+    205-207 type index: #1 -> LTag;
+    207-209 number of element value pairs: 0
+`)
+	m = findSection(t, sections, "method void m")
+	checkOutline(t, m.Children[len(m.Children)-1], 3, `
+209-238 attribute RuntimeInvisibleTypeAnnotations
+  209-211 name index: #4 -> RuntimeInvisibleTypeAnnotations
+  211-215 length: 23
+  215-217 number of annotations: 3
+  217-224 @Tag on type parameter 0 of the method
+    217-218 target type: 0x01 (type parameter declaration of generic method or constructor)
+    218-219 type parameter index: 0
+    219-220 type path: none
+      219-220 path length: 0
+    220-222 type index: #1 -> LTag;
+    222-224 number of element value pairs: 0
+  224-232 @Tag on bound 1 of type parameter 0 of the method
+    224-225 target type: 0x12 (type in bound of type parameter declaration of generic method or constructor)
+    225-226 type parameter index: 0
+    226-227 bound index: 1
+    227-228 type path: none
+      227-228 path length: 0
+    228-230 type index: #1 -> LTag;
+    230-232 number of element value pairs: 0
+  232-238 @Tag on the receiver type
+    232-233 target type: 0x15 (receiver type of method or constructor)
+    233-234 type path: none
+      233-234 path length: 0
+    234-236 type index: #1 -> LTag;
+    236-238 number of element value pairs: 0
+`)
+}
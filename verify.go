@@ -0,0 +1,1296 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type verificationKind int
+
+const (
+	vTop verificationKind = iota
+	vInt
+	vFloat
+	vLong
+	vDouble
+	vNull
+	vUninitializedThis
+	vUninitialized
+	vReference
+)
+
+// verificationType is a type as the type checking verifier sees it (JVMS
+// §4.10.1.2). Booleans, bytes, chars and shorts are all ints.
+type verificationType struct {
+	kind verificationKind
+
+	// name is the class name, or the descriptor of an array, for
+	// references.
+	name string
+
+	// offset is the offset of the new instruction that created an
+	// uninitialized object.
+	offset int
+}
+
+var (
+	topType    = verificationType{kind: vTop}
+	intType    = verificationType{kind: vInt}
+	floatType  = verificationType{kind: vFloat}
+	longType   = verificationType{kind: vLong}
+	doubleType = verificationType{kind: vDouble}
+	nullType   = verificationType{kind: vNull}
+	objectType = reference("java/lang/Object")
+)
+
+func reference(name string) verificationType {
+	return verificationType{kind: vReference, name: name}
+}
+
+func (t verificationType) String() string {
+	switch t.kind {
+	case vTop:
+		return "top"
+	case vInt:
+		return "int"
+	case vFloat:
+		return "float"
+	case vLong:
+		return "long"
+	case vDouble:
+		return "double"
+	case vNull:
+		return "null"
+	case vUninitializedThis:
+		return "uninitializedThis"
+	case vUninitialized:
+		return fmt.Sprintf("uninitialized(%d)", t.offset)
+	}
+	return t.name
+}
+
+func (t verificationType) wide() bool {
+	return t.kind == vLong || t.kind == vDouble
+}
+
+// isReference reports whether t is an initialized reference or null.
+func (t verificationType) isReference() bool {
+	return t.kind == vReference || t.kind == vNull
+}
+
+func (t verificationType) isArray() bool {
+	return t.kind == vReference && strings.HasPrefix(t.name, "[")
+}
+
+// descriptorType returns the verification type of a field descriptor.
+func descriptorType(descriptor string) verificationType {
+	switch descriptor[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return intType
+	case 'F':
+		return floatType
+	case 'J':
+		return longType
+	case 'D':
+		return doubleType
+	case 'L':
+		return reference(descriptor[1 : len(descriptor)-1])
+	}
+	return reference(descriptor)
+}
+
+// arrayOf returns the descriptor of an array of the named class.
+func arrayOf(name string) string {
+	if strings.HasPrefix(name, "[") {
+		return "[" + name
+	}
+	return "[L" + name + ";"
+}
+
+// methodDescriptorParts splits a valid method descriptor into the field
+// descriptors of its parameters and its return type.
+func methodDescriptorParts(descriptor string) (params []string, result string) {
+	d, _ := parseMethodDescriptor(descriptor)
+	for _, p := range d.parameters {
+		params = append(params, p.descriptor())
+	}
+	return params, d.resultDescriptor()
+}
+
+// isAssignable reports whether a value of type from can be used where one
+// of type to is expected. Only this class is available, not the classes it
+// refers to, so any class is assumed to be assignable to any other; arrays
+// and primitive types are checked exactly.
+func isAssignable(from, to verificationType) bool {
+	if from == to || to.kind == vTop {
+		return true
+	}
+	if to.kind != vReference {
+		return false
+	}
+	switch from.kind {
+	case vNull:
+		return true
+	case vReference:
+		return isClassAssignable(from.name, to.name)
+	}
+	return false
+}
+
+func isClassAssignable(from, to string) bool {
+	fromArray, toArray := strings.HasPrefix(from, "["), strings.HasPrefix(to, "[")
+	switch {
+	case to == "java/lang/Object":
+		return true
+	case fromArray && toArray:
+		from, to = from[1:], to[1:]
+		if (from[0] == 'L' || from[0] == '[') && (to[0] == 'L' || to[0] == '[') {
+			return isClassAssignable(descriptorType(from).name, descriptorType(to).name)
+		}
+		return from == to
+	case fromArray:
+		return to == "java/lang/Cloneable" || to == "java/io/Serializable"
+	case toArray:
+		return false
+	}
+	return true
+}
+
+// frame is the types of the local variables and operand stack at some
+// point in a method. Longs and doubles take up two slots in both, the
+// second of which is top.
+type frame struct {
+	locals []verificationType
+	stack  []verificationType
+
+	// thisUninitialized is set in a constructor until it calls another
+	// constructor of this class or its super class.
+	thisUninitialized bool
+}
+
+func (f frame) copy() frame {
+	return frame{
+		locals:            append([]verificationType(nil), f.locals...),
+		stack:             append([]verificationType(nil), f.stack...),
+		thisUninitialized: f.thisUninitialized,
+	}
+}
+
+func (f frame) String() string {
+	s := fmt.Sprintf("locals [%s], stack [%s]", typeList(f.locals), typeList(f.stack))
+	if f.thisUninitialized {
+		s += ", this is uninitialized"
+	}
+	return s
+}
+
+func typeList(types []verificationType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// expand lays out a list of types as they occupy slots.
+func expand(types []verificationType) []verificationType {
+	var slots []verificationType
+	for _, t := range types {
+		slots = append(slots, t)
+		if t.wide() {
+			slots = append(slots, topType)
+		}
+	}
+	return slots
+}
+
+// frameMismatch explains why from is not assignable to to, or returns ""
+// if it is.
+func frameMismatch(from, to frame) string {
+	if len(from.stack) != len(to.stack) {
+		return fmt.Sprintf("the stack holds %d slots, not %d", len(from.stack), len(to.stack))
+	}
+	for i := range to.locals {
+		t := topType
+		if i < len(from.locals) {
+			t = from.locals[i]
+		}
+		if !isAssignable(t, to.locals[i]) {
+			return fmt.Sprintf("local %d is %v, not %v", i, t, to.locals[i])
+		}
+	}
+	for i := range to.stack {
+		if !isAssignable(from.stack[i], to.stack[i]) {
+			return fmt.Sprintf("stack slot %d is %v, not %v", i, from.stack[i], to.stack[i])
+		}
+	}
+	if from.thisUninitialized && !to.thisUninitialized {
+		return "this has not been initialized"
+	}
+	return ""
+}
+
+// verifyError is the first problem found in a method.
+type verifyError struct {
+	start, end int
+	message    string
+	inferred   *frame
+	expected   *frame
+}
+
+// verifier type checks the code of a single method against its
+// StackMapTable (JVMS §4.10.1).
+type verifier struct {
+	class      *Class
+	method     *Method
+	thisClass  string
+	name       string
+	descriptor string
+
+	code         []byte
+	instructions map[int]instruction
+	frames       map[int]frame
+
+	// The instruction being checked and the frame before it, for errors.
+	current instruction
+	before  frame
+	err     *verifyError
+}
+
+// verifyClass type checks every method with code, reporting the first
+// instruction in each that fails. Classes older than version 50 have no
+// stack map frames and are left alone.
+func verifyClass(c *Class) []Diagnostic {
+	if c.MajorVersion < 50 {
+		return nil
+	}
+	thisClass, err := c.classNameAt(c.thisClass)
+	if err != nil {
+		return nil
+	}
+	var diagnostics []Diagnostic
+	for i := range c.methods {
+		m := &c.methods[i]
+		if !hasAttribute(m.attributes, "Code") {
+			continue
+		}
+		name, nameErr := c.utf8At(m.nameIndex)
+		descriptor, descriptorErr := c.utf8At(m.descriptorIndex)
+		if nameErr != nil || descriptorErr != nil || !validMethodDescriptor(descriptor) {
+			continue
+		}
+		v := &verifier{
+			class:      c,
+			method:     m,
+			thisClass:  thisClass,
+			name:       name,
+			descriptor: descriptor,
+			code:       m.Code.Instructions,
+		}
+		v.verify()
+		if e := v.err; e != nil {
+			d := Diagnostic{
+				Severity:   errorSeverity,
+				Message:    fmt.Sprintf("%s%s failed verification: %s", name, descriptor, e.message),
+				StartIndex: e.start,
+				EndIndex:   e.end,
+			}
+			if e.inferred != nil {
+				d.Detail = "inferred: " + e.inferred.String()
+			}
+			if e.expected != nil {
+				d.Detail += "\nexpected: " + e.expected.String()
+			}
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics
+}
+
+func hasAttribute(attributes []attribute, name string) bool {
+	for _, a := range attributes {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fail records a problem with the current instruction unless one has
+// already been found.
+func (v *verifier) fail(format string, args ...interface{}) {
+	if v.err != nil {
+		return
+	}
+	inferred := v.before.copy()
+	start := v.method.Code.offset + v.current.pc
+	v.err = &verifyError{
+		start:    start,
+		end:      start + v.current.length,
+		message:  fmt.Sprintf("%d: %s: ", v.current.pc, v.current.name()) + fmt.Sprintf(format, args...),
+		inferred: &inferred,
+	}
+}
+
+// failFrame records that a frame doesn't match the one expected.
+func (v *verifier) failFrame(f, expected frame, format string, args ...interface{}) {
+	v.fail(format, args...)
+	if v.err.expected == nil {
+		v.err.inferred = &f
+		v.err.expected = &expected
+	}
+}
+
+func (v *verifier) verify() {
+	code := v.method.Code
+	if len(v.code) == 0 {
+		v.err = &verifyError{start: code.offset, end: code.offset, message: "there is no code"}
+		return
+	}
+
+	var order []instruction
+	v.instructions = map[int]instruction{}
+	for pc := 0; pc < len(v.code); {
+		inst, err := decodeInstruction(v.code, pc)
+		if err != nil {
+			v.current = inst
+			v.fail("%v", err)
+			return
+		}
+		v.instructions[pc] = inst
+		order = append(order, inst)
+		pc += inst.length
+	}
+
+	initial, ok := v.initialFrame()
+	if !ok {
+		return
+	}
+	v.frames = v.stackMapFrames(initial)
+	if v.err != nil {
+		return
+	}
+	if !v.checkHandlers() {
+		return
+	}
+
+	f := expandFrame(initial, nil, int(code.maxLocals))
+	fallsThrough := true
+	for _, inst := range order {
+		v.current = inst
+		v.before = f
+		if mapped, ok := v.frames[inst.pc]; ok {
+			if fallsThrough {
+				if why := frameMismatch(f, mapped); why != "" {
+					v.failFrame(f, mapped, "does not match the stack map frame: %s", why)
+					return
+				}
+			}
+			f = mapped.copy()
+			v.before = f
+		} else if !fallsThrough {
+			v.fail("needs a stack map frame, as the instruction before it never falls through")
+			return
+		}
+		// JVMS §4.10.1.6 checks handlers against the locals before each
+		// instruction. HotSpot checks them against the locals after it
+		// too, except for stores, so a call to <init> inside a try block
+		// must suit its handlers both before and after.
+		v.checkExceptions(inst, f)
+		f = f.copy()
+		fallsThrough = v.execute(inst, &f)
+		if v.err == nil && !isStore(inst) {
+			v.checkExceptions(inst, f)
+		}
+		if v.err != nil {
+			return
+		}
+	}
+	if fallsThrough {
+		v.fail("execution falls off the end of the code")
+	}
+}
+
+// isStore reports whether inst stores to a local variable.
+func isStore(inst instruction) bool {
+	return inst.opcode >= 0x36 && inst.opcode <= 0x4e
+}
+
+// initialFrame returns the types of the locals on entry: this, unless the
+// method is static, followed by the parameters.
+func (v *verifier) initialFrame() ([]verificationType, bool) {
+	var locals []verificationType
+	if v.method.accessFlags&Static == 0 {
+		if v.name == "<init>" && v.thisClass != "java/lang/Object" {
+			locals = append(locals, verificationType{kind: vUninitializedThis})
+		} else {
+			locals = append(locals, reference(v.thisClass))
+		}
+	}
+	params, _ := methodDescriptorParts(v.descriptor)
+	for _, p := range params {
+		locals = append(locals, descriptorType(p))
+	}
+	if len(expand(locals)) > int(v.method.Code.maxLocals) {
+		v.err = &verifyError{
+			start:   v.method.Code.offset - 6,
+			end:     v.method.Code.offset - 4,
+			message: fmt.Sprintf("the parameters need %d locals but max locals is %d", len(expand(locals)), v.method.Code.maxLocals),
+		}
+		return nil, false
+	}
+	return locals, true
+}
+
+// expandFrame builds a frame from the locals and stack as listed in a stack
+// map frame, padding the locals with top.
+func expandFrame(locals, stack []verificationType, maxLocals int) frame {
+	f := frame{locals: expand(locals), stack: expand(stack)}
+	for len(f.locals) < maxLocals {
+		f.locals = append(f.locals, topType)
+	}
+	for _, t := range f.locals {
+		if t.kind == vUninitializedThis {
+			f.thisUninitialized = true
+		}
+	}
+	return f
+}
+
+// stackMapFrames decodes the method's StackMapTable (JVMS §4.7.4) into the
+// frame at each offset it describes.
+func (v *verifier) stackMapFrames(initial []verificationType) map[int]frame {
+	frames := map[int]frame{}
+	code := v.method.Code
+	var table *attribute
+	for i, a := range code.attributes {
+		if a.name == "StackMapTable" {
+			if table != nil {
+				v.err = &verifyError{start: a.offset, end: a.offset + len(a.info), message: "there is more than one StackMapTable"}
+				return nil
+			}
+			table = &code.attributes[i]
+		}
+	}
+	if table == nil {
+		return frames
+	}
+	fail := func(format string, args ...interface{}) {
+		if v.err == nil {
+			v.err = &verifyError{start: table.offset, end: table.offset + len(table.info), message: "StackMapTable: " + fmt.Sprintf(format, args...)}
+		}
+	}
+
+	r := newByteParser(table.info, 0)
+	readType := func() verificationType {
+		switch tag := r.u1(); tag {
+		case 0:
+			return topType
+		case 1:
+			return intType
+		case 2:
+			return floatType
+		case 3:
+			return doubleType
+		case 4:
+			return longType
+		case 5:
+			return nullType
+		case 6:
+			return verificationType{kind: vUninitializedThis}
+		case 7:
+			name, err := v.class.classNameAt(r.u2())
+			if err != nil {
+				fail("%v", err)
+				return objectType
+			}
+			if !validReferenceName(name) {
+				fail("%q is not a valid class name", name)
+				return objectType
+			}
+			return reference(name)
+		case 8:
+			offset := int(r.u2())
+			if inst, ok := v.instructions[offset]; !ok || inst.opcode != opNew {
+				fail("uninitialized(%d) does not refer to a new instruction", offset)
+			}
+			return verificationType{kind: vUninitialized, offset: offset}
+		default:
+			fail("unknown verification type %d", tag)
+		}
+		return topType
+	}
+	readTypes := func(n int) []verificationType {
+		var types []verificationType
+		for i := 0; i < n && r.err == nil; i++ {
+			types = append(types, readType())
+		}
+		return types
+	}
+
+	locals := initial
+	pc := -1
+	count := int(r.u2())
+	for i := 0; i < count && r.err == nil && v.err == nil; i++ {
+		var stack []verificationType
+		var delta int
+		switch tag := int(r.u1()); {
+		case tag < 64:
+			delta = tag
+		case tag < 128:
+			delta = tag - 64
+			stack = readTypes(1)
+		case tag < 247:
+			fail("frame %d has reserved type %d", i, tag)
+		case tag == 247:
+			delta = int(r.u2())
+			stack = readTypes(1)
+		case tag < 251:
+			delta = int(r.u2())
+			chop := 251 - tag
+			if chop > len(locals) {
+				fail("frame %d removes %d locals but there are only %d", i, chop, len(locals))
+				break
+			}
+			locals = locals[:len(locals)-chop]
+		case tag == 251:
+			delta = int(r.u2())
+		case tag < 255:
+			delta = int(r.u2())
+			locals = append(append([]verificationType(nil), locals...), readTypes(tag-251)...)
+		default:
+			delta = int(r.u2())
+			locals = readTypes(int(r.u2()))
+			stack = readTypes(int(r.u2()))
+		}
+		pc += delta + 1
+		if _, ok := v.instructions[pc]; !ok && v.err == nil {
+			fail("frame %d is at %d, which is not the start of an instruction", i, pc)
+		}
+		f := expandFrame(locals, stack, int(code.maxLocals))
+		if len(f.locals) > int(code.maxLocals) {
+			fail("frame %d at %d has %d locals but max locals is %d", i, pc, len(f.locals), code.maxLocals)
+		}
+		if len(f.stack) > int(code.maxStack) {
+			fail("frame %d at %d has %d stack slots but max stack is %d", i, pc, len(f.stack), code.maxStack)
+		}
+		frames[pc] = f
+	}
+	if r.err != nil {
+		fail("%v", r.err)
+	}
+	return frames
+}
+
+// checkHandlers makes sure each exception handler covers a range of
+// instructions and starts at an instruction with a stack map frame.
+func (v *verifier) checkHandlers() bool {
+	for i, h := range v.method.Code.ExceptionHandlers {
+		_, startOk := v.instructions[int(h.Start)]
+		_, endOk := v.instructions[int(h.End)]
+		endOk = endOk || int(h.End) == len(v.code)
+		_, handlerOk := v.frames[int(h.Handler)]
+		var message string
+		switch {
+		case !startOk || !endOk || h.Start >= h.End:
+			message = fmt.Sprintf("exception handler %d covers %d to %d, which is not a range of instructions", i, h.Start, h.End)
+		case !handlerOk:
+			message = fmt.Sprintf("exception handler %d at %d has no stack map frame", i, h.Handler)
+		default:
+			continue
+		}
+		// Point at the handler's entry in the exception table.
+		start := v.method.Code.offset + len(v.code) + 2 + 8*i
+		v.err = &verifyError{start: start, end: start + 8, message: message}
+		return false
+	}
+	return true
+}
+
+// checkExceptions makes sure that the handlers covering an instruction can
+// be reached with the locals in f.
+func (v *verifier) checkExceptions(inst instruction, f frame) {
+	for _, h := range v.method.Code.ExceptionHandlers {
+		if inst.pc < int(h.Start) || inst.pc >= int(h.End) {
+			continue
+		}
+		caught := reference("java/lang/Throwable")
+		if h.CatchType != 0 && validReferenceName(h.Class) {
+			caught = reference(h.Class)
+		}
+		thrown := frame{locals: f.locals, stack: []verificationType{caught}, thisUninitialized: f.thisUninitialized}
+		handler := v.frames[int(h.Handler)]
+		if why := frameMismatch(thrown, handler); why != "" {
+			v.failFrame(thrown, handler, "the exception handler at %d can't be reached: %s", h.Handler, why)
+			return
+		}
+	}
+}
+
+// branch checks that f matches the stack map frame at target.
+func (v *verifier) branch(target int, f *frame) {
+	mapped, ok := v.frames[target]
+	if !ok {
+		if _, ok := v.instructions[target]; !ok {
+			v.fail("branches to %d, which is not the start of an instruction", target)
+		} else {
+			v.fail("branches to %d, which has no stack map frame", target)
+		}
+		return
+	}
+	if why := frameMismatch(*f, mapped); why != "" {
+		v.failFrame(f.copy(), mapped, "does not match the stack map frame at %d: %s", target, why)
+	}
+}
+
+func (v *verifier) push(f *frame, t verificationType) {
+	f.stack = append(f.stack, t)
+	if t.wide() {
+		f.stack = append(f.stack, topType)
+	}
+	if len(f.stack) > int(v.method.Code.maxStack) {
+		v.fail("the stack needs more than max stack of %d slots", v.method.Code.maxStack)
+	}
+}
+
+// describeTop names the value on top of the stack.
+func describeTop(f *frame) string {
+	n := len(f.stack)
+	if n == 0 {
+		return "nothing"
+	}
+	if f.stack[n-1].kind == vTop && n > 1 && f.stack[n-2].wide() {
+		return f.stack[n-2].String()
+	}
+	return f.stack[n-1].String()
+}
+
+// pop removes a value that must be assignable to expected from the stack.
+func (v *verifier) pop(f *frame, expected verificationType) verificationType {
+	n := len(f.stack)
+	size := 1
+	if expected.wide() {
+		size = 2
+	}
+	if n < size {
+		v.fail("expected %v on the stack but found %s", expected, describeTop(f))
+		return expected
+	}
+	t := f.stack[n-size]
+	if (size == 2 && f.stack[n-1].kind != vTop) || (size == 1 && !v.cut(f, n-1)) || !isAssignable(t, expected) {
+		v.fail("expected %v on the stack but found %s", expected, describeTop(f))
+		return expected
+	}
+	f.stack = f.stack[:n-size]
+	return t
+}
+
+// popReference removes any initialized reference or null from the stack.
+func (v *verifier) popReference(f *frame) verificationType {
+	return v.pop(f, objectType)
+}
+
+// cut reports whether the stack can be divided below slot i without
+// splitting a long or double in two.
+func (v *verifier) cut(f *frame, i int) bool {
+	return i >= 0 && i < len(f.stack) && f.stack[i].kind != vTop
+}
+
+// shuffle implements the pop, dup and swap instructions, which work on
+// slots. The top n slots are replaced by the given slots, counted from the
+// bottom of those n, after checking each cut point.
+func (v *verifier) shuffle(f *frame, n int, cuts []int, order []int) {
+	if len(f.stack) < n {
+		v.fail("needs %d stack slots but there are only %d", n, len(f.stack))
+		return
+	}
+	base := len(f.stack) - n
+	for _, c := range cuts {
+		if !v.cut(f, base+c) {
+			v.fail("would split a long or double on the stack")
+			return
+		}
+	}
+	top := append([]verificationType(nil), f.stack[base:]...)
+	f.stack = f.stack[:base]
+	for _, i := range order {
+		f.stack = append(f.stack, top[i])
+	}
+	if len(f.stack) > int(v.method.Code.maxStack) {
+		v.fail("the stack needs more than max stack of %d slots", v.method.Code.maxStack)
+	}
+}
+
+func (v *verifier) load(f *frame, index int, expected verificationType) {
+	if index+1 > len(f.locals) || (expected.wide() && index+2 > len(f.locals)) {
+		v.fail("local %d is beyond max locals of %d", index, len(f.locals))
+		return
+	}
+	t := f.locals[index]
+	switch {
+	case expected.kind == vReference:
+		// aload can also load uninitialized objects.
+		if !t.isReference() && t.kind != vUninitialized && t.kind != vUninitializedThis {
+			v.fail("local %d is %v, not a reference", index, t)
+			return
+		}
+	case t != expected:
+		v.fail("local %d is %v, not %v", index, t, expected)
+		return
+	}
+	v.push(f, t)
+}
+
+func (v *verifier) store(f *frame, index int, expected verificationType) {
+	var t verificationType
+	if expected.kind == vReference {
+		n := len(f.stack)
+		if n == 0 || !v.cut(f, n-1) {
+			v.fail("expected a reference on the stack but found %s", describeTop(f))
+			return
+		}
+		t = f.stack[n-1]
+		if !t.isReference() && t.kind != vUninitialized && t.kind != vUninitializedThis {
+			v.fail("expected a reference on the stack but found %v", t)
+			return
+		}
+		f.stack = f.stack[:n-1]
+	} else {
+		t = v.pop(f, expected)
+	}
+	size := 1
+	if t.wide() {
+		size = 2
+	}
+	if index+size > len(f.locals) {
+		v.fail("local %d is beyond max locals of %d", index, len(f.locals))
+		return
+	}
+	// Overwriting the second half of a long or double invalidates it.
+	if index > 0 && f.locals[index-1].wide() {
+		f.locals[index-1] = topType
+	}
+	f.locals[index] = t
+	if size == 2 {
+		f.locals[index+1] = topType
+	}
+}
+
+// letterTypes maps the letters used in stackEffects to types. A is any
+// reference.
+var letterTypes = map[byte]verificationType{
+	'I': intType,
+	'J': longType,
+	'F': floatType,
+	'D': doubleType,
+	'A': objectType,
+}
+
+// stackEffects lists the operands popped and results pushed by the
+// instructions that need nothing more than that, in the order they appear
+// on the stack.
+var stackEffects = map[uint8][2]string{
+	0x00: {"", ""},
+	0x02: {"", "I"}, 0x03: {"", "I"}, 0x04: {"", "I"}, 0x05: {"", "I"}, 0x06: {"", "I"}, 0x07: {"", "I"}, 0x08: {"", "I"},
+	0x09: {"", "J"}, 0x0a: {"", "J"},
+	0x0b: {"", "F"}, 0x0c: {"", "F"}, 0x0d: {"", "F"},
+	0x0e: {"", "D"}, 0x0f: {"", "D"},
+	0x10: {"", "I"}, 0x11: {"", "I"},
+	0x60: {"II", "I"}, 0x61: {"JJ", "J"}, 0x62: {"FF", "F"}, 0x63: {"DD", "D"},
+	0x64: {"II", "I"}, 0x65: {"JJ", "J"}, 0x66: {"FF", "F"}, 0x67: {"DD", "D"},
+	0x68: {"II", "I"}, 0x69: {"JJ", "J"}, 0x6a: {"FF", "F"}, 0x6b: {"DD", "D"},
+	0x6c: {"II", "I"}, 0x6d: {"JJ", "J"}, 0x6e: {"FF", "F"}, 0x6f: {"DD", "D"},
+	0x70: {"II", "I"}, 0x71: {"JJ", "J"}, 0x72: {"FF", "F"}, 0x73: {"DD", "D"},
+	0x74: {"I", "I"}, 0x75: {"J", "J"}, 0x76: {"F", "F"}, 0x77: {"D", "D"},
+	0x78: {"II", "I"}, 0x79: {"JI", "J"}, 0x7a: {"II", "I"}, 0x7b: {"JI", "J"}, 0x7c: {"II", "I"}, 0x7d: {"JI", "J"},
+	0x7e: {"II", "I"}, 0x7f: {"JJ", "J"}, 0x80: {"II", "I"}, 0x81: {"JJ", "J"}, 0x82: {"II", "I"}, 0x83: {"JJ", "J"},
+	0x85: {"I", "J"}, 0x86: {"I", "F"}, 0x87: {"I", "D"},
+	0x88: {"J", "I"}, 0x89: {"J", "F"}, 0x8a: {"J", "D"},
+	0x8b: {"F", "I"}, 0x8c: {"F", "J"}, 0x8d: {"F", "D"},
+	0x8e: {"D", "I"}, 0x8f: {"D", "J"}, 0x90: {"D", "F"},
+	0x91: {"I", "I"}, 0x92: {"I", "I"}, 0x93: {"I", "I"},
+	0x94: {"JJ", "I"}, 0x95: {"FF", "I"}, 0x96: {"FF", "I"}, 0x97: {"DD", "I"}, 0x98: {"DD", "I"},
+	0x99: {"I", ""}, 0x9a: {"I", ""}, 0x9b: {"I", ""}, 0x9c: {"I", ""}, 0x9d: {"I", ""}, 0x9e: {"I", ""},
+	0x9f: {"II", ""}, 0xa0: {"II", ""}, 0xa1: {"II", ""}, 0xa2: {"II", ""}, 0xa3: {"II", ""}, 0xa4: {"II", ""},
+	0xa5: {"AA", ""}, 0xa6: {"AA", ""},
+	0xa7: {"", ""}, 0xc8: {"", ""},
+	0xaa: {"I", ""}, 0xab: {"I", ""},
+	0xc2: {"A", ""}, 0xc3: {"A", ""},
+	0xc6: {"A", ""}, 0xc7: {"A", ""},
+}
+
+// arrayElements gives the element type of the arrays read by the xaload
+// instructions and written by the xastore ones. Byte instructions work on
+// boolean arrays too.
+var arrayElements = map[uint8]string{
+	0x2e: "I", 0x2f: "J", 0x30: "F", 0x31: "D", 0x33: "B", 0x34: "C", 0x35: "S",
+	0x4f: "I", 0x50: "J", 0x51: "F", 0x52: "D", 0x54: "B", 0x55: "C", 0x56: "S",
+}
+
+// newArrayTypes gives the descriptor of the array made by newarray for
+// each array type.
+var newArrayTypes = map[int]string{
+	4: "[Z", 5: "[C", 6: "[F", 7: "[D", 8: "[B", 9: "[S", 10: "[I", 11: "[J",
+}
+
+// localTypes is the type loaded or stored by each group of load and store
+// instructions, in opcode order.
+var localTypes = []verificationType{intType, longType, floatType, doubleType, objectType}
+
+const (
+	opNew             = 0xbb
+	opInvokeVirtual   = 0xb6
+	opInvokeSpecial   = 0xb7
+	opInvokeStatic    = 0xb8
+	opInvokeInterface = 0xb9
+)
+
+// execute updates f to the frame after inst and reports whether execution
+// can carry on to the next instruction.
+func (v *verifier) execute(inst instruction, f *frame) bool {
+	op := inst.opcode
+	local, _ := inst.operand(localIndex)
+	cpIndex, _ := inst.operand(constantIndex)
+	if effect, ok := stackEffects[op]; ok {
+		for i := len(effect[0]) - 1; i >= 0; i-- {
+			v.pop(f, letterTypes[effect[0][i]])
+		}
+		for i := 0; i < len(effect[1]); i++ {
+			v.push(f, letterTypes[effect[1][i]])
+		}
+	} else {
+		switch {
+		case op == 0x01:
+			v.push(f, nullType)
+		case op == 0x12 || op == 0x13 || op == 0x14:
+			v.ldc(f, op, uint16(cpIndex.value))
+		case op >= 0x15 && op <= 0x19:
+			v.load(f, local.value, localTypes[op-0x15])
+		case op >= 0x1a && op <= 0x2d:
+			v.load(f, int(op-0x1a)%4, localTypes[(op-0x1a)/4])
+		case op >= 0x2e && op <= 0x35:
+			v.arrayLoad(f, op)
+		case op >= 0x36 && op <= 0x3a:
+			v.store(f, local.value, localTypes[op-0x36])
+		case op >= 0x3b && op <= 0x4e:
+			v.store(f, int(op-0x3b)%4, localTypes[(op-0x3b)/4])
+		case op >= 0x4f && op <= 0x56:
+			v.arrayStore(f, op)
+		case op == 0x57:
+			v.shuffle(f, 1, []int{0}, nil)
+		case op == 0x58:
+			v.shuffle(f, 2, []int{0}, nil)
+		case op == 0x59:
+			v.shuffle(f, 1, []int{0}, []int{0, 0})
+		case op == 0x5a:
+			v.shuffle(f, 2, []int{0, 1}, []int{1, 0, 1})
+		case op == 0x5b:
+			v.shuffle(f, 3, []int{0, 2}, []int{2, 0, 1, 2})
+		case op == 0x5c:
+			v.shuffle(f, 2, []int{0}, []int{0, 1, 0, 1})
+		case op == 0x5d:
+			v.shuffle(f, 3, []int{0, 1}, []int{1, 2, 0, 1, 2})
+		case op == 0x5e:
+			v.shuffle(f, 4, []int{0, 2}, []int{2, 3, 0, 1, 2, 3})
+		case op == 0x5f:
+			v.shuffle(f, 2, []int{0, 1}, []int{1, 0})
+		case op == opIinc:
+			if local.value >= len(f.locals) || f.locals[local.value] != intType {
+				v.fail("local %d is not an int", local.value)
+			}
+		case op >= 0xac && op <= 0xb1:
+			v.doReturn(f, op)
+		case op >= 0xb2 && op <= 0xb5:
+			v.fieldAccess(f, op, uint16(cpIndex.value))
+		case op >= 0xb6 && op <= 0xb9:
+			v.invoke(f, op, uint16(cpIndex.value))
+		case op == 0xba:
+			v.invokeDynamic(f, uint16(cpIndex.value))
+		case op == opNew:
+			if name, ok := v.className(uint16(cpIndex.value)); ok {
+				if strings.HasPrefix(name, "[") {
+					v.fail("can't create an array %s", name)
+				}
+				v.push(f, verificationType{kind: vUninitialized, offset: inst.pc})
+			}
+		case op == 0xbc:
+			v.pop(f, intType)
+			atype, _ := inst.operand(arrayType)
+			if name, ok := newArrayTypes[atype.value]; ok {
+				v.push(f, reference(name))
+			} else {
+				v.fail("unknown array type %d", atype.value)
+			}
+		case op == 0xbd:
+			v.pop(f, intType)
+			if name, ok := v.className(uint16(cpIndex.value)); ok {
+				v.push(f, reference(arrayOf(name)))
+			}
+		case op == 0xbe:
+			if t := v.popReference(f); t.kind != vNull && !t.isArray() {
+				v.fail("expected an array but found %v", t)
+			}
+			v.push(f, intType)
+		case op == 0xbf:
+			v.pop(f, reference("java/lang/Throwable"))
+		case op == 0xc0:
+			v.popReference(f)
+			if name, ok := v.className(uint16(cpIndex.value)); ok {
+				v.push(f, reference(name))
+			}
+		case op == 0xc1:
+			v.popReference(f)
+			v.push(f, intType)
+		case op == 0xc5:
+			dims, _ := inst.operand(dimensions)
+			name, ok := v.className(uint16(cpIndex.value))
+			if ok && (dims.value == 0 || !strings.HasPrefix(name, strings.Repeat("[", dims.value))) {
+				v.fail("can't make %d dimensions of %s", dims.value, name)
+			}
+			for i := 0; i < dims.value; i++ {
+				v.pop(f, intType)
+			}
+			v.push(f, reference(name))
+		case op == 0xa8 || op == 0xa9 || op == 0xc9:
+			v.fail("subroutines can't be type checked")
+		default:
+			v.fail("unknown instruction")
+		}
+	}
+	if v.err != nil {
+		return false
+	}
+	for _, o := range inst.operands {
+		if o.kind == branchOffset || o.kind == defaultOffset {
+			v.branch(inst.pc+o.value, f)
+		}
+	}
+	switch op {
+	case 0xa7, 0xc8, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf, 0xb0, 0xb1, 0xbf:
+		return false
+	}
+	return true
+}
+
+func (v *verifier) className(index uint16) (string, bool) {
+	name, err := v.class.classNameAt(index)
+	if err != nil {
+		v.fail("%v", err)
+		return "", false
+	}
+	if !validReferenceName(name) {
+		v.fail("%q is not a valid class name", name)
+		return "", false
+	}
+	return name, true
+}
+
+func (v *verifier) ldc(f *frame, op uint8, index uint16) {
+	if index == 0 || int(index) > len(v.class.ConstantPoolItems) {
+		v.fail("constant pool index %d is out of range", index)
+		return
+	}
+	item := v.class.ConstantPoolItems[index-1]
+	var t verificationType
+	switch item := item.(type) {
+	case intConstant:
+		t = intType
+	case floatConstant:
+		t = floatType
+	case longConstant:
+		t = longType
+	case doubleConstant:
+		t = doubleType
+	case stringConstant:
+		t = reference("java/lang/String")
+	case classInfo:
+		t = reference("java/lang/Class")
+	case methodType:
+		t = reference("java/lang/invoke/MethodType")
+	case methodHandle:
+		t = reference("java/lang/invoke/MethodHandle")
+	case dynamicConstant:
+		_, descriptor, ok := v.nameAndType(item.nameAndTypeIndex)
+		if !ok {
+			return
+		}
+		if !validFieldDescriptor(descriptor) {
+			v.fail("dynamic #%d has %q, which is not a field descriptor", index, descriptor)
+			return
+		}
+		t = descriptorType(descriptor)
+	default:
+		v.fail("can't load a %s", constantKind(item))
+		return
+	}
+	if t.wide() != (op == 0x14) {
+		v.fail("can't load a %s", constantKind(item))
+		return
+	}
+	v.push(f, t)
+}
+
+func (v *verifier) arrayLoad(f *frame, op uint8) {
+	v.pop(f, intType)
+	if op == 0x32 {
+		array := v.popReference(f)
+		switch {
+		case array.kind == vNull:
+			v.push(f, nullType)
+		case array.isArray() && (array.name[1] == 'L' || array.name[1] == '['):
+			v.push(f, descriptorType(array.name[1:]))
+		default:
+			v.fail("expected an array of references but found %v", array)
+		}
+		return
+	}
+	element := arrayElements[op]
+	v.checkArray(v.popReference(f), element)
+	v.push(f, descriptorType(element))
+}
+
+func (v *verifier) arrayStore(f *frame, op uint8) {
+	if op == 0x53 {
+		v.popReference(f)
+		v.pop(f, intType)
+		if array := v.popReference(f); array.kind != vNull && !(array.isArray() && (array.name[1] == 'L' || array.name[1] == '[')) {
+			v.fail("expected an array of references but found %v", array)
+		}
+		return
+	}
+	element := arrayElements[op]
+	v.pop(f, descriptorType(element))
+	v.pop(f, intType)
+	v.checkArray(v.popReference(f), element)
+}
+
+func (v *verifier) checkArray(array verificationType, element string) {
+	if array.kind == vNull {
+		return
+	}
+	if array.name == "["+element || (element == "B" && array.name == "[Z") {
+		return
+	}
+	v.fail("expected an array of %v but found %v", "["+element, array)
+}
+
+func (v *verifier) doReturn(f *frame, op uint8) {
+	_, result := methodDescriptorParts(v.descriptor)
+	if op == 0xb1 {
+		if result != "V" {
+			v.fail("returns nothing from a method that returns %s", result)
+		}
+		if f.thisUninitialized {
+			v.fail("returns before this is initialized")
+		}
+		return
+	}
+	want := map[uint8]string{0xac: "I", 0xad: "J", 0xae: "F", 0xaf: "D", 0xb0: "A"}[op]
+	if result == "V" || (want == "A") != (result[0] == 'L' || result[0] == '[') || (want != "A" && descriptorType(result) != letterTypes[want[0]]) {
+		v.fail("can't return from a method that returns %s", result)
+		return
+	}
+	v.pop(f, descriptorType(result))
+}
+
+// memberRef returns the class, name and descriptor of a field or method
+// ref.
+func (v *verifier) memberRef(index uint16) (class, name, descriptor string, ok bool) {
+	if index == 0 || int(index) > len(v.class.ConstantPoolItems) {
+		v.fail("constant pool index %d is out of range", index)
+		return
+	}
+	var classIndex, natIndex uint16
+	switch item := v.class.ConstantPoolItems[index-1].(type) {
+	case fieldRef:
+		classIndex, natIndex = item.classIndex, item.nameAndTypeIndex
+	case methodRef:
+		classIndex, natIndex = item.classIndex, item.nameAndTypeIndex
+	case interfaceMethodRef:
+		classIndex, natIndex = item.classIndex, item.nameAndTypeIndex
+	default:
+		v.fail("#%d is a %s", index, constantKind(item))
+		return
+	}
+	class, ok = v.className(classIndex)
+	if !ok {
+		return
+	}
+	name, descriptor, ok = v.nameAndType(natIndex)
+	return
+}
+
+func (v *verifier) nameAndType(index uint16) (name, descriptor string, ok bool) {
+	if index == 0 || int(index) > len(v.class.ConstantPoolItems) {
+		v.fail("constant pool index %d is out of range", index)
+		return
+	}
+	nat, isNat := v.class.ConstantPoolItems[index-1].(nameAndType)
+	if !isNat {
+		v.fail("#%d is not a name and type", index)
+		return
+	}
+	name, nameErr := v.class.utf8At(nat.nameIndex)
+	descriptor, descriptorErr := v.class.utf8At(nat.descriptorIndex)
+	if nameErr != nil || descriptorErr != nil {
+		v.fail("#%d has a bad name or descriptor", index)
+		return
+	}
+	return name, descriptor, true
+}
+
+func (v *verifier) fieldAccess(f *frame, op uint8, index uint16) {
+	class, _, descriptor, ok := v.memberRef(index)
+	if !ok {
+		return
+	}
+	if !validFieldDescriptor(descriptor) {
+		v.fail("%q is not a field descriptor", descriptor)
+		return
+	}
+	t := descriptorType(descriptor)
+	switch op {
+	case 0xb2:
+		v.push(f, t)
+	case 0xb3:
+		v.pop(f, t)
+	case 0xb4:
+		v.pop(f, reference(class))
+		v.push(f, t)
+	case 0xb5:
+		v.pop(f, t)
+		// Constructors may set their own fields before calling super.
+		n := len(f.stack)
+		if n > 0 && f.stack[n-1].kind == vUninitializedThis && class == v.thisClass {
+			f.stack = f.stack[:n-1]
+			return
+		}
+		v.pop(f, reference(class))
+	}
+}
+
+func (v *verifier) popArguments(f *frame, descriptor string) (result string, ok bool) {
+	if !validMethodDescriptor(descriptor) {
+		v.fail("%q is not a method descriptor", descriptor)
+		return "", false
+	}
+	params, result := methodDescriptorParts(descriptor)
+	for i := len(params) - 1; i >= 0; i-- {
+		v.pop(f, descriptorType(params[i]))
+	}
+	return result, true
+}
+
+func (v *verifier) pushResult(f *frame, result string) {
+	if result != "V" {
+		v.push(f, descriptorType(result))
+	}
+}
+
+func (v *verifier) invoke(f *frame, op uint8, index uint16) {
+	class, name, descriptor, ok := v.memberRef(index)
+	if !ok {
+		return
+	}
+	switch v.class.ConstantPoolItems[index-1].(type) {
+	case fieldRef:
+		v.fail("#%d is a field ref", index)
+		return
+	case methodRef:
+		if op == opInvokeInterface {
+			v.fail("#%d is a method ref, not an interface method ref", index)
+			return
+		}
+	case interfaceMethodRef:
+		if op == opInvokeVirtual {
+			v.fail("#%d is an interface method ref, not a method ref", index)
+			return
+		}
+	}
+	result, ok := v.popArguments(f, descriptor)
+	if !ok {
+		return
+	}
+	if name == "<init>" {
+		if op != opInvokeSpecial {
+			v.fail("only invokespecial can call <init>")
+			return
+		}
+		v.initialize(f, class)
+		return
+	}
+	if strings.HasPrefix(name, "<") {
+		v.fail("can't call %s", name)
+		return
+	}
+	if op != opInvokeStatic {
+		v.pop(f, reference(class))
+	}
+	v.pushResult(f, result)
+}
+
+// initialize handles a call to <init>, which turns every copy of the
+// uninitialized object it is called on into an initialized one.
+func (v *verifier) initialize(f *frame, class string) {
+	n := len(f.stack)
+	if n == 0 {
+		v.fail("expected an uninitialized object on the stack but found nothing")
+		return
+	}
+	receiver := f.stack[n-1]
+	var initialized verificationType
+	switch receiver.kind {
+	case vUninitializedThis:
+		superClass, _ := v.class.classNameAt(v.class.superClass)
+		if class != v.thisClass && class != superClass {
+			v.fail("this must be initialized by a constructor of %s or %s, not %s", v.thisClass, superClass, class)
+			return
+		}
+		initialized = reference(v.thisClass)
+		f.thisUninitialized = false
+	case vUninitialized:
+		created, _ := v.instructions[receiver.offset].operand(constantIndex)
+		name, ok := v.className(uint16(created.value))
+		if !ok {
+			return
+		}
+		if name != class {
+			v.fail("calls a constructor of %s on a new %s", class, name)
+			return
+		}
+		initialized = reference(name)
+	default:
+		v.fail("expected an uninitialized object on the stack but found %s", describeTop(f))
+		return
+	}
+	f.stack = f.stack[:n-1]
+	for i, t := range f.stack {
+		if t == receiver {
+			f.stack[i] = initialized
+		}
+	}
+	for i, t := range f.locals {
+		if t == receiver {
+			f.locals[i] = initialized
+		}
+	}
+}
+
+func (v *verifier) invokeDynamic(f *frame, index uint16) {
+	if index == 0 || int(index) > len(v.class.ConstantPoolItems) {
+		v.fail("constant pool index %d is out of range", index)
+		return
+	}
+	indy, ok := v.class.ConstantPoolItems[index-1].(invokeDynamic)
+	if !ok {
+		v.fail("#%d is not an invoke dynamic", index)
+		return
+	}
+	_, descriptor, ok := v.nameAndType(indy.nameAndTypeIndex)
+	if !ok {
+		return
+	}
+	if result, ok := v.popArguments(f, descriptor); ok {
+		v.pushResult(f, result)
+	}
+}
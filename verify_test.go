@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerifyFixtures(t *testing.T) {
+	for _, name := range []string{
+		"HelloWorld.class",
+		"Branches6.class",
+		"Lambda8.class",
+		"Nest11.class",
+		"Nest11$Inner.class",
+		"Tag.class",
+		"Annotated.class",
+		"Condy.class",
+		"Point17.class",
+		"Shape25.class",
+	} {
+		c, err := ParseClass(bytes.NewReader(readFixture(t, name)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		for _, d := range verifyClass(&c) {
+			t.Errorf("%s: %s\n%s", name, d.Message, d.Detail)
+		}
+	}
+}
+
+// verifiedMethod is a static method for TestVerify to check.
+type verifiedMethod struct {
+	descriptor          string
+	maxStack, maxLocals int
+	code                func(b *classBuilder) []byte
+	handlers            []handler
+	frames              func(b *classBuilder) []byte
+	frameCount          int
+}
+
+func (m verifiedMethod) class() []byte {
+	b := newClassBuilder()
+	var attrs [][]byte
+	if m.frames != nil {
+		attrs = append(attrs, b.attribute("StackMapTable", u2(m.frameCount), m.frames(b)))
+	}
+	return b.build(classSpec{
+		major: 52,
+		flags: 0x0021,
+		this:  "V", super: "java/lang/Object",
+		methods: [][]byte{
+			b.member(0x0008, "m", m.descriptor,
+				b.code(m.maxStack, m.maxLocals, m.code(b), m.handlers, attrs...)),
+		},
+	})
+}
+
+func TestVerify(t *testing.T) {
+	const (
+		top           = 0
+		float         = 2
+		object        = 7
+		uninitialized = 8
+	)
+	tests := []struct {
+		name    string
+		method  verifiedMethod
+		message string
+		detail  string
+	}{
+		{
+			name: "stack underflow",
+			method: verifiedMethod{
+				descriptor: "()I", maxStack: 2,
+				code: func(b *classBuilder) []byte {
+					return join(op("iconst_1"), op("iadd"), op("ireturn"))
+				},
+			},
+			message: "m()I failed verification: 1: iadd: expected int on the stack but found nothing",
+			detail:  "inferred: locals [], stack [int]",
+		},
+		{
+			name: "wrong local type",
+			method: verifiedMethod{
+				descriptor: "(Ljava/lang/String;)I", maxStack: 1, maxLocals: 1,
+				code: func(b *classBuilder) []byte {
+					return join(op("iload_0"), op("ireturn"))
+				},
+			},
+			message: "m(Ljava/lang/String;)I failed verification: 0: iload_0: local 0 is java/lang/String, not int",
+			detail:  "inferred: locals [java/lang/String], stack []",
+		},
+		{
+			name: "frame mismatch at a branch target",
+			method: verifiedMethod{
+				descriptor: "(I)I", maxStack: 1, maxLocals: 1,
+				code: func(b *classBuilder) []byte {
+					return join(
+						op("iload_0"),
+						op("ifeq"), u2(5),
+						op("iconst_1"),
+						op("ireturn"),
+						op("iconst_0"),
+						op("ireturn"))
+				},
+				frameCount: 1,
+				frames: func(b *classBuilder) []byte {
+					return join(u1(255), u2(6), u2(1), u1(float), u2(0))
+				},
+			},
+			message: "m(I)I failed verification: 1: ifeq: does not match the stack map frame at 6: local 0 is int, not float",
+			detail:  "inferred: locals [int], stack []\nexpected: locals [float], stack []",
+		},
+		{
+			name: "invokeinterface on a method ref",
+			method: verifiedMethod{
+				descriptor: "(Ljava/lang/Runnable;)V", maxStack: 1, maxLocals: 1,
+				code: func(b *classBuilder) []byte {
+					return join(
+						op("aload_0"),
+						op("invokeinterface"), u2(b.methodref("java/lang/Runnable", "run", "()V")), u1(1), u1(0),
+						op("return"))
+				},
+			},
+			message: "m(Ljava/lang/Runnable;)V failed verification: 1: invokeinterface: #6 is a method ref, not an interface method ref",
+			detail:  "inferred: locals [java/lang/Runnable], stack [java/lang/Runnable]",
+		},
+		{
+			name: "invokevirtual on an interface method ref",
+			method: verifiedMethod{
+				descriptor: "(Ljava/lang/Runnable;)V", maxStack: 1, maxLocals: 1,
+				code: func(b *classBuilder) []byte {
+					return join(
+						op("aload_0"),
+						op("invokevirtual"), u2(b.interfaceMethodref("java/lang/Runnable", "run", "()V")),
+						op("return"))
+				},
+			},
+			message: "m(Ljava/lang/Runnable;)V failed verification: 1: invokevirtual: #6 is an interface method ref, not a method ref",
+			detail:  "inferred: locals [java/lang/Runnable], stack [java/lang/Runnable]",
+		},
+		{
+			name: "handler that can't take the locals after <init>",
+			method: verifiedMethod{
+				descriptor: "()V", maxStack: 1, maxLocals: 2,
+				code: func(b *classBuilder) []byte {
+					return join(
+						op("new"), u2(b.class("java/lang/Object")),
+						op("astore_1"),
+						op("aload_1"),
+						op("invokespecial"), u2(b.methodref("java/lang/Object", "<init>", "()V")),
+						op("return"),
+						op("pop"),
+						op("return"))
+				},
+				handlers:   []handler{{5, 8, 9, ""}},
+				frameCount: 1,
+				frames: func(b *classBuilder) []byte {
+					return join(u1(255), u2(9), u2(2), u1(top), u1(uninitialized), u2(0),
+						u2(1), u1(object), u2(b.class("java/lang/Throwable")))
+				},
+			},
+			message: "m()V failed verification: 5: invokespecial: the exception handler at 9 can't be reached: local 1 is java/lang/Object, not uninitialized(0)",
+			detail:  "inferred: locals [top, java/lang/Object], stack [java/lang/Throwable]\nexpected: locals [top, uninitialized(0)], stack [java/lang/Throwable]",
+		},
+	}
+	for _, test := range tests {
+		c, err := ParseClass(bytes.NewReader(test.method.class()))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		diagnostics := verifyClass(&c)
+		if len(diagnostics) != 1 {
+			t.Errorf("%s: %d diagnostics, want 1: %v", test.name, len(diagnostics), diagnostics)
+			continue
+		}
+		d := diagnostics[0]
+		if d.Message != test.message {
+			t.Errorf("%s: message\n%s\nwant\n%s", test.name, d.Message, test.message)
+		}
+		if d.Detail != test.detail {
+			t.Errorf("%s: detail\n%s\nwant\n%s", test.name, d.Detail, test.detail)
+		}
+	}
+}
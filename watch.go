@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// classDirectory serves the class files found under a directory, such as
+// a Gradle build/classes output, and tells subscribers when they change.
+type classDirectory struct {
+	root string
+
+	sync.Mutex
+	files       map[string]fileState
+	subscribers map[chan classChange]bool
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// classChange is sent to subscribers when a class file is added, modified
+// or removed. Path is relative to the directory and uses forward slashes.
+type classChange struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+func newClassDirectory(root string) (*classDirectory, error) {
+	d := &classDirectory{
+		root:        root,
+		subscribers: map[chan classChange]bool{},
+	}
+	files, err := d.scan()
+	if err != nil {
+		return nil, err
+	}
+	d.files = files
+	return d, nil
+}
+
+// scan finds every class file under the directory.
+func (d *classDirectory) scan() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.Walk(d.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files can disappear mid-walk while a build is running.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !isClassEntry(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(d.root, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fileState{info.ModTime(), info.Size()}
+		return nil
+	})
+	return files, err
+}
+
+// list returns the paths of every class file, sorted.
+func (d *classDirectory) list() []string {
+	d.Lock()
+	defer d.Unlock()
+	paths := make([]string, 0, len(d.files))
+	for path := range d.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// read returns the contents of a class file, refusing anything that is not
+// a class file we know about so that requests can't escape the directory.
+func (d *classDirectory) read(path string) ([]byte, error) {
+	path = strings.TrimPrefix(path, "/")
+	d.Lock()
+	_, ok := d.files[path]
+	d.Unlock()
+	if !ok {
+		return nil, errors.New("no such class file: " + path)
+	}
+	return ioutil.ReadFile(filepath.Join(d.root, filepath.FromSlash(path)))
+}
+
+// source returns the Java source of the class file at path if it sits
+// next to it, as it does when javac is run without -d.
+func (d *classDirectory) source(path string, classFile []byte) ([]byte, error) {
+	name, ok := sourcePath(strings.TrimPrefix(path, "/"), classFile)
+	if !ok {
+		return nil, errors.New("no source file for " + path)
+	}
+	return ioutil.ReadFile(filepath.Join(d.root, filepath.FromSlash(name)))
+}
+
+func (d *classDirectory) subscribe() chan classChange {
+	ch := make(chan classChange, 64)
+	d.Lock()
+	d.subscribers[ch] = true
+	d.Unlock()
+	return ch
+}
+
+func (d *classDirectory) unsubscribe(ch chan classChange) {
+	d.Lock()
+	delete(d.subscribers, ch)
+	d.Unlock()
+}
+
+// watch polls the directory every interval and notifies subscribers of any
+// changes. It never returns.
+func (d *classDirectory) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		d.rescan()
+	}
+}
+
+// rescan looks for class files that were added, modified or removed since
+// the last scan and notifies subscribers of them.
+func (d *classDirectory) rescan() {
+	files, err := d.scan()
+	if err != nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	var changes []classChange
+	for path, state := range files {
+		old, ok := d.files[path]
+		switch {
+		case !ok:
+			changes = append(changes, classChange{"added", path})
+		case !old.modTime.Equal(state.modTime) || old.size != state.size:
+			changes = append(changes, classChange{"modified", path})
+		}
+	}
+	for path := range d.files {
+		if _, ok := files[path]; !ok {
+			changes = append(changes, classChange{"removed", path})
+		}
+	}
+	d.files = files
+	for ch := range d.subscribers {
+		for _, c := range changes {
+			select {
+			case ch <- c:
+			default:
+				// A slow subscriber misses changes rather than
+				// holding up everyone else.
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// classTree writes files, given by slash-separated paths, under a new
+// temporary directory.
+func classTree(t *testing.T, files map[string][]byte) string {
+	root, err := ioutil.TempDir("", "classes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDirectory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	class := readFixture(t, "Hello11.class")
+	root := classTree(t, map[string][]byte{
+		"com/example/Hello11.class": class,
+		"com/example/Hello11.java":  []byte("class Hello11 {}\n"),
+		"Box5.class":                readFixture(t, "Box5.class"),
+		"notes.txt":                 []byte("not a class"),
+	})
+	defer os.RemoveAll(root)
+	dir, err := newClassDirectory(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouter(nil, nil, newArchiveStore(), dir)
+	get := func(url string, status int, v interface{}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != status {
+			t.Errorf("GET %s: status %d: %s", url, w.Code, w.Body.String())
+			return
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Errorf("GET %s: %v", url, err)
+		}
+	}
+
+	var paths []string
+	get("/directory", http.StatusOK, &paths)
+	if want := []string{"Box5.class", "com/example/Hello11.class"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("listed %v, want %v", paths, want)
+	}
+
+	var response struct {
+		Source string
+		classResponse
+	}
+	get("/directory/com/example/Hello11.class", http.StatusOK, &response)
+	sections, _ := parseClass(class)
+	want, _ := json.Marshal(sections)
+	if parsed, _ := json.Marshal(response.Parsed); !bytes.Equal(parsed, want) {
+		t.Error("sections differ from those of parseClass")
+	}
+	checkCoverage(t, "Hello11.class", response.Parsed, len(class))
+	if response.Source != "class Hello11 {}\n" {
+		t.Errorf("source %q", response.Source)
+	}
+
+	var failure struct{ Error string }
+	get("/directory/notes.txt", http.StatusNotFound, &failure)
+	get("/directory/../Box5.class", http.StatusNotFound, &failure)
+}
+
+func TestDirectoryChanges(t *testing.T) {
+	root := classTree(t, map[string][]byte{
+		"A.class": {1},
+		"B.class": {1},
+	})
+	defer os.RemoveAll(root)
+	dir, err := newClassDirectory(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := dir.subscribe()
+	defer dir.unsubscribe(changes)
+
+	ioutil.WriteFile(filepath.Join(root, "A.class"), []byte{1, 2}, 0644)
+	os.Remove(filepath.Join(root, "B.class"))
+	ioutil.WriteFile(filepath.Join(root, "C.class"), []byte{1}, 0644)
+	ioutil.WriteFile(filepath.Join(root, "C.java"), []byte{1}, 0644)
+	dir.rescan()
+	var got []string
+	for len(changes) > 0 {
+		c := <-changes
+		got = append(got, c.Kind+" "+c.Path)
+	}
+	sort.Strings(got)
+	if want := []string{"added C.class", "modified A.class", "removed B.class"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changes %v, want %v", got, want)
+	}
+
+	dir.rescan()
+	if len(changes) != 0 {
+		t.Errorf("%d changes without any edits", len(changes))
+	}
+}
+
+func TestDirectoryEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	root := classTree(t, map[string][]byte{"A.class": {1}})
+	defer os.RemoveAll(root)
+	dir, err := newClassDirectory(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newRouter(nil, nil, newArchiveStore(), dir))
+	defer server.Close()
+
+	// The response starts with the first event, so the change has to be
+	// made once the request has subscribed.
+	go func() {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			dir.Lock()
+			subscribed := len(dir.subscribers) > 0
+			dir.Unlock()
+			if subscribed {
+				ioutil.WriteFile(filepath.Join(root, "A.class"), []byte{1, 2}, 0644)
+				dir.rescan()
+				return
+			}
+		}
+	}()
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Errorf("content type %q", contentType)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	var event []string
+	for lines.Scan() && lines.Text() != "" {
+		event = append(event, lines.Text())
+	}
+	if want := []string{"event:change", `data:{"kind":"modified","path":"A.class"}`}; !reflect.DeepEqual(event, want) {
+		t.Errorf("event %q, want %q", event, want)
+	}
+}